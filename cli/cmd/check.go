@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"bufio"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -24,6 +25,8 @@ type checkOptions struct {
 	namespace       string
 	cniEnabled      bool
 	output          string
+	fix             bool
+	yes             bool
 }
 
 func newCheckOptions() *checkOptions {
@@ -35,6 +38,8 @@ func newCheckOptions() *checkOptions {
 		namespace:       "",
 		cniEnabled:      false,
 		output:          tableOutput,
+		fix:             false,
+		yes:             false,
 	}
 }
 
@@ -57,6 +62,8 @@ func (options *checkOptions) checkFlagSet() *pflag.FlagSet {
 	flags.StringVar(&options.versionOverride, "expected-version", options.versionOverride, "Overrides the version used when checking if Linkerd is running the latest version (mostly for testing)")
 	flags.StringVarP(&options.output, "output", "o", options.output, "Output format. One of: basic, json")
 	flags.DurationVar(&options.wait, "wait", options.wait, "Maximum allowed time for all tests to pass")
+	flags.BoolVar(&options.fix, "fix", options.fix, "Apply the automated fixes available for failed checks, after confirmation, and run them again")
+	flags.BoolVarP(&options.yes, "yes", "y", options.yes, "Apply fixes without asking for confirmation (requires --fix)")
 
 	return flags
 }
@@ -68,6 +75,12 @@ func (options *checkOptions) validate() error {
 	if options.output != tableOutput && options.output != jsonOutput {
 		return fmt.Errorf("Invalid output type '%s'. Supported output types are: %s, %s", options.output, jsonOutput, tableOutput)
 	}
+	if options.yes && !options.fix {
+		return errors.New("--yes requires --fix")
+	}
+	if options.fix && !options.yes && options.output == jsonOutput {
+		return errors.New("--fix with JSON output requires --yes")
+	}
 	return nil
 }
 
@@ -119,7 +132,10 @@ non-zero exit code.`,
   linkerd check config
 
   # Check that the Linkerd data plane proxies in the "app" namespace are up and running
-  linkerd check --proxy --namespace app`,
+  linkerd check --proxy --namespace app
+
+  # Check the Linkerd installation and fix the failures that can be fixed automatically
+  linkerd check --fix`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return configureAndRunChecks(stdout, stderr, "", options)
 		},
@@ -155,6 +171,7 @@ func configureAndRunChecks(wout io.Writer, werr io.Writer, stage string, options
 		if stage != configStage {
 			checks = append(checks, healthcheck.LinkerdControlPlaneExistenceChecks)
 			checks = append(checks, healthcheck.LinkerdAPIChecks)
+			checks = append(checks, healthcheck.LinkerdIdentityChecks)
 
 			if options.dataPlaneOnly {
 				checks = append(checks, healthcheck.LinkerdDataPlaneChecks)
@@ -164,7 +181,7 @@ func configureAndRunChecks(wout io.Writer, werr io.Writer, stage string, options
 		}
 	}
 
-	hc := healthcheck.NewHealthChecker(checks, &healthcheck.Options{
+	hcOptions := &healthcheck.Options{
 		ControlPlaneNamespace: controlPlaneNamespace,
 		DataPlaneNamespace:    options.namespace,
 		KubeConfig:            kubeconfigPath,
@@ -172,7 +189,11 @@ func configureAndRunChecks(wout io.Writer, werr io.Writer, stage string, options
 		APIAddr:               apiAddr,
 		VersionOverride:       options.versionOverride,
 		RetryDeadline:         time.Now().Add(options.wait),
-	})
+	}
+	if options.fix {
+		hcOptions.ConfirmFix = confirmFix(os.Stdin, wout, options.yes)
+	}
+	hc := healthcheck.NewHealthChecker(checks, hcOptions)
 
 	success := runChecks(wout, werr, hc, options.output)
 
@@ -208,6 +229,16 @@ func runChecksTable(wout io.Writer, hc *healthcheck.HealthChecker) bool {
 		}
 
 		spin.Stop()
+		if result.Remediation {
+			if result.Err != nil {
+				fmt.Fprintf(wout, "    failed to %s: %s\n", result.Description, result.Err)
+				return
+			}
+			for _, change := range result.Changes {
+				fmt.Fprintf(wout, "    %s\n", change)
+			}
+			return
+		}
 		if result.Retry {
 			if isatty.IsTerminal(os.Stdout.Fd()) {
 				spin.Suffix = fmt.Sprintf(" %s -- %s", result.Description, result.Err)
@@ -224,12 +255,19 @@ func runChecksTable(wout io.Writer, hc *healthcheck.HealthChecker) bool {
 			}
 		}
 
-		fmt.Fprintf(wout, "%s %s\n", status, result.Description)
+		if verbose {
+			fmt.Fprintf(wout, "%s %s (%s)\n", status, result.Description, formatCheckDuration(result.Duration))
+		} else {
+			fmt.Fprintf(wout, "%s %s\n", status, result.Description)
+		}
 		if result.Err != nil {
 			fmt.Fprintf(wout, "    %s\n", result.Err)
 			if result.HintAnchor != "" {
 				fmt.Fprintf(wout, "    see %s%s for hints\n", healthcheck.HintBaseURL, result.HintAnchor)
 			}
+			if hc.ConfirmFix != nil && result.Fix == "" {
+				fmt.Fprintln(wout, "    no automated fix available, this has to be fixed manually")
+			}
 		}
 	}
 
@@ -263,6 +301,10 @@ type check struct {
 	Hint        string      `json:"hint,omitempty"`
 	Error       string      `json:"error,omitempty"`
 	Result      checkResult `json:"result"`
+	Duration    string      `json:"duration"`
+	Fix         string      `json:"fix,omitempty"`
+	FixError    string      `json:"fixError,omitempty"`
+	Changes     []string    `json:"changes,omitempty"`
 }
 
 type checkResult string
@@ -271,6 +313,10 @@ const (
 	checkSuccess checkResult = "success"
 	checkWarn    checkResult = "warning"
 	checkErr     checkResult = "error"
+
+	// manualFix is reported as the fix of failed checks that don't have an
+	// automated fix, when fixes are enabled.
+	manualFix = "manual"
 )
 
 func runChecksJSON(wout io.Writer, werr io.Writer, hc *healthcheck.HealthChecker) bool {
@@ -285,6 +331,20 @@ func runChecksJSON(wout io.Writer, werr io.Writer, hc *healthcheck.HealthChecker
 			})
 		}
 
+		if result.Remediation {
+			// fixes are reported along with the check they fix, which is followed by
+			// the result of running it again
+			checks := categories[len(categories)-1].Checks
+			if len(checks) > 0 {
+				fixed := checks[len(checks)-1]
+				fixed.Changes = result.Changes
+				if result.Err != nil {
+					fixed.FixError = result.Err.Error()
+				}
+			}
+			return
+		}
+
 		if !result.Retry {
 			currentCategory := categories[len(categories)-1]
 			// ignore checks that are going to be retried, we want only final results
@@ -299,6 +359,7 @@ func runChecksJSON(wout io.Writer, werr io.Writer, hc *healthcheck.HealthChecker
 			currentCheck := &check{
 				Description: result.Description,
 				Result:      status,
+				Duration:    formatCheckDuration(result.Duration),
 			}
 
 			if result.Err != nil {
@@ -307,6 +368,13 @@ func runChecksJSON(wout io.Writer, werr io.Writer, hc *healthcheck.HealthChecker
 				if result.HintAnchor != "" {
 					currentCheck.Hint = fmt.Sprintf("%s%s", healthcheck.HintBaseURL, result.HintAnchor)
 				}
+
+				if hc.ConfirmFix != nil {
+					currentCheck.Fix = result.Fix
+					if currentCheck.Fix == "" {
+						currentCheck.Fix = manualFix
+					}
+				}
 			}
 			currentCategory.Checks = append(currentCategory.Checks, currentCheck)
 		}
@@ -327,3 +395,29 @@ func runChecksJSON(wout io.Writer, werr io.Writer, hc *healthcheck.HealthChecker
 	}
 	return result
 }
+
+// formatCheckDuration rounds a check's duration to the millisecond, which is
+// precise enough to tell slow checks apart.
+func formatCheckDuration(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
+
+// confirmFix returns a function that asks for confirmation before a failed
+// check is fixed, reading the answer from in, unless yes is set.
+func confirmFix(in io.Reader, out io.Writer, yes bool) func(*healthcheck.CheckResult) bool {
+	reader := bufio.NewReader(in)
+	return func(result *healthcheck.CheckResult) bool {
+		if yes {
+			return true
+		}
+
+		fmt.Fprintf(out, "    %s? [y/N] ", result.Fix)
+		answer, err := reader.ReadString('\n')
+		if err != nil && answer == "" {
+			fmt.Fprintln(out)
+			return false
+		}
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		return answer == "y" || answer == "yes"
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"strings"
 	"testing"
 
 	"github.com/linkerd/linkerd2/pkg/healthcheck"
@@ -65,3 +66,34 @@ func TestCheckStatus(t *testing.T) {
 		}
 	})
 }
+
+func TestConfirmFix(t *testing.T) {
+	result := &healthcheck.CheckResult{Fix: "create the missing ServiceAccounts"}
+
+	testCases := []struct {
+		input    string
+		yes      bool
+		expected bool
+		prompt   string
+	}{
+		{"y\n", false, true, "    create the missing ServiceAccounts? [y/N] "},
+		{"Yes\n", false, true, "    create the missing ServiceAccounts? [y/N] "},
+		{"\n", false, false, "    create the missing ServiceAccounts? [y/N] "},
+		{"", false, false, "    create the missing ServiceAccounts? [y/N] \n"},
+		{"", true, true, ""},
+	}
+
+	for i, tc := range testCases {
+		tc := tc // pin
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			output := bytes.NewBufferString("")
+			confirmed := confirmFix(strings.NewReader(tc.input), output, tc.yes)(result)
+			if confirmed != tc.expected {
+				t.Fatalf("Expected confirmation to be %t, got %t", tc.expected, confirmed)
+			}
+			if output.String() != tc.prompt {
+				t.Fatalf("Expected prompt %q, got %q", tc.prompt, output.String())
+			}
+		})
+	}
+}
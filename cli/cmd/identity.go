@@ -0,0 +1,484 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linkerd/linkerd2/pkg/healthcheck"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	"github.com/linkerd/linkerd2/pkg/tls"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Trust anchor rotation happens in phases, so that every proxy trusts both
+// the old and the new trust anchors while certificates issued under either are
+// in use.
+const (
+	// rotationPhaseBundle adds the new trust anchors alongside the existing ones.
+	rotationPhaseBundle = "bundle"
+
+	// rotationPhaseIssuer replaces the issuer with one signed by the new trust
+	// anchors. The bundled trust anchors are kept.
+	rotationPhaseIssuer = "issuer"
+
+	// rotationPhaseFinalize drops all but the new trust anchors.
+	rotationPhaseFinalize = "finalize"
+)
+
+var (
+	rotationPhases = []string{rotationPhaseBundle, rotationPhaseIssuer, rotationPhaseFinalize}
+
+	rotationNextSteps = map[string]string{
+		rotationPhaseBundle: `The new trust anchors have been bundled with the existing ones. Once applied,
+restart all meshed workloads so that their proxies trust both sets of anchors,
+then run "linkerd check" and "linkerd identity rotate-anchors --phase issuer".`,
+		rotationPhaseIssuer: `The issuer has been replaced with one signed by the new trust anchors. Once
+applied, wait for proxies to renew their certificates (or restart all meshed
+workloads), then run "linkerd check" and "linkerd identity rotate-anchors --phase finalize".`,
+		rotationPhaseFinalize: `The old trust anchors have been removed. Once applied, restart all meshed
+workloads so that their proxies no longer trust them, then run "linkerd check".`,
+	}
+)
+
+const (
+	// expiryWarningFraction is the fraction of a certificate's lifetime below
+	// which it is reported as close to expiry. Proxies renew their certificates
+	// well before this point.
+	expiryWarningFraction = 0.25
+
+	// proxyCertExpiryMetric is the proxy metric reporting the expiry of its
+	// certificate, which is used when the certificate can't be fetched.
+	proxyCertExpiryMetric = "identity_cert_expiration_timestamp_seconds"
+)
+
+type identityOptions struct {
+	namespace string
+}
+
+// podIdentity describes the certificate served by a pod's proxy.
+type podIdentity struct {
+	pod      string
+	identity string
+
+	// crt is the certificate served by the proxy, if it could be fetched.
+	crt *tls.Crt
+
+	// expiry is the certificate expiry reported by the proxy's metrics, used
+	// when crt could not be fetched.
+	expiry time.Time
+
+	err error
+}
+
+func newIdentityOptions() *identityOptions {
+	return &identityOptions{
+		namespace: "default",
+	}
+}
+
+func newCmdIdentity() *cobra.Command {
+	options := newIdentityOptions()
+
+	cmd := &cobra.Command{
+		Use:   "identity [flags] (RESOURCE)",
+		Short: "Display the certificates of Linkerd proxies and manage Linkerd identity",
+		Long: `Display the certificates of Linkerd proxies and manage Linkerd identity.
+
+  This command initiates a port-forward to a given pod or set of pods, and
+  fetches the certificate served on the Linkerd proxy's inbound port. Each
+  certificate is verified against the configured trust anchors, and
+  certificates that are close to expiry are flagged.
+
+  If a certificate cannot be fetched, its expiry is read from the proxy's
+  metrics instead.
+
+  The RESOURCE argument specifies the target resource to inspect:
+  (TYPE/NAME)
+
+  Examples:
+  * deploy/my-deploy
+  * po/mypod1
+
+  Valid resource types include:
+  * daemonsets
+  * deployments
+  * jobs
+  * pods
+  * replicasets
+  * replicationcontrollers
+  * statefulsets`,
+		Example: `  # Display the certificate of pod-foo-bar in the default namespace.
+  linkerd identity po/pod-foo-bar
+
+  # Display the certificates of the web deployment in the emojivoto namespace.
+  linkerd identity -n emojivoto deploy/web`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return cmd.Help()
+			}
+
+			k8sAPI, err := k8s.NewAPI(kubeconfigPath, kubeContext, 0)
+			if err != nil {
+				return err
+			}
+
+			configs, err := fetchConfigs(k8sAPI)
+			if err != nil {
+				return err
+			}
+			idctx := configs.GetGlobal().GetIdentityContext()
+			if idctx == nil {
+				return errors.New("identity is not enabled for this Linkerd installation")
+			}
+			anchors, err := tls.DecodePEMCertificates(idctx.GetTrustAnchorsPem())
+			if err != nil {
+				return fmt.Errorf("invalid trust anchors: %s", err)
+			}
+
+			pods, err := getPodsFor(k8sAPI, options.namespace, args[0])
+			if err != nil {
+				return err
+			}
+			if len(pods) == 0 {
+				return fmt.Errorf("no pods found for %s", args[0])
+			}
+
+			results := make([]podIdentity, len(pods))
+			for i := range pods {
+				results[i] = getPodIdentity(k8sAPI, pods[i], idctx.GetTrustDomain())
+			}
+
+			renderPodIdentities(os.Stdout, results, anchors, time.Now())
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.namespace, "namespace", "n", options.namespace, "Namespace of resource")
+	cmd.AddCommand(newCmdIdentityRotateAnchors())
+
+	return cmd
+}
+
+// getPodIdentity fetches the certificate served on the inbound port of a
+// pod's proxy, falling back to the certificate expiry in its metrics.
+func getPodIdentity(k8sAPI *k8s.KubernetesAPI, pod corev1.Pod, trustDomain string) podIdentity {
+	sa, ns := k8s.GetServiceAccountAndNS(&pod)
+	result := podIdentity{
+		pod:      pod.GetName(),
+		identity: fmt.Sprintf("%s.%s.serviceaccount.identity.%s.%s", sa, ns, controlPlaneNamespace, trustDomain),
+	}
+
+	result.crt, result.err = healthcheck.FetchProxyCertificate(context.Background(), k8sAPI, pod, result.identity, verbose)
+	if result.err == nil {
+		return result
+	}
+
+	metrics, err := getMetrics(k8sAPI, pod, verbose)
+	if err != nil {
+		return result
+	}
+	if expiry, ok := parseCertExpiry(metrics); ok {
+		result.expiry = expiry
+	}
+	return result
+}
+
+// parseCertExpiry reads the proxy's certificate expiry from its metrics.
+func parseCertExpiry(metrics []byte) (time.Time, bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(metrics))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || fields[0] != proxyCertExpiryMetric {
+			continue
+		}
+		secs, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || secs <= 0 {
+			return time.Time{}, false
+		}
+		return time.Unix(int64(secs), 0), true
+	}
+	return time.Time{}, false
+}
+
+func renderPodIdentities(w io.Writer, results []podIdentity, anchors []*x509.Certificate, now time.Time) {
+	roots := x509.NewCertPool()
+	for _, c := range anchors {
+		roots.AddCert(c)
+	}
+
+	for i, result := range results {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "POD %s (%d of %d)\n\n", result.pod, i+1, len(results))
+		fmt.Fprintf(w, "Identity: %s\n", result.identity)
+
+		if result.crt == nil {
+			fmt.Fprintf(w, "%s %s\n", failStatus, result.err)
+			if !result.expiry.IsZero() {
+				fmt.Fprintf(w, "Certificate expiry reported by the proxy: %s (%s)\n",
+					result.expiry.UTC().Format(time.RFC3339), describeExpiry(result.expiry, now))
+			}
+			continue
+		}
+
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Certificate:")
+		renderCertificate(w, result.crt.Certificate, now)
+		// The trust chain is stored from root to leaf, but is displayed from the
+		// leaf's issuer to the root. Proxies usually don't present the root, so
+		// the top of the chain is only labelled as a trust anchor if it is
+		// self-signed.
+		for j := len(result.crt.TrustChain) - 1; j >= 0; j-- {
+			c := result.crt.TrustChain[j]
+			if j == 0 && selfSigned(c) {
+				fmt.Fprintln(w, "Trust anchor:")
+			} else {
+				fmt.Fprintf(w, "Intermediate %d:\n", len(result.crt.TrustChain)-j)
+			}
+			renderCertificate(w, c, now)
+		}
+
+		fmt.Fprintln(w)
+		if err := result.crt.Verify(roots, result.identity); err != nil {
+			fmt.Fprintf(w, "%s certificate is not valid for the configured trust anchors: %s\n", failStatus, err)
+			continue
+		}
+		anchor := chainAnchor(result.crt, anchors)
+		if anchor == nil {
+			fmt.Fprintf(w, "%s certificate chains to the configured trust anchors\n", okStatus)
+			continue
+		}
+		fmt.Fprintf(w, "%s certificate chains to trust anchor %s (expires %s)\n",
+			okStatus, anchor.Subject, anchor.NotAfter.UTC().Format(time.RFC3339))
+		if expiresSoon(anchor, now) {
+			fmt.Fprintf(w, "%s trust anchor %s\n", warnStatus, describeExpiry(anchor.NotAfter, now))
+		}
+	}
+}
+
+func renderCertificate(w io.Writer, c *x509.Certificate, now time.Time) {
+	fmt.Fprintf(w, "  Subject:    %s\n", c.Subject)
+	if sans := certificateSANs(c); len(sans) > 0 {
+		fmt.Fprintf(w, "  SANs:       %s\n", strings.Join(sans, ", "))
+	}
+	fmt.Fprintf(w, "  Serial:     %s\n", c.SerialNumber.Text(16))
+	fmt.Fprintf(w, "  Not before: %s\n", c.NotBefore.UTC().Format(time.RFC3339))
+	fmt.Fprintf(w, "  Not after:  %s (%s)\n", c.NotAfter.UTC().Format(time.RFC3339), describeExpiry(c.NotAfter, now))
+	fmt.Fprintf(w, "  Issuer:     %s\n", c.Issuer)
+	if expiresSoon(c, now) {
+		fmt.Fprintf(w, "  %s less than %d%% of the certificate's lifetime remains\n",
+			warnStatus, int(expiryWarningFraction*100))
+	}
+}
+
+func certificateSANs(c *x509.Certificate) []string {
+	var sans []string
+	for _, n := range c.DNSNames {
+		sans = append(sans, "DNS:"+n)
+	}
+	for _, u := range c.URIs {
+		sans = append(sans, "URI:"+u.String())
+	}
+	for _, ip := range c.IPAddresses {
+		sans = append(sans, "IP:"+ip.String())
+	}
+	return sans
+}
+
+// selfSigned returns true if c is signed by its own key, as root certificates
+// are.
+func selfSigned(c *x509.Certificate) bool {
+	return bytes.Equal(c.RawSubject, c.RawIssuer) && c.CheckSignatureFrom(c) == nil
+}
+
+// chainAnchor returns the trust anchor that signed the root of crt's chain.
+func chainAnchor(crt *tls.Crt, anchors []*x509.Certificate) *x509.Certificate {
+	top := crt.Certificate
+	if len(crt.TrustChain) > 0 {
+		top = crt.TrustChain[0]
+	}
+	for _, a := range anchors {
+		if a.Equal(top) || top.CheckSignatureFrom(a) == nil {
+			return a
+		}
+	}
+	return nil
+}
+
+// expiresSoon returns true if less than expiryWarningFraction of c's lifetime
+// remains.
+func expiresSoon(c *x509.Certificate, now time.Time) bool {
+	lifetime := c.NotAfter.Sub(c.NotBefore)
+	return c.NotAfter.Sub(now) < time.Duration(float64(lifetime)*expiryWarningFraction)
+}
+
+func describeExpiry(notAfter, now time.Time) string {
+	remaining := notAfter.Sub(now).Round(time.Second)
+	if remaining <= 0 {
+		return fmt.Sprintf("expired %s ago", -remaining)
+	}
+	return fmt.Sprintf("expires in %s", remaining)
+}
+
+// newCmdIdentityRotateAnchors is a subcommand for `linkerd identity rotate-anchors`
+func newCmdIdentityRotateAnchors() *cobra.Command {
+	options := newUpgradeOptionsWithDefaults()
+	flags := options.recordableFlagSet()
+
+	cmd := &cobra.Command{
+		Use:   "rotate-anchors [flags]",
+		Args:  cobra.NoArgs,
+		Short: "Output Kubernetes configs to rotate the Linkerd identity trust anchors",
+		Long: `Output Kubernetes configs to rotate the Linkerd identity trust anchors.
+
+Trust anchors are rotated in three phases, each of which is applied like an
+upgrade and verified with "linkerd check" before moving on to the next:
+
+  bundle:   the new trust anchors are added alongside the existing ones. All
+            meshed workloads must then be restarted so that they trust both.
+  issuer:   the issuer is replaced with one signed by the new trust anchors.
+            Proxies must renew their certificates before the next phase.
+  finalize: the old trust anchors are removed.
+
+The same phases are followed by "linkerd upgrade --identity-trust-anchors-file",
+which determines the phase from the current configuration.`,
+		Example: `  # Bundle the new trust anchors with the existing ones.
+  linkerd identity rotate-anchors --phase bundle --identity-trust-anchors-file ca.crt | kubectl apply -f -
+
+  # Replace the issuer with one signed by the new trust anchors.
+  linkerd identity rotate-anchors --phase issuer --identity-trust-anchors-file ca.crt \
+    --identity-issuer-certificate-file issuer.crt --identity-issuer-key-file issuer.key | kubectl apply -f -
+
+  # Remove the old trust anchors.
+  linkerd identity rotate-anchors --phase finalize --identity-trust-anchors-file ca.crt | kubectl apply -f -`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if options.identityOptions.trustPEMFile == "" {
+				return errors.New("--identity-trust-anchors-file must be specified")
+			}
+			switch options.rotationPhase {
+			case rotationPhaseBundle, rotationPhaseIssuer, rotationPhaseFinalize:
+			default:
+				return fmt.Errorf("--phase must be one of: %s", strings.Join(rotationPhases, ", "))
+			}
+			return upgradeRunE(options, "", flags)
+		},
+	}
+
+	cmd.Flags().StringVar(
+		&options.rotationPhase, "phase", options.rotationPhase,
+		fmt.Sprintf("Phase of the rotation to perform (one of: %s)", strings.Join(rotationPhases, ", ")),
+	)
+	cmd.Flags().AddFlagSet(options.upgradeOnlyFlagSet())
+
+	return cmd
+}
+
+// rotateTrustAnchors returns a copy of the current identity values updated for
+// a phase of a trust anchor rotation to the trust anchors in trustPEMFile, and
+// the phase that was performed.
+//
+// If phase is empty, it is determined from the current configuration and
+// whether new issuer credentials were provided.
+func (idopts *installIdentityOptions) rotateTrustAnchors(current *installIdentityValues, phase string) (*installIdentityValues, string, error) {
+	trustb, err := ioutil.ReadFile(idopts.trustPEMFile)
+	if err != nil {
+		return nil, "", err
+	}
+	trustAnchorsPEM := string(trustb)
+	trustAnchors, err := tls.DecodePEMCertificates(trustAnchorsPEM)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid trust anchors: %s", err)
+	}
+	if len(trustAnchors) == 0 {
+		return nil, "", fmt.Errorf("no trust anchors found in %s", idopts.trustPEMFile)
+	}
+	currentAnchors, err := tls.DecodePEMCertificates(current.TrustAnchorsPEM)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid current trust anchors: %s", err)
+	}
+
+	var issuer *tls.Cred
+	if idopts.crtPEMFile != "" || idopts.keyPEMFile != "" {
+		if idopts.crtPEMFile == "" || idopts.keyPEMFile == "" {
+			return nil, "", errors.New("both an issuer certificate and key file must be specified")
+		}
+		if issuer, err = tls.ReadPEMCreds(idopts.keyPEMFile, idopts.crtPEMFile); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if phase == "" {
+		switch {
+		case issuer != nil:
+			phase = rotationPhaseIssuer
+		case !tls.ContainsCertificates(currentAnchors, trustAnchors...):
+			phase = rotationPhaseBundle
+		default:
+			phase = rotationPhaseFinalize
+		}
+	}
+	if issuer != nil && phase != rotationPhaseIssuer {
+		return nil, "", fmt.Errorf("the issuer may only be replaced in the %s phase", rotationPhaseIssuer)
+	}
+
+	roots := x509.NewCertPool()
+	for _, c := range trustAnchors {
+		roots.AddCert(c)
+	}
+	issuerName := fmt.Sprintf("identity.%s.%s", controlPlaneNamespace, current.TrustDomain)
+
+	rotated := *current
+	issuerValues := *current.Issuer
+	rotated.Issuer = &issuerValues
+
+	switch phase {
+	case rotationPhaseBundle:
+		rotated.TrustAnchorsPEM, err = tls.BundleCertificatesPEM(current.TrustAnchorsPEM, trustAnchorsPEM)
+		if err != nil {
+			return nil, "", err
+		}
+
+	case rotationPhaseIssuer:
+		if !tls.ContainsCertificates(currentAnchors, trustAnchors...) {
+			return nil, "", fmt.Errorf("the new trust anchors must be bundled with the current ones before the issuer is replaced; run the %s phase first", rotationPhaseBundle)
+		}
+		if issuer == nil {
+			return nil, "", errors.New("an issuer certificate and key file must be specified to replace the issuer")
+		}
+		if err := issuer.Verify(roots, issuerName); err != nil {
+			return nil, "", fmt.Errorf("the issuer is not signed by the new trust anchors: %s", err)
+		}
+
+		issuerValues.KeyPEM = issuer.EncodePrivateKeyPEM()
+		issuerValues.CrtPEM = issuer.EncodeCertificatePEM()
+		issuerValues.CrtExpiry = issuer.Crt.Certificate.NotAfter
+
+	case rotationPhaseFinalize:
+		crt, err := tls.DecodePEMCrt(current.Issuer.CrtPEM)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid current issuer certificate: %s", err)
+		}
+		if err := crt.Verify(roots, ""); err != nil {
+			return nil, "", fmt.Errorf("the current issuer is not signed by the new trust anchors; run the %s phase first: %s", rotationPhaseIssuer, err)
+		}
+		rotated.TrustAnchorsPEM = tls.EncodeCertificatesPEM(trustAnchors...)
+
+	default:
+		return nil, "", fmt.Errorf("unknown rotation phase: %s", phase)
+	}
+
+	return &rotated, phase, nil
+}
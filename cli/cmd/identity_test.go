@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linkerd/linkerd2/pkg/tls"
+)
+
+func TestRotateTrustAnchors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate-anchors")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+			t.Fatalf("failed to write %s: %s", name, err)
+		}
+		return path
+	}
+
+	issuerName := "identity.linkerd.cluster.local"
+	newIssuer := func(root string) (*tls.CA, *tls.CA) {
+		ca, err := tls.GenerateRootCAWithDefaults(root)
+		if err != nil {
+			t.Fatalf("failed to create root: %s", err)
+		}
+		issuer, err := ca.GenerateCA(issuerName, ca.Validity, -1)
+		if err != nil {
+			t.Fatalf("failed to create issuer: %s", err)
+		}
+		return ca, issuer
+	}
+	oldRoot, oldIssuer := newIssuer("old")
+	newRoot, nextIssuer := newIssuer("new")
+	oldPEM := oldRoot.Cred.Crt.EncodeCertificatePEM()
+	newPEM := newRoot.Cred.Crt.EncodeCertificatePEM()
+
+	trustFile := write("ca.crt", newPEM)
+	crtFile := write("issuer.crt", nextIssuer.Cred.EncodeCertificatePEM())
+	keyFile := write("issuer.key", nextIssuer.Cred.EncodePrivateKeyPEM())
+
+	initial := &installIdentityValues{
+		TrustDomain:     "cluster.local",
+		TrustAnchorsPEM: oldPEM,
+		Issuer: &issuerValues{
+			KeyPEM: oldIssuer.Cred.EncodePrivateKeyPEM(),
+			CrtPEM: oldIssuer.Cred.EncodeCertificatePEM(),
+		},
+	}
+
+	rotate := func(current *installIdentityValues, phase string, withIssuer bool) (*installIdentityValues, string, error) {
+		idopts := &installIdentityOptions{trustPEMFile: trustFile}
+		if withIssuer {
+			idopts.crtPEMFile, idopts.keyPEMFile = crtFile, keyFile
+		}
+		return idopts.rotateTrustAnchors(current, phase)
+	}
+
+	var bundled, reissued *installIdentityValues
+
+	t.Run("bundles the new trust anchors with the current ones", func(t *testing.T) {
+		var phase string
+		bundled, phase, err = rotate(initial, "", false)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if phase != rotationPhaseBundle {
+			t.Fatalf("expected the %s phase, got %s", rotationPhaseBundle, phase)
+		}
+		if bundled.TrustAnchorsPEM != oldPEM+newPEM {
+			t.Fatalf("expected old and new trust anchors, got:\n%s", bundled.TrustAnchorsPEM)
+		}
+		if bundled.Issuer.CrtPEM != initial.Issuer.CrtPEM {
+			t.Fatal("expected the issuer to be unchanged")
+		}
+		if initial.TrustAnchorsPEM != oldPEM {
+			t.Fatal("expected the current values to be unchanged")
+		}
+	})
+
+	t.Run("refuses to replace the issuer before bundling", func(t *testing.T) {
+		_, _, err := rotate(initial, rotationPhaseIssuer, true)
+		if err == nil || !strings.Contains(err.Error(), "run the bundle phase first") {
+			t.Fatalf("expected an error, got: %v", err)
+		}
+	})
+
+	t.Run("refuses to finalize before replacing the issuer", func(t *testing.T) {
+		_, _, err := rotate(bundled, rotationPhaseFinalize, false)
+		if err == nil || !strings.Contains(err.Error(), "run the issuer phase first") {
+			t.Fatalf("expected an error, got: %v", err)
+		}
+	})
+
+	t.Run("replaces the issuer", func(t *testing.T) {
+		var phase string
+		reissued, phase, err = rotate(bundled, "", true)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if phase != rotationPhaseIssuer {
+			t.Fatalf("expected the %s phase, got %s", rotationPhaseIssuer, phase)
+		}
+		if reissued.TrustAnchorsPEM != bundled.TrustAnchorsPEM {
+			t.Fatal("expected the bundled trust anchors to be kept")
+		}
+		if reissued.Issuer.CrtPEM != nextIssuer.Cred.EncodeCertificatePEM() {
+			t.Fatal("expected the issuer to be replaced")
+		}
+	})
+
+	t.Run("refuses to replace the issuer outside of the issuer phase", func(t *testing.T) {
+		_, _, err := rotate(bundled, rotationPhaseFinalize, true)
+		if err == nil || !strings.Contains(err.Error(), "may only be replaced") {
+			t.Fatalf("expected an error, got: %v", err)
+		}
+	})
+
+	t.Run("drops the old trust anchors", func(t *testing.T) {
+		finalized, phase, err := rotate(reissued, "", false)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if phase != rotationPhaseFinalize {
+			t.Fatalf("expected the %s phase, got %s", rotationPhaseFinalize, phase)
+		}
+		if finalized.TrustAnchorsPEM != newPEM {
+			t.Fatalf("expected only the new trust anchors, got:\n%s", finalized.TrustAnchorsPEM)
+		}
+	})
+}
+
+func TestRenderPodIdentities(t *testing.T) {
+	identity := "web.emojivoto.serviceaccount.identity.linkerd.cluster.local"
+
+	root, err := tls.GenerateRootCAWithDefaults("Linkerd Root CA")
+	if err != nil {
+		t.Fatalf("failed to create root: %s", err)
+	}
+	issuer, err := root.GenerateCA("identity.linkerd.cluster.local", root.Validity, -1)
+	if err != nil {
+		t.Fatalf("failed to create issuer: %s", err)
+	}
+	cred, err := issuer.GenerateEndEntityCred(identity)
+	if err != nil {
+		t.Fatalf("failed to create end entity cred: %s", err)
+	}
+	other, err := tls.GenerateRootCAWithDefaults("Other Root CA")
+	if err != nil {
+		t.Fatalf("failed to create root: %s", err)
+	}
+
+	// the chain of cred is the root and the issuer; proxies usually only
+	// present the issuer
+	withoutRoot := cred.Crt
+	withoutRoot.TrustChain = cred.Crt.TrustChain[1:]
+
+	results := []podIdentity{
+		{pod: "web-1", identity: identity, crt: &cred.Crt},
+		{pod: "web-2", identity: identity, err: errors.New("connection refused"), expiry: time.Now().Add(-time.Hour)},
+	}
+
+	t.Run("verifies certificates against the trust anchors", func(t *testing.T) {
+		var buf bytes.Buffer
+		renderPodIdentities(&buf, results, []*x509.Certificate{root.Cred.Crt.Certificate}, time.Now())
+		out := buf.String()
+
+		expected := []string{
+			"POD web-1 (1 of 2)",
+			"Identity: " + identity,
+			"SANs:       DNS:" + identity,
+			"Issuer:     CN=identity.linkerd.cluster.local",
+			"Intermediate 1:",
+			"certificate chains to trust anchor CN=Linkerd Root CA",
+			"POD web-2 (2 of 2)",
+			"connection refused",
+			"Certificate expiry reported by the proxy",
+			"expired 1h0m0s ago",
+		}
+		for _, e := range expected {
+			if !strings.Contains(out, e) {
+				t.Errorf("expected output to contain %q, got:\n%s", e, out)
+			}
+		}
+		if strings.Contains(out, "lifetime remains") {
+			t.Errorf("expected no expiry warnings, got:\n%s", out)
+		}
+	})
+
+	t.Run("labels a self-signed root at the top of the chain as the trust anchor", func(t *testing.T) {
+		anchors := []*x509.Certificate{root.Cred.Crt.Certificate}
+
+		var buf bytes.Buffer
+		renderPodIdentities(&buf, results[:1], anchors, time.Now())
+		out := buf.String()
+		for _, e := range []string{"Intermediate 1:\n  Subject:    CN=identity.linkerd.cluster.local", "Trust anchor:\n  Subject:    CN=Linkerd Root CA"} {
+			if !strings.Contains(out, e) {
+				t.Errorf("expected output to contain %q, got:\n%s", e, out)
+			}
+		}
+		if strings.Contains(out, "Intermediate 2:") {
+			t.Errorf("expected the root not to be labelled as an intermediate, got:\n%s", out)
+		}
+
+		buf.Reset()
+		renderPodIdentities(&buf, []podIdentity{{pod: "web-1", identity: identity, crt: &withoutRoot}}, anchors, time.Now())
+		out = buf.String()
+		if !strings.Contains(out, "Intermediate 1:\n  Subject:    CN=identity.linkerd.cluster.local") || strings.Contains(out, "Trust anchor:") {
+			t.Errorf("expected the issuer to be labelled as an intermediate, got:\n%s", out)
+		}
+	})
+
+	t.Run("reports certificates that don't chain to the trust anchors", func(t *testing.T) {
+		var buf bytes.Buffer
+		renderPodIdentities(&buf, results[:1], []*x509.Certificate{other.Cred.Crt.Certificate}, time.Now())
+		if !strings.Contains(buf.String(), "certificate is not valid for the configured trust anchors") {
+			t.Fatalf("expected a verification failure, got:\n%s", buf.String())
+		}
+	})
+
+	t.Run("flags certificates close to expiry", func(t *testing.T) {
+		var buf bytes.Buffer
+		later := cred.Crt.Certificate.NotAfter.Add(-time.Hour)
+		renderPodIdentities(&buf, results[:1], []*x509.Certificate{root.Cred.Crt.Certificate}, later)
+		if !strings.Contains(buf.String(), "less than 25% of the certificate's lifetime remains") {
+			t.Fatalf("expected an expiry warning, got:\n%s", buf.String())
+		}
+	})
+}
+
+func TestParseCertExpiry(t *testing.T) {
+	metrics := []byte(`# HELP identity_cert_expiration_timestamp_seconds Time when the this proxy's current mTLS identity certificate will expire (in seconds since the UNIX epoch)
+# TYPE identity_cert_expiration_timestamp_seconds gauge
+identity_cert_expiration_timestamp_seconds 1555000000
+request_total{direction="inbound"} 3
+`)
+	expiry, ok := parseCertExpiry(metrics)
+	if !ok || !expiry.Equal(time.Unix(1555000000, 0)) {
+		t.Fatalf("unexpected expiry: %s", expiry)
+	}
+
+	if _, ok := parseCertExpiry([]byte("request_total 3\n")); ok {
+		t.Fatal("expected no expiry")
+	}
+}
@@ -109,6 +109,11 @@ sub-folders, or coming from stdin.`,
 		"Disables resources from from being tapped",
 	)
 
+	flags.BoolVar(
+		&options.identityProjectedToken, "identity-projected-token", options.identityProjectedToken,
+		"Provisions identity with a projected, audience-scoped service account token instead of the default service account token",
+	)
+
 	flags.BoolVar(
 		&options.ignoreCluster, "ignore-cluster", options.ignoreCluster,
 		"Ignore the current Kubernetes cluster when checking for existing cluster configuration (default false)",
@@ -396,6 +401,10 @@ func (options *proxyConfigOptions) overrideConfigs(configs *cfg.All, overrideAnn
 		overrideAnnotations[k8s.ProxyDisableTapAnnotation] = strconv.FormatBool(true)
 	}
 
+	if options.identityProjectedToken {
+		overrideAnnotations[k8s.ProxyIdentityProjectedTokenAnnotation] = strconv.FormatBool(true)
+	}
+
 	// keep track of this option because its true/false value results in different
 	// values being assigned to the LINKERD2_PROXY_DESTINATION_PROFILE_SUFFIXES
 	// env var. Its annotation is added only if its value is true.
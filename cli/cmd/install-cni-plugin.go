@@ -24,6 +24,7 @@ type installCNIPluginConfig struct {
 	IgnoreInboundPorts  string
 	IgnoreOutboundPorts string
 	ProxyUID            int64
+	IPv6                bool
 	DestCNINetDir       string
 	DestCNIBinDir       string
 	CreatedByAnnotation string
@@ -40,6 +41,7 @@ type cniPluginOptions struct {
 	ignoreInboundPorts  []uint
 	ignoreOutboundPorts []uint
 	proxyUID            int64
+	ipv6                bool
 	cniPluginImage      string
 	logLevel            string
 	destCNINetDir       string
@@ -116,6 +118,7 @@ assumes that the 'linkerd install' command will be executed with the
 	cmd.PersistentFlags().UintVar(&options.proxyAdminPort, "admin-port", options.proxyAdminPort, "Proxy port to serve metrics on")
 	cmd.PersistentFlags().UintSliceVar(&options.ignoreInboundPorts, "skip-inbound-ports", options.ignoreInboundPorts, "Ports that should skip the proxy and send directly to the application")
 	cmd.PersistentFlags().UintSliceVar(&options.ignoreOutboundPorts, "skip-outbound-ports", options.ignoreOutboundPorts, "Outbound ports that should skip the proxy")
+	cmd.PersistentFlags().BoolVar(&options.ipv6, "ipv6", options.ipv6, "Experimental: Also redirect the IPv6 traffic of pods with IPv6 addresses to the proxy, using ip6tables (only the CNI plugin supports IPv6; the proxy-init container used without it redirects IPv4 traffic only)")
 	cmd.PersistentFlags().StringVar(&options.cniPluginImage, "cni-image", options.cniPluginImage, "Image for the cni-plugin.")
 	cmd.PersistentFlags().StringVar(&options.logLevel, "cni-log-level", options.logLevel, "Log level for the cni-plugin.")
 	cmd.PersistentFlags().StringVar(&options.destCNINetDir, "dest-cni-net-dir", options.destCNINetDir, "Directory on the host where the CNI configuration will be placed.")
@@ -150,6 +153,7 @@ func validateAndBuildCNIConfig(options *cniPluginOptions) (*installCNIPluginConf
 		IgnoreInboundPorts:  strings.Join(ignoreInboundPorts, ","),
 		IgnoreOutboundPorts: strings.Join(ignoreOutboundPorts, ","),
 		ProxyUID:            options.proxyUID,
+		IPv6:                options.ipv6,
 		DestCNINetDir:       options.destCNINetDir,
 		DestCNIBinDir:       options.destCNIBinDir,
 		CreatedByAnnotation: k8s.CreatedByAnnotation,
@@ -24,6 +24,7 @@ func TestRenderCNIPlugin(t *testing.T) {
 		ignoreInboundPorts:  make([]uint, 0),
 		ignoreOutboundPorts: make([]uint, 0),
 		proxyUID:            12102,
+		ipv6:                true,
 		cniPluginImage:      "my-docker-registry.io/awesome/cni-plugin-test-image",
 		logLevel:            "debug",
 		destCNINetDir:       "/etc/kubernetes/cni/net.d",
@@ -19,6 +19,7 @@ import (
 	"github.com/linkerd/linkerd2/pkg/healthcheck"
 	"github.com/linkerd/linkerd2/pkg/k8s"
 	"github.com/linkerd/linkerd2/pkg/tls"
+	"github.com/linkerd/linkerd2/pkg/util"
 	"github.com/linkerd/linkerd2/pkg/version"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -60,6 +61,7 @@ type (
 		NoInitContainer          bool
 		WebhookFailurePolicy     string
 		OmitWebhookSideEffects   bool
+		WebBasePath              string
 
 		Configs configJSONs
 
@@ -130,6 +132,7 @@ type (
 		noInitContainer        bool
 		skipChecks             bool
 		omitWebhookSideEffects bool
+		webBasePath            string
 		identityOptions        *installIdentityOptions
 		*proxyConfigOptions
 
@@ -443,6 +446,10 @@ func (options *installOptions) recordableFlagSet() *pflag.FlagSet {
 		&options.omitWebhookSideEffects, "omit-webhook-side-effects", options.omitWebhookSideEffects,
 		"Omit the sideEffects flag in the webhook manifests, This flag must be provided during install or upgrade for Kubernetes versions pre 1.12",
 	)
+	flags.StringVar(
+		&options.webBasePath, "base-path", options.webBasePath,
+		"Path prefix under which the dashboard and Grafana are served, e.g. /linkerd behind an ingress (default serves them at the root)",
+	)
 
 	flags.StringVarP(&options.controlPlaneVersion, "control-plane-version", "", options.controlPlaneVersion, "(Development) Tag to be used for the control plane component images")
 	flags.MarkHidden("control-plane-version")
@@ -522,6 +529,10 @@ func (options *installOptions) validate() error {
 		return fmt.Errorf("--controller-log-level must be one of: panic, fatal, error, warn, info, debug")
 	}
 
+	if options.webBasePath != "" && !alphaNumDashDotSlash.MatchString(options.webBasePath) {
+		return fmt.Errorf("--base-path must be a URL path, e.g. /linkerd")
+	}
+
 	if err := options.proxyConfigOptions.validate(); err != nil {
 		return err
 	}
@@ -585,6 +596,7 @@ func (options *installOptions) buildValuesWithoutIdentity(configs *pb.All) (*ins
 		WebhookFailurePolicy:   "Ignore",
 		OmitWebhookSideEffects: options.omitWebhookSideEffects,
 		PrometheusLogLevel:     toPromLogLevel(strings.ToLower(options.controllerLogLevel)),
+		WebBasePath:            util.NormalizeBasePath(options.webBasePath),
 
 		Configs: configJSONs{
 			Global:  globalJSON,
@@ -662,6 +674,7 @@ func (values *installValues) render(w io.Writer, configs *pb.All) error {
 			{Name: "templates/controller-rbac.yaml"},
 			{Name: "templates/web-rbac.yaml"},
 			{Name: "templates/serviceprofile-crd.yaml"},
+			{Name: "templates/workloadidentity-crd.yaml"},
 			{Name: "templates/prometheus-rbac.yaml"},
 			{Name: "templates/grafana-rbac.yaml"},
 			{Name: "templates/proxy_injector-rbac.yaml"},
@@ -1038,10 +1051,6 @@ func webhookCommonName(webhook string) string {
 	return fmt.Sprintf("%s.%s.svc", webhook, controlPlaneNamespace)
 }
 
-func webhookSecretName(webhook string) string {
-	return fmt.Sprintf("%s-tls", webhook)
-}
-
 func verifyWebhookTLS(value *tlsValues, webhook string) error {
 	crt, err := tls.DecodePEMCrt(value.CrtPEM)
 	if err != nil {
@@ -99,6 +99,11 @@ func TestRender(t *testing.T) {
 	noInitContainerOptions.noInitContainer = true
 	noInitContainerValues, noInitContainerConfig, _ := noInitContainerOptions.validateAndBuild("", nil)
 
+	basePathOptions := testInstallOptions()
+	basePathOptions.recordedFlags = []*config.Install_Flag{{Name: "base-path", Value: "/linkerd/"}}
+	basePathOptions.webBasePath = "/linkerd/"
+	basePathValues, basePathConfig, _ := basePathOptions.validateAndBuild("", nil)
+
 	testCases := []struct {
 		values         *installValues
 		configs        *config.All
@@ -111,6 +116,7 @@ func TestRender(t *testing.T) {
 		{haValues, haConfig, "install_ha_output.golden"},
 		{haWithOverridesValues, haWithOverridesConfig, "install_ha_with_overrides_output.golden"},
 		{noInitContainerValues, noInitContainerConfig, "install_no_init_container.golden"},
+		{basePathValues, basePathConfig, "install_base_path.golden"},
 	}
 
 	for i, tc := range testCases {
@@ -163,6 +169,20 @@ func TestValidate(t *testing.T) {
 		}
 	})
 
+	t.Run("Rejects invalid base paths", func(t *testing.T) {
+		options := testInstallOptions()
+		options.webBasePath = "/linkerd/%(domain)s"
+		expected := "--base-path must be a URL path, e.g. /linkerd"
+
+		err := options.validate()
+		if err == nil {
+			t.Fatal("Expected error, got nothing")
+		}
+		if err.Error() != expected {
+			t.Fatalf("Expected error string\"%s\", got \"%s\"", expected, err)
+		}
+	})
+
 	t.Run("Ensure log level input is converted to lower case before passing to prometheus", func(t *testing.T) {
 		underTest := testInstallOptions()
 		underTest.controllerLogLevel = "DEBUG"
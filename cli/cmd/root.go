@@ -45,6 +45,7 @@ var (
 	// sanity check against illegal characters.
 	alphaNumDash              = regexp.MustCompile(`^[a-zA-Z0-9-]+$`)
 	alphaNumDashDot           = regexp.MustCompile(`^[\.a-zA-Z0-9-]+$`)
+	alphaNumDashDotSlash      = regexp.MustCompile(`^[\./a-zA-Z0-9-]+$`)
 	alphaNumDashDotSlashColon = regexp.MustCompile(`^[\./a-zA-Z0-9-:]+$`)
 
 	// Full Rust log level syntax at
@@ -100,6 +101,7 @@ func init() {
 	RootCmd.AddCommand(newCmdEdges())
 	RootCmd.AddCommand(newCmdEndpoints())
 	RootCmd.AddCommand(newCmdGet())
+	RootCmd.AddCommand(newCmdIdentity())
 	RootCmd.AddCommand(newCmdInject())
 	RootCmd.AddCommand(newCmdInstall())
 	RootCmd.AddCommand(newCmdInstallCNIPlugin())
@@ -195,9 +197,10 @@ type proxyConfigOptions struct {
 	proxyMemoryLimit       string
 	enableExternalProfiles bool
 	// ignoreCluster is not validated by validate().
-	ignoreCluster   bool
-	disableIdentity bool
-	disableTap      bool
+	ignoreCluster          bool
+	disableIdentity        bool
+	disableTap             bool
+	identityProjectedToken bool
 }
 
 func (options *proxyConfigOptions) validate() error {
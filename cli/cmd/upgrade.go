@@ -29,6 +29,11 @@ type upgradeOptions struct {
 	manifests string
 	*installOptions
 
+	// rotationPhase is the phase of a trust anchor rotation to perform when new
+	// trust anchors are provided. If empty, it is determined from the current
+	// configuration. Once the upgrade is built, it holds the phase performed.
+	rotationPhase string
+
 	verifyTLS func(tls *tlsValues, service string) error
 }
 
@@ -50,6 +55,18 @@ func (options *upgradeOptions) upgradeOnlyFlagSet() *pflag.FlagSet {
 		&options.manifests, "from-manifests", options.manifests,
 		"Read config from a Linkerd install YAML rather than from Kubernetes",
 	)
+	flags.StringVar(
+		&options.identityOptions.trustPEMFile, "identity-trust-anchors-file", options.identityOptions.trustPEMFile,
+		"A path to a PEM-encoded file containing new Linkerd Identity trust anchors to rotate to (see \"linkerd identity rotate-anchors\")",
+	)
+	flags.StringVar(
+		&options.identityOptions.crtPEMFile, "identity-issuer-certificate-file", options.identityOptions.crtPEMFile,
+		"A path to a PEM-encoded file containing a Linkerd Identity issuer certificate signed by the new trust anchors",
+	)
+	flags.StringVar(
+		&options.identityOptions.keyPEMFile, "identity-issuer-key-file", options.identityOptions.keyPEMFile,
+		"A path to a PEM-encoded file containing the private key of the new Linkerd Identity issuer",
+	)
 
 	return flags
 }
@@ -175,6 +192,9 @@ func upgradeRunE(options *upgradeOptions, stage string, flags *pflag.FlagSet) er
 	if stage == configStage {
 		fmt.Fprintf(os.Stderr, "%s\n", controlPlaneMessage)
 	}
+	if next, ok := rotationNextSteps[options.rotationPhase]; ok {
+		fmt.Fprintf(os.Stderr, "%s\n", next)
+	}
 	fmt.Fprintf(os.Stderr, "%s\n\n", visitMessage)
 
 	return nil
@@ -235,6 +255,14 @@ func (options *upgradeOptions) validateAndBuild(stage string, k kubernetes.Inter
 		if err != nil {
 			return nil, nil, fmt.Errorf("unable to fetch the existing issuer credentials from Kubernetes: %s", err)
 		}
+
+		if options.identityOptions.trustPEMFile != "" {
+			identity, options.rotationPhase, err = options.identityOptions.rotateTrustAnchors(identity, options.rotationPhase)
+			if err != nil {
+				return nil, nil, fmt.Errorf("unable to rotate the trust anchors: %s", err)
+			}
+			idctx.TrustAnchorsPem = identity.TrustAnchorsPEM
+		}
 	}
 
 	// Values have to be generated after any missing identity is generated,
@@ -310,7 +338,7 @@ func fetchWebhookTLS(k kubernetes.Interface, webhook string, options *upgradeOpt
 
 	secret, err := k.CoreV1().
 		Secrets(controlPlaneNamespace).
-		Get(webhookSecretName(webhook), metav1.GetOptions{})
+		Get(k8s.WebhookSecretName(webhook), metav1.GetOptions{})
 	if err != nil {
 		if !kerrors.IsNotFound(err) {
 			return nil, err
@@ -87,6 +87,9 @@ rules:
 - apiGroups: [""]
   resources: ["pods", "nodes", "namespaces"]
   verbs: ["list", "get", "watch"]
+- apiGroups: [""]
+  resources: ["nodes/status"]
+  verbs: ["update"]
 ---
 apiVersion: rbac.authorization.k8s.io/v1beta1
 kind: ClusterRoleBinding
@@ -114,6 +117,7 @@ data:
   inbound_ports_to_ignore: "{{.IgnoreInboundPorts}}"
   outbound_ports_to_ignore: "{{.IgnoreOutboundPorts}}"
   simulate: "false"
+  ipv6: "{{.IPv6}}"
   log_level: "{{.LogLevel}}"
   dest_cni_net_dir: "{{.DestCNINetDir}}"
   dest_cni_bin_dir: "{{.DestCNIBinDir}}"
@@ -139,7 +143,8 @@ data:
         "ports-to-redirect": [__PORTS_TO_REDIRECT__],
         "inbound-ports-to-ignore": [__INBOUND_PORTS_TO_IGNORE__],
         "outbound-ports-to-ignore": [__OUTBOUND_PORTS_TO_IGNORE__],
-        "simulate": __SIMULATE__
+        "simulate": __SIMULATE__,
+        "ipv6": __IPV6__
       }
     }
 ---
@@ -217,6 +222,11 @@ spec:
             configMapKeyRef:
               name: linkerd-cni-config
               key: inbound_ports_to_ignore
+        - name: IPV6
+          valueFrom:
+            configMapKeyRef:
+              name: linkerd-cni-config
+              key: ipv6
         - name: LOG_LEVEL
           valueFrom:
             configMapKeyRef:
@@ -224,6 +234,11 @@ spec:
               key: log_level
         - name: SLEEP
           value: "true"
+        # The node whose condition reports whether the plugin is configured.
+        - name: KUBERNETES_NODE_NAME
+          valueFrom:
+            fieldRef:
+              fieldPath: spec.nodeName
         lifecycle:
           preStop:
             exec:
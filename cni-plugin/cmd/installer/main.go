@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/linkerd/linkerd2/cni-plugin/installer"
+	"github.com/linkerd/linkerd2/pkg/admin"
+	"github.com/linkerd/linkerd2/pkg/flags"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	log "github.com/sirupsen/logrus"
+)
+
+// The installer keeps the linkerd-cni plugin in the node's CNI network
+// configuration, once install-cni.sh has copied the plugin and written its
+// configuration.
+func main() {
+	netDir := flag.String("net-dir", "/host/etc/cni/net.d", "node's CNI network configuration directory")
+	pluginConfPath := flag.String("plugin-conf", "/linkerd/linkerd-cni.plugin.conf", "path to the configuration of the linkerd-cni plugin")
+	interval := flag.Duration("interval", 10*time.Second, "interval at which the CNI network configuration is polled; it isn't watched, so the plugin can be missing from it for up to this long after another CNI plugin rewrites it")
+	nodeName := flag.String("node-name", os.Getenv("KUBERNETES_NODE_NAME"), "name of the node, whose condition reports the status of the configuration; empty to not report it")
+	kubeConfigPath := flag.String("kubeconfig", "", "path to kube config")
+	metricsAddr := flag.String("metrics-addr", "", "address to serve scrapable metrics on; empty to not serve them, since the installer runs on the node's network")
+	flags.ConfigureAndParse()
+
+	data, err := ioutil.ReadFile(*pluginConfPath)
+	if err != nil {
+		log.Fatalf("Failed to read the plugin configuration: %s", err)
+	}
+	var pluginConf map[string]interface{}
+	if err := json.Unmarshal(data, &pluginConf); err != nil {
+		log.Fatalf("Failed to parse the plugin configuration: %s", err)
+	}
+
+	i := &installer.Installer{
+		NetDir:     *netDir,
+		PluginConf: pluginConf,
+		Interval:   *interval,
+		NodeName:   *nodeName,
+	}
+	if *nodeName != "" {
+		kubeAPI, err := k8s.NewAPI(*kubeConfigPath, "", 0)
+		if err != nil {
+			log.Fatalf("Failed to initialize K8s API: %s", err)
+		}
+		i.Client = kubeAPI
+	}
+
+	if *metricsAddr != "" {
+		go admin.StartServer(*metricsAddr)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		<-stop
+		close(done)
+	}()
+
+	log.Infof("Checking the CNI network configuration in %s every %s", *netDir, *interval)
+	i.Run(done)
+	log.Info("Shutting down")
+}
@@ -61,6 +61,12 @@ CNI_CONF_PATH=${CNI_CONF_PATH:-"${CONTAINER_MOUNT_PREFIX}${DEST_CNI_NET_DIR}/01-
 KUBECONFIG_FILE_NAME=${KUBECONFIG_FILE_NAME:-ZZZ-linkerd-cni-kubeconfig}
 
 cleanup() {
+  # Stop the installer first, so that it doesn't insert the plugin again.
+  if [ -n "${INSTALLER_PID:-}" ]; then
+    kill "${INSTALLER_PID}" 2>/dev/null || true
+    wait "${INSTALLER_PID}" 2>/dev/null || true
+  fi
+
   echo 'Removing linkerd-cni artifacts.'
 
   if [ -e "${CNI_CONF_PATH}" ]; then
@@ -182,6 +188,7 @@ sed -i s~__PORTS_TO_REDIRECT__~"${PORTS_TO_REDIRECT:=}"~g ${TMP_CONF}
 sed -i s~__INBOUND_PORTS_TO_IGNORE__~"${INBOUND_PORTS_TO_IGNORE:=}"~g ${TMP_CONF}
 sed -i s~__OUTBOUND_PORTS_TO_IGNORE__~"${OUTBOUND_PORTS_TO_IGNORE:=}"~g ${TMP_CONF}
 sed -i s~__SIMULATE__~"${SIMULATE:=false}"~g ${TMP_CONF}
+sed -i s~__IPV6__~"${IPV6:=false}"~g ${TMP_CONF}
 
 CNI_OLD_CONF_PATH="${CNI_OLD_CONF_PATH:-${CNI_CONF_PATH}}"
 
@@ -191,6 +198,10 @@ echo "CNI config: $(cat ${TMP_CONF})"
 
 sed -i s/__SERVICEACCOUNT_TOKEN__/"${SERVICEACCOUNT_TOKEN:-}"/g ${TMP_CONF}
 
+# Keep the plugin config, for the installer to insert it again if it's removed.
+PLUGIN_CONF='/linkerd/linkerd-cni.plugin.conf'
+cp "${TMP_CONF}" "${PLUGIN_CONF}"
+
 CNI_CONF_FILE="${CNI_CONF_PATH}"
 if [ -e "${CNI_CONF_FILE}" ]; then
   # Add the linkerd-cni plugin to the existing list
@@ -218,11 +229,16 @@ mv "${TMP_CONF}" "${CNI_CONF_PATH}" || exit_with_error 'Failed to mv files.'
 
 echo "Created CNI config ${CNI_CONF_PATH}"
 
-# Unless told otherwise, sleep forever.
-# This prevents Kubernetes from restarting the pod repeatedly.
+# Unless told otherwise, keep running the installer, which inserts the plugin
+# again if another CNI plugin rewrites its config without it. This also
+# prevents Kubernetes from restarting the pod repeatedly.
 should_sleep=${SLEEP:-"true"}
 echo "Done configuring CNI. Sleep=$should_sleep"
-while [ "${should_sleep}" = "true"  ]; do
-  sleep infinity &
-  wait $!
-done
+if [ "${should_sleep}" = "true" ]; then
+  linkerd-cni-installer \
+    -net-dir "${CONTAINER_MOUNT_PREFIX}${DEST_CNI_NET_DIR}" \
+    -plugin-conf "${PLUGIN_CONF}" \
+    -node-name "${KUBERNETES_NODE_NAME:-}" &
+  INSTALLER_PID=$!
+  wait "${INSTALLER_PID}"
+fi
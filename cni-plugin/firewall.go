@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/containernetworking/cni/pkg/types/current"
+	"github.com/linkerd/linkerd2-proxy-init/cmd"
+	"github.com/sirupsen/logrus"
+)
+
+// The chains created by proxy-init in the nat table, and the built-in chains
+// that jump to them.
+var proxyInitChains = []struct {
+	chain  string
+	parent string
+}{
+	{chain: "PROXY_INIT_REDIRECT", parent: "PREROUTING"},
+	{chain: "PROXY_INIT_OUTPUT", parent: "OUTPUT"},
+}
+
+// iptablesBackend runs iptables commands against the nat table of a network
+// namespace.
+type iptablesBackend interface {
+	// rules returns the rules of the nat table, in `iptables -S` format
+	rules(netns string) ([]string, error)
+
+	// run runs an iptables command against the nat table
+	run(netns string, args ...string) error
+}
+
+// nsenterIptables runs iptables, or ip6tables, in a network namespace through
+// nsenter, the same way proxy-init does.
+type nsenterIptables struct {
+	command string
+}
+
+var (
+	iptablesBackendV4 = nsenterIptables{command: "iptables"}
+	iptablesBackendV6 = nsenterIptables{command: "ip6tables"}
+)
+
+func (n nsenterIptables) rules(netns string) ([]string, error) {
+	out, err := n.exec(netns, "-S")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSpace(string(out)), "\n"), nil
+}
+
+func (n nsenterIptables) run(netns string, args ...string) error {
+	_, err := n.exec(netns, args...)
+	return err
+}
+
+func (n nsenterIptables) exec(netns string, args ...string) ([]byte, error) {
+	nsenterArgs := append([]string{fmt.Sprintf("--net=%s", netns), n.command, "-t", "nat"}, args...)
+	var stderr bytes.Buffer
+	command := exec.Command("nsenter", nsenterArgs...)
+	command.Stderr = &stderr
+	out, err := command.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %s: %s", n.command, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// checkFirewall fails if the proxy-init chains are missing from the nat table,
+// or if they are not jumped to.
+func checkFirewall(backend iptablesBackend, netns string) error {
+	rules, err := backend.rules(netns)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, c := range proxyInitChains {
+		if !hasRule(rules, fmt.Sprintf("-N %s", c.chain)) {
+			missing = append(missing, fmt.Sprintf("chain %s", c.chain))
+		}
+		if len(jumpRules(rules, c.parent, c.chain)) == 0 {
+			missing = append(missing, fmt.Sprintf("jump from %s to %s", c.parent, c.chain))
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("linkerd-cni: missing iptables rules in %s: %s", netns, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// cleanupFirewall removes the proxy-init chains, and the rules that jump to
+// them, from the nat table. Rules that are already gone are skipped, so that
+// it can be called more than once.
+func cleanupFirewall(backend iptablesBackend, netns string) error {
+	rules, err := backend.rules(netns)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range proxyInitChains {
+		for _, rule := range jumpRules(rules, c.parent, c.chain) {
+			args := append([]string{"-D"}, splitRule(rule)[1:]...)
+			if err := backend.run(netns, args...); err != nil {
+				return err
+			}
+		}
+		if hasRule(rules, fmt.Sprintf("-N %s", c.chain)) {
+			if err := backend.run(netns, "-F", c.chain); err != nil {
+				return err
+			}
+			if err := backend.run(netns, "-X", c.chain); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ip6tablesRules returns the ip6tables commands that set up the nat table of a
+// pod for options, mirroring the rules that proxy-init sets up with iptables,
+// which doesn't support IPv6.
+func ip6tablesRules(options cmd.RootOptions) [][]string {
+	const (
+		redirectChain = "PROXY_INIT_REDIRECT"
+		outputChain   = "PROXY_INIT_OUTPUT"
+	)
+	inboundPort := strconv.Itoa(options.IncomingProxyPort)
+	outboundPort := strconv.Itoa(options.OutgoingProxyPort)
+
+	commands := [][]string{{"-N", redirectChain}}
+	for _, ports := range multiportDestinations(options.InboundPortsToIgnore) {
+		commands = append(commands, []string{"-A", redirectChain, "-p", "tcp", "-m", "multiport", "--dports", ports, "-j", "RETURN"})
+	}
+	if len(options.PortsToRedirect) > 0 {
+		for _, port := range options.PortsToRedirect {
+			commands = append(commands, []string{"-A", redirectChain, "-p", "tcp", "--dport", strconv.Itoa(port), "-j", "REDIRECT", "--to-ports", inboundPort})
+		}
+	} else {
+		commands = append(commands, []string{"-A", redirectChain, "-p", "tcp", "-j", "REDIRECT", "--to-ports", inboundPort})
+	}
+	commands = append(commands, []string{"-A", "PREROUTING", "-j", redirectChain})
+
+	commands = append(commands,
+		[]string{"-N", outputChain},
+		// the proxy's own traffic, and traffic over loopback, isn't redirected
+		[]string{"-A", outputChain, "-m", "owner", "--uid-owner", strconv.Itoa(options.ProxyUserID), "-j", "RETURN"},
+		[]string{"-A", outputChain, "-o", "lo", "-j", "RETURN"},
+	)
+	for _, ports := range multiportDestinations(options.OutboundPortsToIgnore) {
+		commands = append(commands, []string{"-A", outputChain, "-p", "tcp", "-m", "multiport", "--dports", ports, "-j", "RETURN"})
+	}
+	commands = append(commands,
+		[]string{"-A", outputChain, "-p", "tcp", "-j", "REDIRECT", "--to-ports", outboundPort},
+		[]string{"-A", "OUTPUT", "-j", outputChain},
+	)
+	return commands
+}
+
+// multiportDestinations splits ports into comma-separated lists that fit in a
+// multiport match, which takes at most 15 ports.
+func multiportDestinations(ports []int) []string {
+	const maxPorts = 15
+
+	var destinations []string
+	for i := 0; i < len(ports); i += maxPorts {
+		end := i + maxPorts
+		if end > len(ports) {
+			end = len(ports)
+		}
+		chunk := make([]string, 0, end-i)
+		for _, port := range ports[i:end] {
+			chunk = append(chunk, strconv.Itoa(port))
+		}
+		destinations = append(destinations, strings.Join(chunk, ","))
+	}
+	return destinations
+}
+
+// configureIPv6Firewall sets up the ip6tables rules for options, replacing any
+// rules left from a previous ADD for the same network namespace. With
+// SimulateOnly, the commands are logged instead of run.
+func configureIPv6Firewall(backend iptablesBackend, options cmd.RootOptions, logEntry *logrus.Entry) error {
+	commands := ip6tablesRules(options)
+	if options.SimulateOnly {
+		for _, command := range commands {
+			logEntry.Infof("linkerd-cni: ip6tables -t nat %s", strings.Join(command, " "))
+		}
+		return nil
+	}
+
+	if err := cleanupFirewall(backend, options.NetNs); err != nil {
+		return err
+	}
+	for _, command := range commands {
+		if err := backend.run(options.NetNs, command...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasIPv6 returns true if the result of the previous plugin in the chain
+// assigned an IPv6 address to the pod.
+func hasIPv6(result *current.Result) bool {
+	if result == nil {
+		return false
+	}
+	for _, ip := range result.IPs {
+		if ip.Version == "6" || (ip.Address.IP != nil && ip.Address.IP.To4() == nil) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasRule(rules []string, rule string) bool {
+	for _, r := range rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// jumpRules returns the rules of parent that jump to chain.
+func jumpRules(rules []string, parent, chain string) []string {
+	var jumps []string
+	for _, rule := range rules {
+		args := splitRule(rule)
+		if len(args) < 2 || args[0] != "-A" || args[1] != parent {
+			continue
+		}
+		for i := 2; i < len(args)-1; i++ {
+			if args[i] == "-j" && args[i+1] == chain {
+				jumps = append(jumps, rule)
+				break
+			}
+		}
+	}
+	return jumps
+}
+
+// splitRule splits a rule in `iptables -S` format into arguments, keeping
+// double-quoted arguments such as comments together.
+func splitRule(rule string) []string {
+	var (
+		args   []string
+		arg    strings.Builder
+		quoted bool
+		inArg  bool
+	)
+	for _, r := range rule {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inArg = true
+		case r == ' ' && !quoted:
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
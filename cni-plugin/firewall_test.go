@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/containernetworking/cni/pkg/types/current"
+	"github.com/linkerd/linkerd2-proxy-init/cmd"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeIptables simulates the nat table of a single network namespace, as a
+// list of rules in `iptables -S` format.
+type fakeIptables struct {
+	table    []string
+	commands []string
+}
+
+func newFakeIptables(rules ...string) *fakeIptables {
+	return &fakeIptables{
+		table: append([]string{
+			"-P PREROUTING ACCEPT",
+			"-P INPUT ACCEPT",
+			"-P OUTPUT ACCEPT",
+			"-P POSTROUTING ACCEPT",
+		}, rules...),
+	}
+}
+
+func (f *fakeIptables) rules(netns string) ([]string, error) {
+	return append([]string{}, f.table...), nil
+}
+
+func (f *fakeIptables) run(netns string, args ...string) error {
+	// iptables -S quotes comments
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if i > 0 && args[i-1] == "--comment" {
+			arg = fmt.Sprintf("%q", arg)
+		}
+		quoted[i] = arg
+	}
+	f.commands = append(f.commands, strings.Join(quoted, " "))
+
+	switch args[0] {
+	case "-N":
+		f.table = append(f.table, "-N "+args[1])
+		return nil
+	case "-A":
+		f.table = append(f.table, strings.Join(quoted, " "))
+		return nil
+	case "-D":
+		rule := "-A " + strings.Join(quoted[1:], " ")
+		return f.remove(func(r string) bool { return r == rule }, rule)
+	case "-F":
+		if !hasRule(f.table, "-N "+args[1]) {
+			return fmt.Errorf("no chain %s", args[1])
+		}
+		prefix := fmt.Sprintf("-A %s ", args[1])
+		f.remove(func(r string) bool { return strings.HasPrefix(r, prefix) }, "")
+		return nil
+	case "-X":
+		for _, r := range f.table {
+			if strings.HasPrefix(r, fmt.Sprintf("-A %s ", args[1])) || strings.HasSuffix(r, "-j "+args[1]) {
+				return fmt.Errorf("chain %s is not empty or is referenced", args[1])
+			}
+		}
+		return f.remove(func(r string) bool { return r == "-N "+args[1] }, "-N "+args[1])
+	}
+	return fmt.Errorf("unsupported command: %v", args)
+}
+
+func (f *fakeIptables) remove(match func(string) bool, rule string) error {
+	var table []string
+	removed := false
+	for _, r := range f.table {
+		if match(r) {
+			removed = true
+			continue
+		}
+		table = append(table, r)
+	}
+	f.table = table
+	if !removed && rule != "" {
+		return fmt.Errorf("no such rule: %s", rule)
+	}
+	return nil
+}
+
+// proxyInitRules are the rules proxy-init sets up in the nat table.
+var proxyInitRules = []string{
+	"-N PROXY_INIT_OUTPUT",
+	"-N PROXY_INIT_REDIRECT",
+	`-A PREROUTING -m comment --comment "proxy-init/install-proxy-init-prerouting/1565886000" -j PROXY_INIT_REDIRECT`,
+	`-A OUTPUT -m comment --comment "proxy-init/install-proxy-init-output/1565886000" -j PROXY_INIT_OUTPUT`,
+	`-A PROXY_INIT_OUTPUT -o lo -m owner --uid-owner 2102 -m comment --comment "proxy-init/ignore-proxy-user-id/1565886000" -j RETURN`,
+	`-A PROXY_INIT_OUTPUT -p tcp -m comment --comment "proxy-init/redirect-all-outgoing-to-proxy-port/1565886000" -j REDIRECT --to-ports 4140`,
+	`-A PROXY_INIT_REDIRECT -p tcp -m multiport --dports 4190,4191 -m comment --comment "proxy-init/ignore-port-4190,4191/1565886000" -j RETURN`,
+	`-A PROXY_INIT_REDIRECT -p tcp -m comment --comment "proxy-init/redirect-all-incoming-to-proxy-port/1565886000" -j REDIRECT --to-ports 4143`,
+}
+
+func TestCheckFirewall(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rules    []string
+		expected string
+	}{
+		{
+			name:  "Passes when the proxy-init rules are in place",
+			rules: proxyInitRules,
+		},
+		{
+			name:     "Fails when the proxy-init rules are missing",
+			expected: "linkerd-cni: missing iptables rules in /proc/1/ns/net: chain PROXY_INIT_REDIRECT, jump from PREROUTING to PROXY_INIT_REDIRECT, chain PROXY_INIT_OUTPUT, jump from OUTPUT to PROXY_INIT_OUTPUT",
+		},
+		{
+			name:     "Fails when a proxy-init chain is not jumped to",
+			rules:    append(append([]string{}, proxyInitRules[:3]...), proxyInitRules[4:]...),
+			expected: "linkerd-cni: missing iptables rules in /proc/1/ns/net: jump from OUTPUT to PROXY_INIT_OUTPUT",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // pin
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkFirewall(newFakeIptables(tc.rules...), "/proc/1/ns/net")
+			if tc.expected == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.expected {
+				t.Fatalf("Expected error %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestCleanupFirewall(t *testing.T) {
+	backend := newFakeIptables(append([]string{
+		`-A PREROUTING -i eth0 -j ACCEPT`,
+	}, proxyInitRules...)...)
+
+	if err := cleanupFirewall(backend, "/proc/1/ns/net"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expectedCommands := []string{
+		`-D PREROUTING -m comment --comment "proxy-init/install-proxy-init-prerouting/1565886000" -j PROXY_INIT_REDIRECT`,
+		"-F PROXY_INIT_REDIRECT",
+		"-X PROXY_INIT_REDIRECT",
+		`-D OUTPUT -m comment --comment "proxy-init/install-proxy-init-output/1565886000" -j PROXY_INIT_OUTPUT`,
+		"-F PROXY_INIT_OUTPUT",
+		"-X PROXY_INIT_OUTPUT",
+	}
+	if !reflect.DeepEqual(backend.commands, expectedCommands) {
+		t.Fatalf("Expected commands %v, got %v", expectedCommands, backend.commands)
+	}
+	expectedTable := newFakeIptables(`-A PREROUTING -i eth0 -j ACCEPT`).table
+	if !reflect.DeepEqual(backend.table, expectedTable) {
+		t.Fatalf("Expected nat table %v, got %v", expectedTable, backend.table)
+	}
+
+	// cleaning up again is a no-op
+	backend.commands = nil
+	if err := cleanupFirewall(backend, "/proc/1/ns/net"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(backend.commands) != 0 {
+		t.Fatalf("Expected no commands, got %v", backend.commands)
+	}
+}
+
+func TestSplitRule(t *testing.T) {
+	rule := `-A OUTPUT -m comment --comment "proxy-init/install proxy-init" -j PROXY_INIT_OUTPUT`
+	expected := []string{"-A", "OUTPUT", "-m", "comment", "--comment", "proxy-init/install proxy-init", "-j", "PROXY_INIT_OUTPUT"}
+	if args := splitRule(rule); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("Expected %v, got %v", expected, args)
+	}
+}
+
+func TestIP6tablesRules(t *testing.T) {
+	options := cmd.RootOptions{
+		IncomingProxyPort:     4143,
+		OutgoingProxyPort:     4140,
+		ProxyUserID:           2102,
+		InboundPortsToIgnore:  []int{4190, 4191},
+		OutboundPortsToIgnore: []int{443},
+		NetNs:                 "/proc/1/ns/net",
+	}
+
+	backend := newFakeIptables(
+		"-N PROXY_INIT_REDIRECT",
+		"-A PREROUTING -j PROXY_INIT_REDIRECT",
+	)
+	if err := configureIPv6Firewall(backend, options, logrus.WithField("test", t.Name())); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := newFakeIptables(
+		"-N PROXY_INIT_REDIRECT",
+		"-A PROXY_INIT_REDIRECT -p tcp -m multiport --dports 4190,4191 -j RETURN",
+		"-A PROXY_INIT_REDIRECT -p tcp -j REDIRECT --to-ports 4143",
+		"-A PREROUTING -j PROXY_INIT_REDIRECT",
+		"-N PROXY_INIT_OUTPUT",
+		"-A PROXY_INIT_OUTPUT -m owner --uid-owner 2102 -j RETURN",
+		"-A PROXY_INIT_OUTPUT -o lo -j RETURN",
+		"-A PROXY_INIT_OUTPUT -p tcp -m multiport --dports 443 -j RETURN",
+		"-A PROXY_INIT_OUTPUT -p tcp -j REDIRECT --to-ports 4140",
+		"-A OUTPUT -j PROXY_INIT_OUTPUT",
+	)
+	if !reflect.DeepEqual(backend.table, expected.table) {
+		t.Fatalf("Expected nat table:\n%s\ngot:\n%s", strings.Join(expected.table, "\n"), strings.Join(backend.table, "\n"))
+	}
+	if err := checkFirewall(backend, options.NetNs); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	t.Run("Redirects listed ports only", func(t *testing.T) {
+		options := options
+		options.PortsToRedirect = []int{80, 8080}
+		commands := ip6tablesRules(options)
+		expected := [][]string{
+			{"-A", "PROXY_INIT_REDIRECT", "-p", "tcp", "--dport", "80", "-j", "REDIRECT", "--to-ports", "4143"},
+			{"-A", "PROXY_INIT_REDIRECT", "-p", "tcp", "--dport", "8080", "-j", "REDIRECT", "--to-ports", "4143"},
+		}
+		if !reflect.DeepEqual(commands[2:4], expected) {
+			t.Fatalf("Expected commands %v, got %v", expected, commands[2:4])
+		}
+	})
+
+	t.Run("Logs the rules when simulating", func(t *testing.T) {
+		options := options
+		options.SimulateOnly = true
+		backend := newFakeIptables()
+		if err := configureIPv6Firewall(backend, options, logrus.WithField("test", t.Name())); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(backend.commands) != 0 {
+			t.Fatalf("Expected no commands, got %v", backend.commands)
+		}
+	})
+}
+
+func TestMultiportDestinations(t *testing.T) {
+	ports := []int{}
+	for port := 1; port <= 16; port++ {
+		ports = append(ports, port)
+	}
+	expected := []string{"1,2,3,4,5,6,7,8,9,10,11,12,13,14,15", "16"}
+	if destinations := multiportDestinations(ports); !reflect.DeepEqual(destinations, expected) {
+		t.Fatalf("Expected %v, got %v", expected, destinations)
+	}
+}
+
+func TestHasIPv6(t *testing.T) {
+	ipConfig := func(cidr string) *current.IPConfig {
+		ip, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		ipNet.IP = ip
+		return &current.IPConfig{Address: *ipNet}
+	}
+
+	testCases := []struct {
+		result   *current.Result
+		expected bool
+	}{
+		{nil, false},
+		{&current.Result{IPs: []*current.IPConfig{ipConfig("10.1.1.2/24")}}, false},
+		{&current.Result{IPs: []*current.IPConfig{ipConfig("10.1.1.2/24"), ipConfig("fd00::2/64")}}, true},
+		{&current.Result{IPs: []*current.IPConfig{{Version: "6"}}}, true},
+	}
+
+	for i, tc := range testCases {
+		if hasIPv6(tc.result) != tc.expected {
+			t.Fatalf("test case %d: expected %t", i, tc.expected)
+		}
+	}
+}
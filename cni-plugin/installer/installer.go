@@ -0,0 +1,282 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	pluginType = "linkerd-cni"
+
+	// standaloneConfName is the name of the configuration written when the
+	// node has no other CNI network configuration, as in install-cni.sh.
+	standaloneConfName = "01-linkerd-cni.conf"
+
+	// chainedCNIVersion is the cniVersion of a configuration list created from
+	// a single plugin configuration, as in install-cni.sh.
+	chainedCNIVersion = "0.3.0"
+)
+
+var (
+	pluginConfigured = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cni_plugin_configured",
+			Help: "Whether the linkerd-cni plugin is in the node's CNI network configuration: 1 if it is, 0 otherwise.",
+		},
+	)
+
+	pluginRepairs = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cni_plugin_repairs_total",
+			Help: "The number of times the linkerd-cni plugin was missing from the node's CNI network configuration and was inserted again.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(pluginConfigured, pluginRepairs)
+}
+
+// Installer keeps the linkerd-cni plugin in a node's CNI network
+// configuration. Other CNI plugins may rewrite their configuration, when
+// they're upgraded for instance, dropping linkerd-cni from the chain; the
+// installer checks the configuration periodically, and inserts the plugin
+// again when it's missing.
+type Installer struct {
+	// NetDir is the node's CNI network configuration directory
+	NetDir string
+
+	// PluginConf is the configuration of the linkerd-cni plugin
+	PluginConf map[string]interface{}
+
+	// Interval is the time between checks of the configuration
+	Interval time.Duration
+
+	// Client and NodeName, if set, are used to report the status of the
+	// configuration through the node's CNIPluginNodeCondition condition
+	Client   kubernetes.Interface
+	NodeName string
+
+	// reported is the condition reported by the latest check
+	reported *corev1.NodeCondition
+}
+
+// Run checks the configuration immediately and then every Interval, until
+// stop is closed. The configuration directory is polled rather than watched,
+// so that a rewrite is noticed even if it replaces the directory itself.
+func (i *Installer) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(i.Interval)
+	defer ticker.Stop()
+
+	for {
+		i.check()
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// check ensures the plugin is configured, and reports the result.
+func (i *Installer) check() {
+	changes, err := i.Ensure()
+	for _, change := range changes {
+		log.Infof("linkerd-cni plugin was missing from the CNI network configuration: %s", change)
+	}
+	if len(changes) > 0 {
+		pluginRepairs.Inc()
+	}
+
+	condition := corev1.NodeCondition{
+		Type:    k8s.CNIPluginNodeCondition,
+		Status:  corev1.ConditionTrue,
+		Reason:  "PluginConfigured",
+		Message: "linkerd-cni plugin is in the CNI network configuration",
+	}
+	if err != nil {
+		log.Errorf("Failed to configure the linkerd-cni plugin: %s", err)
+		pluginConfigured.Set(0)
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = "PluginNotConfigured"
+		condition.Message = err.Error()
+	} else {
+		pluginConfigured.Set(1)
+	}
+
+	if err := i.reportCondition(condition); err != nil {
+		log.Errorf("Failed to update the %s condition of node %s: %s", k8s.CNIPluginNodeCondition, i.NodeName, err)
+	}
+}
+
+// Ensure inserts the linkerd-cni plugin into the CNI network configuration
+// that the kubelet uses, which is the first in the lexicographic order of the
+// directory, if it's missing. It returns a description of each change made.
+func (i *Installer) Ensure() ([]string, error) {
+	paths, err := i.confPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []string
+	standalone := filepath.Join(i.NetDir, standaloneConfName)
+	if len(paths) > 1 && paths[0] == standalone {
+		// another CNI plugin was installed after linkerd-cni, and the
+		// standalone configuration would take precedence over it
+		if err := os.Remove(standalone); err != nil {
+			return nil, err
+		}
+		changes = append(changes, fmt.Sprintf("removed %s", standalone))
+		paths = paths[1:]
+	}
+
+	if len(paths) == 0 {
+		if err := writeConf(standalone, i.PluginConf); err != nil {
+			return changes, err
+		}
+		return append(changes, fmt.Sprintf("created %s", standalone)), nil
+	}
+
+	path := paths[0]
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return changes, err
+	}
+	var conf map[string]interface{}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return changes, fmt.Errorf("failed to parse %s: %s", path, err)
+	}
+
+	if plugins, ok := conf["plugins"].([]interface{}); ok {
+		if hasPlugin(plugins) {
+			return changes, nil
+		}
+		conf["plugins"] = append(plugins, i.PluginConf)
+		if err := writeConf(path, conf); err != nil {
+			return changes, err
+		}
+		return append(changes, fmt.Sprintf("added the plugin to %s", path)), nil
+	}
+
+	if conf["type"] == pluginType {
+		return changes, nil
+	}
+
+	// convert a single plugin configuration into a list, as in
+	// install-cni.sh
+	delete(conf, "cniVersion")
+	list := map[string]interface{}{
+		"name":       "k8s-pod-network",
+		"cniVersion": chainedCNIVersion,
+		"plugins":    []interface{}{conf, i.PluginConf},
+	}
+	listPath := path
+	if strings.HasSuffix(path, ".conf") {
+		listPath = path + "list"
+	}
+	if err := writeConf(listPath, list); err != nil {
+		return changes, err
+	}
+	if listPath != path {
+		if err := os.Remove(path); err != nil {
+			return changes, err
+		}
+	}
+	return append(changes, fmt.Sprintf("replaced %s with %s, adding the plugin", path, listPath)), nil
+}
+
+// confPaths returns the paths of the CNI network configurations, in
+// lexicographic order.
+func (i *Installer) confPaths() ([]string, error) {
+	files, err := ioutil.ReadDir(i.NetDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, file := range files {
+		ext := strings.ToLower(filepath.Ext(file.Name()))
+		if file.Mode().IsRegular() && (ext == ".conf" || ext == ".conflist") {
+			paths = append(paths, filepath.Join(i.NetDir, file.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func hasPlugin(plugins []interface{}) bool {
+	for _, plugin := range plugins {
+		if p, ok := plugin.(map[string]interface{}); ok && p["type"] == pluginType {
+			return true
+		}
+	}
+	return false
+}
+
+// writeConf writes conf to path through a temporary file, so that the kubelet
+// never reads a partial configuration.
+func writeConf(path string, conf map[string]interface{}) error {
+	data, err := json.MarshalIndent(conf, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// reportCondition sets the node's condition, if it changed since the latest
+// check.
+func (i *Installer) reportCondition(condition corev1.NodeCondition) error {
+	if i.Client == nil || i.NodeName == "" {
+		return nil
+	}
+	if i.reported != nil && i.reported.Status == condition.Status && i.reported.Message == condition.Message {
+		return nil
+	}
+
+	node, err := i.Client.CoreV1().Nodes().Get(i.NodeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	now := metav1.Now()
+	condition.LastHeartbeatTime = now
+	condition.LastTransitionTime = now
+	found := false
+	for j, c := range node.Status.Conditions {
+		if c.Type != condition.Type {
+			continue
+		}
+		if c.Status == condition.Status {
+			condition.LastTransitionTime = c.LastTransitionTime
+		}
+		node.Status.Conditions[j] = condition
+		found = true
+	}
+	if !found {
+		node.Status.Conditions = append(node.Status.Conditions, condition)
+	}
+
+	if _, err := i.Client.CoreV1().Nodes().UpdateStatus(node); err != nil {
+		return err
+	}
+	i.reported = &condition
+	return nil
+}
@@ -0,0 +1,211 @@
+package installer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const calicoConflist = `{
+  "name": "k8s-pod-network",
+  "cniVersion": "0.3.0",
+  "plugins": [
+    {
+      "type": "calico",
+      "ipam": {
+        "type": "calico-ipam"
+      }
+    },
+    {
+      "type": "portmap",
+      "snat": true
+    }
+  ]
+}`
+
+const hostLocalConf = `{
+  "cniVersion": "0.3.1",
+  "name": "mynet",
+  "type": "bridge",
+  "bridge": "cni0"
+}`
+
+func newInstaller(t *testing.T, files map[string]string) *Installer {
+	dir, err := ioutil.TempDir("", "linkerd-cni-installer")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	return &Installer{
+		NetDir: dir,
+		PluginConf: map[string]interface{}{
+			"name": "linkerd-cni",
+			"type": "linkerd-cni",
+		},
+	}
+}
+
+func readConf(t *testing.T, path string) map[string]interface{} {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	var conf map[string]interface{}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	return conf
+}
+
+func pluginTypes(conf map[string]interface{}) []string {
+	var types []string
+	for _, plugin := range conf["plugins"].([]interface{}) {
+		types = append(types, plugin.(map[string]interface{})["type"].(string))
+	}
+	return types
+}
+
+func TestEnsure(t *testing.T) {
+	t.Run("Inserts the plugin again when it's removed from a conflist", func(t *testing.T) {
+		i := newInstaller(t, map[string]string{
+			"10-calico.conflist":  calicoConflist,
+			"20-other.conflist":   calicoConflist,
+			"calico-kubeconfig":   "not a CNI config",
+			"99-ignored.conf.bak": "not a CNI config",
+		})
+		defer os.RemoveAll(i.NetDir)
+
+		changes, err := i.Ensure()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		path := filepath.Join(i.NetDir, "10-calico.conflist")
+		if !reflect.DeepEqual(changes, []string{"added the plugin to " + path}) {
+			t.Fatalf("Unexpected changes: %v", changes)
+		}
+		conf := readConf(t, path)
+		if types := pluginTypes(conf); !reflect.DeepEqual(types, []string{"calico", "portmap", "linkerd-cni"}) {
+			t.Fatalf("Unexpected plugins: %v", types)
+		}
+		if conf["name"] != "k8s-pod-network" {
+			t.Fatalf("Unexpected name: %v", conf["name"])
+		}
+		if types := pluginTypes(readConf(t, filepath.Join(i.NetDir, "20-other.conflist"))); len(types) != 2 {
+			t.Fatalf("Expected only the first conflist to be changed, got plugins %v", types)
+		}
+
+		// the plugin is only inserted once
+		changes, err = i.Ensure()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(changes) != 0 {
+			t.Fatalf("Unexpected changes: %v", changes)
+		}
+	})
+
+	t.Run("Converts a single plugin conf into a conflist", func(t *testing.T) {
+		i := newInstaller(t, map[string]string{"10-host-local.conf": hostLocalConf})
+		defer os.RemoveAll(i.NetDir)
+
+		changes, err := i.Ensure()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(changes) != 1 {
+			t.Fatalf("Unexpected changes: %v", changes)
+		}
+		if _, err := os.Stat(filepath.Join(i.NetDir, "10-host-local.conf")); !os.IsNotExist(err) {
+			t.Fatalf("Expected the conf to be replaced, got %v", err)
+		}
+		conf := readConf(t, filepath.Join(i.NetDir, "10-host-local.conflist"))
+		if types := pluginTypes(conf); !reflect.DeepEqual(types, []string{"bridge", "linkerd-cni"}) {
+			t.Fatalf("Unexpected plugins: %v", types)
+		}
+		if conf["cniVersion"] != chainedCNIVersion {
+			t.Fatalf("Unexpected cniVersion: %v", conf["cniVersion"])
+		}
+	})
+
+	t.Run("Writes a standalone conf when there are no other confs", func(t *testing.T) {
+		i := newInstaller(t, nil)
+		defer os.RemoveAll(i.NetDir)
+
+		if _, err := i.Ensure(); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		conf := readConf(t, filepath.Join(i.NetDir, standaloneConfName))
+		if conf["type"] != "linkerd-cni" {
+			t.Fatalf("Unexpected conf: %v", conf)
+		}
+
+		// another CNI plugin is installed later
+		if err := ioutil.WriteFile(filepath.Join(i.NetDir, "10-calico.conflist"), []byte(calicoConflist), 0644); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		changes, err := i.Ensure()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(changes) != 2 || !strings.HasPrefix(changes[0], "removed") {
+			t.Fatalf("Unexpected changes: %v", changes)
+		}
+		if types := pluginTypes(readConf(t, filepath.Join(i.NetDir, "10-calico.conflist"))); !reflect.DeepEqual(types, []string{"calico", "portmap", "linkerd-cni"}) {
+			t.Fatalf("Unexpected plugins: %v", types)
+		}
+	})
+
+	t.Run("Fails on invalid confs", func(t *testing.T) {
+		i := newInstaller(t, map[string]string{"10-calico.conflist": "{"})
+		defer os.RemoveAll(i.NetDir)
+
+		if _, err := i.Ensure(); err == nil {
+			t.Fatal("Expected error, got nothing")
+		}
+	})
+}
+
+func TestCheck(t *testing.T) {
+	i := newInstaller(t, map[string]string{"10-calico.conflist": calicoConflist})
+	defer os.RemoveAll(i.NetDir)
+	i.NodeName = "node1"
+	client := fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}})
+	i.Client = client
+
+	condition := func() corev1.NodeCondition {
+		node, err := client.CoreV1().Nodes().Get("node1", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(node.Status.Conditions) != 1 || node.Status.Conditions[0].Type != k8s.CNIPluginNodeCondition {
+			t.Fatalf("Unexpected conditions: %v", node.Status.Conditions)
+		}
+		return node.Status.Conditions[0]
+	}
+
+	i.check()
+	if c := condition(); c.Status != corev1.ConditionTrue {
+		t.Fatalf("Unexpected condition: %v", c)
+	}
+
+	if err := os.RemoveAll(i.NetDir); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	i.check()
+	if c := condition(); c.Status != corev1.ConditionFalse || c.Reason != "PluginNotConfigured" {
+		t.Fatalf("Unexpected condition: %v", c)
+	}
+}
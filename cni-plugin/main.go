@@ -22,6 +22,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/containernetworking/cni/pkg/skel"
@@ -33,6 +34,7 @@ import (
 	"github.com/linkerd/linkerd2/pkg/k8s"
 	"github.com/projectcalico/libcalico-go/lib/logutils"
 	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -45,6 +47,12 @@ type ProxyInit struct {
 	InboundPortsToIgnore  []int `json:"inbound-ports-to-ignore"`
 	OutboundPortsToIgnore []int `json:"outbound-ports-to-ignore"`
 	Simulate              bool  `json:"simulate"`
+	// IPv6 enables ip6tables rules for pods with IPv6 addresses, so that their
+	// IPv6 traffic is redirected to the proxy too. It is only set by
+	// `linkerd install-cni --ipv6`: the linkerd2-proxy-init image has no
+	// ip6tables support, so `linkerd install` has no equivalent setting until
+	// it does.
+	IPv6 bool `json:"ipv6"`
 }
 
 // Kubernetes a K8s specific struct to hold config
@@ -80,7 +88,7 @@ func main() {
 	logrus.SetFormatter(&logutils.Formatter{})
 	// Install a hook that adds file/line no information.
 	logrus.AddHook(&logutils.ContextHook{})
-	skel.PluginMain(cmdAdd, cmdDel, version.All)
+	skel.PluginMain(cmdAdd, cmdCheck, cmdDel, version.All, "linkerd-cni")
 }
 
 func configureLogging(logLevel string) {
@@ -147,12 +155,82 @@ func cmdAdd(args *skel.CmdArgs) error {
 		}).Debug("linkerd-cni: cmdAdd, config parsed")
 	}
 
+	pod, logEntry, err := podWithoutInitContainer(conf, args)
+	if err != nil {
+		return err
+	}
+	if pod != nil {
+		logEntry.Debug("linkerd-cni: setting up iptables firewall")
+		options := podRootOptions(conf.ProxyInit, pod, args.Netns, logEntry)
+		firewallConfiguration, err := cmd.BuildFirewallConfiguration(&options)
+		if err != nil {
+			logEntry.Errorf("linkerd-cni: could not create a Firewall Configuration from the options: %v", options)
+			return err
+		}
+		iptables.ConfigureFirewall(*firewallConfiguration)
+
+		if conf.ProxyInit.IPv6 && hasIPv6(conf.PrevResult) {
+			logEntry.Debug("linkerd-cni: setting up ip6tables firewall")
+			if err := configureIPv6Firewall(iptablesBackendV6, options, logEntry); err != nil {
+				return err
+			}
+		}
+	}
+
+	logrus.Debug("linkerd-cni: plugin is finished")
+	if conf.PrevResult != nil {
+		// Pass through the prevResult for the next plugin
+		return types.PrintResult(conf.PrevResult, conf.CNIVersion)
+	}
+
+	logrus.Debug("linkerd-cni: no previous result to pass through, emptying stdout")
+	return nil
+}
+
+// cmdCheck is called by the CNI runtime for CHECK requests, to verify that
+// the iptables rules set up by cmdAdd are still in place
+func cmdCheck(args *skel.CmdArgs) error {
+	logrus.Debug("linkerd-cni: cmdCheck, parsing config")
+	conf, err := parseConfig(args.StdinData)
+	if err != nil {
+		return err
+	}
+	configureLogging(conf.LogLevel)
+
+	pod, logEntry, err := podWithoutInitContainer(conf, args)
+	if err != nil {
+		return err
+	}
+	if pod == nil {
+		return nil
+	}
+	if conf.ProxyInit.Simulate {
+		logEntry.Debug("linkerd-cni: iptables firewall is simulated, skipping.")
+		return nil
+	}
+
+	logEntry.Debug("linkerd-cni: checking iptables firewall")
+	if err := checkFirewall(iptablesBackendV4, args.Netns); err != nil {
+		return err
+	}
+	if conf.ProxyInit.IPv6 && hasIPv6(conf.PrevResult) {
+		logEntry.Debug("linkerd-cni: checking ip6tables firewall")
+		return checkFirewall(iptablesBackendV6, args.Netns)
+	}
+	return nil
+}
+
+// podWithoutInitContainer returns the pod described by the CNI args if the
+// proxy was injected into it without the proxy-init container, so that its
+// iptables rules are the plugin's responsibility; it returns nil otherwise.
+// The returned log entry describes the pod.
+func podWithoutInitContainer(conf *PluginConf, args *skel.CmdArgs) (*corev1.Pod, *logrus.Entry, error) {
 	// Determine if running under k8s by checking the CNI args
 	k8sArgs := K8sArgs{}
 	args.Args = strings.Replace(args.Args, "K8S_POD_NAMESPACE", "K8sPodNamespace", 1)
 	args.Args = strings.Replace(args.Args, "K8S_POD_NAME", "K8sPodName", 1)
 	if err := types.LoadArgs(args.Args, &k8sArgs); err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	namespace := string(k8sArgs.K8sPodNamespace)
@@ -163,74 +241,195 @@ func cmdAdd(args *skel.CmdArgs) error {
 		"Namespace":   namespace,
 	})
 
-	if namespace != "" && podName != "" {
-		client, err := k8s.NewAPI(conf.Kubernetes.Kubeconfig, "linkerd-cni-context", 0)
-		if err != nil {
-			return err
+	if namespace == "" || podName == "" {
+		logEntry.Debug("linkerd-cni: no Kubernetes namespace or pod name found, skipping.")
+		return nil, logEntry, nil
+	}
+
+	client, err := k8s.NewAPI(conf.Kubernetes.Kubeconfig, "linkerd-cni-context", 0)
+	if err != nil {
+		return nil, logEntry, err
+	}
+
+	pod, err := client.CoreV1().Pods(namespace).Get(podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, logEntry, err
+	}
+
+	containsLinkerdProxy := false
+	for _, container := range pod.Spec.Containers {
+		if container.Name == k8s.ProxyContainerName {
+			containsLinkerdProxy = true
+			break
 		}
+	}
 
-		pod, err := client.CoreV1().Pods(namespace).Get(podName, metav1.GetOptions{})
-		if err != nil {
-			return err
+	containsInitContainer := false
+	for _, container := range pod.Spec.InitContainers {
+		if container.Name == k8s.InitContainerName {
+			containsInitContainer = true
+			break
 		}
+	}
+
+	if containsInitContainer {
+		logEntry.Debug("linkerd-cni: linkerd-init initContainer is present, skipping.")
+		return nil, logEntry, nil
+	}
+	if !containsLinkerdProxy {
+		logEntry.Debug("linkerd-cni: linkerd-proxy is not present, skipping.")
+		return nil, logEntry, nil
+	}
+	return pod, logEntry, nil
+}
+
+// podRootOptions returns the proxy-init options for a pod, starting from the
+// node's ProxyInit config and applying the pod's proxy config annotations,
+// the same way `linkerd inject` does for the proxy-init container. Invalid
+// annotations are logged and ignored.
+func podRootOptions(proxyInit ProxyInit, pod *corev1.Pod, netns string, logEntry *logrus.Entry) cmd.RootOptions {
+	options := cmd.RootOptions{
+		IncomingProxyPort:     proxyInit.IncomingProxyPort,
+		OutgoingProxyPort:     proxyInit.OutgoingProxyPort,
+		ProxyUserID:           proxyInit.ProxyUID,
+		PortsToRedirect:       proxyInit.PortsToRedirect,
+		InboundPortsToIgnore:  proxyInit.InboundPortsToIgnore,
+		OutboundPortsToIgnore: proxyInit.OutboundPortsToIgnore,
+		SimulateOnly:          proxyInit.Simulate,
+		NetNs:                 netns,
+	}
 
-		containsLinkerdProxy := false
-		for _, container := range pod.Spec.Containers {
-			if container.Name == k8s.ProxyContainerName {
-				containsLinkerdProxy = true
-				break
+	annotationPort := func(annotation string, port *int) {
+		if override, ok := pod.Annotations[annotation]; ok {
+			if p, err := parsePort(override); err == nil {
+				*port = p
+			} else {
+				logEntry.Warnf("linkerd-cni: ignoring invalid %s annotation: %s", annotation, err)
 			}
 		}
+	}
+	annotationPort(k8s.ProxyInboundPortAnnotation, &options.IncomingProxyPort)
+	annotationPort(k8s.ProxyOutboundPortAnnotation, &options.OutgoingProxyPort)
 
-		containsInitContainer := false
-		for _, container := range pod.Spec.InitContainers {
-			if container.Name == k8s.InitContainerName {
-				containsInitContainer = true
-				break
-			}
+	if override, ok := pod.Annotations[k8s.ProxyUIDAnnotation]; ok {
+		if uid, err := strconv.Atoi(override); err == nil {
+			options.ProxyUserID = uid
+		} else {
+			logEntry.Warnf("linkerd-cni: ignoring invalid %s annotation: %s", k8s.ProxyUIDAnnotation, err)
 		}
+	}
 
-		if containsLinkerdProxy && !containsInitContainer {
-			logEntry.Debug("linkerd-cni: setting up iptables firewall")
-			options := cmd.RootOptions{
-				IncomingProxyPort:     conf.ProxyInit.IncomingProxyPort,
-				OutgoingProxyPort:     conf.ProxyInit.OutgoingProxyPort,
-				ProxyUserID:           conf.ProxyInit.ProxyUID,
-				PortsToRedirect:       conf.ProxyInit.PortsToRedirect,
-				InboundPortsToIgnore:  conf.ProxyInit.InboundPortsToIgnore,
-				OutboundPortsToIgnore: conf.ProxyInit.OutboundPortsToIgnore,
-				SimulateOnly:          conf.ProxyInit.Simulate,
-				NetNs:                 args.Netns,
-			}
-			firewallConfiguration, err := cmd.BuildFirewallConfiguration(&options)
-			if err != nil {
-				logEntry.Errorf("linkerd-cni: could not create a Firewall Configuration from the options: %v", options)
-				return err
-			}
-			iptables.ConfigureFirewall(*firewallConfiguration)
+	// The node's inbound ports to ignore include the proxy's default control
+	// and admin ports, so the pod's ports are added to them instead of
+	// replacing them.
+	var inboundPortsToIgnore []int
+	for _, annotation := range []string{k8s.ProxyControlPortAnnotation, k8s.ProxyAdminPortAnnotation} {
+		var port int
+		annotationPort(annotation, &port)
+		if port != 0 {
+			inboundPortsToIgnore = append(inboundPortsToIgnore, port)
+		}
+	}
+	if override, ok := pod.Annotations[k8s.ProxyIgnoreInboundPortsAnnotation]; ok {
+		if ports, err := parsePorts(override); err == nil {
+			inboundPortsToIgnore = append(inboundPortsToIgnore, ports...)
 		} else {
-			if containsInitContainer {
-				logEntry.Debug("linkerd-cni: linkerd-init initContainer is present, skipping.")
-			} else {
-				logEntry.Debug("linkerd-cni: linkerd-proxy is not present, skipping.")
-			}
+			logEntry.Warnf("linkerd-cni: ignoring invalid %s annotation: %s", k8s.ProxyIgnoreInboundPortsAnnotation, err)
 		}
-	} else {
-		logEntry.Debug("linkerd-cni: no Kubernetes namespace or pod name found, skipping.")
+	}
+	if len(inboundPortsToIgnore) > 0 {
+		options.InboundPortsToIgnore = mergePorts(proxyInit.InboundPortsToIgnore, inboundPortsToIgnore)
 	}
 
-	logrus.Debug("linkerd-cni: plugin is finished")
-	if conf.PrevResult != nil {
-		// Pass through the prevResult for the next plugin
-		return types.PrintResult(conf.PrevResult, conf.CNIVersion)
+	if override, ok := pod.Annotations[k8s.ProxyIgnoreOutboundPortsAnnotation]; ok {
+		if ports, err := parsePorts(override); err == nil {
+			options.OutboundPortsToIgnore = ports
+		} else {
+			logEntry.Warnf("linkerd-cni: ignoring invalid %s annotation: %s", k8s.ProxyIgnoreOutboundPortsAnnotation, err)
+		}
 	}
 
-	logrus.Debug("linkerd-cni: no previous result to pass through, emptying stdout")
-	return nil
+	return options
 }
 
-// cmdDel is called for DELETE requests
+func parsePort(port string) (int, error) {
+	p, err := strconv.Atoi(strings.TrimSpace(port))
+	if err != nil {
+		return 0, err
+	}
+	if p <= 0 || p > 65535 {
+		return 0, fmt.Errorf("port %d out of range", p)
+	}
+	return p, nil
+}
+
+// parsePorts parses a comma-separated list of ports, as used by the
+// skip-inbound-ports and skip-outbound-ports annotations.
+func parsePorts(ports string) ([]int, error) {
+	parsed := []int{}
+	for _, port := range strings.Split(ports, ",") {
+		if strings.TrimSpace(port) == "" {
+			continue
+		}
+		p, err := parsePort(port)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, p)
+	}
+	return parsed, nil
+}
+
+// mergePorts returns the ports in a followed by the ports in b that aren't in
+// a.
+func mergePorts(a, b []int) []int {
+	merged := append([]int{}, a...)
+	seen := make(map[int]struct{})
+	for _, port := range a {
+		seen[port] = struct{}{}
+	}
+	for _, port := range b {
+		if _, ok := seen[port]; !ok {
+			seen[port] = struct{}{}
+			merged = append(merged, port)
+		}
+	}
+	return merged
+}
+
+// cmdDel is called for DELETE requests. The pod may already be gone, so the
+// proxy-init chains are removed from any network namespace that has them;
+// cmdDel can be called more than once for the same container, and succeeds
+// if the network namespace no longer exists.
 func cmdDel(args *skel.CmdArgs) error {
-	logrus.Debug("linkerd-cni: cmdDel not implemented")
+	logrus.Debug("linkerd-cni: cmdDel, parsing config")
+	conf, err := parseConfig(args.StdinData)
+	if err != nil {
+		return err
+	}
+	configureLogging(conf.LogLevel)
+
+	logEntry := logrus.WithField("ContainerID", args.ContainerID)
+	if conf.ProxyInit.Simulate {
+		logEntry.Debug("linkerd-cni: iptables firewall is simulated, skipping.")
+		return nil
+	}
+	if args.Netns == "" {
+		logEntry.Debug("linkerd-cni: no network namespace, skipping.")
+		return nil
+	}
+	if _, err := os.Stat(args.Netns); os.IsNotExist(err) {
+		logEntry.Debug("linkerd-cni: network namespace is gone, skipping.")
+		return nil
+	}
+
+	logEntry.Debug("linkerd-cni: cleaning up iptables firewall")
+	if err := cleanupFirewall(iptablesBackendV4, args.Netns); err != nil {
+		return err
+	}
+	if conf.ProxyInit.IPv6 {
+		logEntry.Debug("linkerd-cni: cleaning up ip6tables firewall")
+		return cleanupFirewall(iptablesBackendV6, args.Netns)
+	}
 	return nil
 }
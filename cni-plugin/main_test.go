@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/linkerd/linkerd2-proxy-init/cmd"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// readPluginConf reads the linkerd-cni plugin configuration from a conf or
+// conflist fixture.
+func readPluginConf(t *testing.T, fixture string) *PluginConf {
+	data, err := ioutil.ReadFile(fixture)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var conflist struct {
+		Plugins []json.RawMessage `json:"plugins"`
+	}
+	if err := json.Unmarshal(data, &conflist); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(conflist.Plugins) > 0 {
+		data = conflist.Plugins[len(conflist.Plugins)-1]
+	}
+
+	conf, err := parseConfig(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if conf.Type != "linkerd-cni" {
+		t.Fatalf("Expected a linkerd-cni plugin in %s, got %s", fixture, conf.Type)
+	}
+	return conf
+}
+
+func TestPodRootOptions(t *testing.T) {
+	logEntry := logrus.WithField("test", t.Name())
+
+	testCases := []struct {
+		name        string
+		fixture     string
+		proxyInit   func(*ProxyInit)
+		annotations map[string]string
+		expected    cmd.RootOptions
+	}{
+		{
+			name:    "Uses the node config without annotations",
+			fixture: "test/data/expected/01-linkerd-cni.conf-1",
+			expected: cmd.RootOptions{
+				IncomingProxyPort:     -1,
+				OutgoingProxyPort:     -1,
+				ProxyUserID:           -1,
+				PortsToRedirect:       []int{},
+				InboundPortsToIgnore:  []int{},
+				OutboundPortsToIgnore: []int{},
+				NetNs:                 "/proc/1/ns/net",
+			},
+		},
+		{
+			name:    "Overrides the node config with annotations",
+			fixture: "test/data/expected/10-calico.conflist-1",
+			annotations: map[string]string{
+				k8s.ProxyInboundPortAnnotation:         "5143",
+				k8s.ProxyOutboundPortAnnotation:        "5140",
+				k8s.ProxyUIDAnnotation:                 "2102",
+				k8s.ProxyIgnoreInboundPortsAnnotation:  "22, 3306",
+				k8s.ProxyIgnoreOutboundPortsAnnotation: "443,8443",
+			},
+			expected: cmd.RootOptions{
+				IncomingProxyPort:     5143,
+				OutgoingProxyPort:     5140,
+				ProxyUserID:           2102,
+				PortsToRedirect:       []int{},
+				InboundPortsToIgnore:  []int{22, 3306},
+				OutboundPortsToIgnore: []int{443, 8443},
+				NetNs:                 "/proc/1/ns/net",
+			},
+		},
+		{
+			name:    "Keeps the node's inbound ports to ignore",
+			fixture: "test/data/expected/10-calico.conflist-1",
+			proxyInit: func(proxyInit *ProxyInit) {
+				proxyInit.InboundPortsToIgnore = []int{4190, 4191}
+				proxyInit.OutboundPortsToIgnore = []int{25}
+			},
+			annotations: map[string]string{
+				k8s.ProxyControlPortAnnotation:        "5190",
+				k8s.ProxyAdminPortAnnotation:          "4191",
+				k8s.ProxyIgnoreInboundPortsAnnotation: "22",
+			},
+			expected: cmd.RootOptions{
+				IncomingProxyPort:     -1,
+				OutgoingProxyPort:     -1,
+				ProxyUserID:           -1,
+				PortsToRedirect:       []int{},
+				InboundPortsToIgnore:  []int{4190, 4191, 5190, 22},
+				OutboundPortsToIgnore: []int{25},
+				NetNs:                 "/proc/1/ns/net",
+			},
+		},
+		{
+			name:    "Ignores invalid annotations",
+			fixture: "test/data/expected/10-calico.conflist-1",
+			annotations: map[string]string{
+				k8s.ProxyInboundPortAnnotation:         "70000",
+				k8s.ProxyUIDAnnotation:                 "proxy",
+				k8s.ProxyIgnoreInboundPortsAnnotation:  "22,ssh",
+				k8s.ProxyIgnoreOutboundPortsAnnotation: "-1",
+			},
+			expected: cmd.RootOptions{
+				IncomingProxyPort:     -1,
+				OutgoingProxyPort:     -1,
+				ProxyUserID:           -1,
+				PortsToRedirect:       []int{},
+				InboundPortsToIgnore:  []int{},
+				OutboundPortsToIgnore: []int{},
+				NetNs:                 "/proc/1/ns/net",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // pin
+		t.Run(tc.name, func(t *testing.T) {
+			conf := readPluginConf(t, tc.fixture)
+			if tc.proxyInit != nil {
+				tc.proxyInit(&conf.ProxyInit)
+			}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "pod",
+					Namespace:   "ns",
+					Annotations: tc.annotations,
+				},
+			}
+
+			options := podRootOptions(conf.ProxyInit, pod, "/proc/1/ns/net", logEntry)
+			if !reflect.DeepEqual(options, tc.expected) {
+				t.Fatalf("Expected options %+v, got %+v", tc.expected, options)
+			}
+			if _, err := cmd.BuildFirewallConfiguration(&options); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+		})
+	}
+}
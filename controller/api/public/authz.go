@@ -0,0 +1,84 @@
+package public
+
+import (
+	"net/http"
+
+	pb "github.com/linkerd/linkerd2/controller/gen/public"
+	pkgK8s "github.com/linkerd/linkerd2/pkg/k8s"
+)
+
+// tapAPIGroup is the API group of the RBAC rules that allow users to tap
+// resources, e.g.:
+//
+//   - apiGroups: ["tap.linkerd.io"]
+//     resources: ["deployments"]
+//     verbs: ["watch"]
+const tapAPIGroup = "tap.linkerd.io"
+
+// resourceAttributes describes the access to Kubernetes resources that a
+// request requires.
+type resourceAttributes struct {
+	verb      string
+	group     string
+	resource  string
+	namespace string
+	name      string
+}
+
+// authorize checks that the user on whose behalf the request is made, if any,
+// is allowed the access described by attrs.
+func (h *handler) authorize(req *http.Request, attrs resourceAttributes) error {
+	user, ok := userFromHeaders(req)
+	if !ok {
+		return nil
+	}
+
+	err := pkgK8s.UserResourceAuthz(
+		h.k8sClient,
+		user.Name, user.Groups,
+		attrs.namespace, attrs.verb, attrs.group, "", attrs.resource, attrs.name,
+	)
+	if err != nil {
+		return httpError{Code: http.StatusForbidden, WrappedError: err}
+	}
+	return nil
+}
+
+// selectionAttributes returns the access required to read the metrics of the
+// selected resources, which is to list the pods they're made of.
+func selectionAttributes(selection *pb.ResourceSelection) resourceAttributes {
+	resource := selection.GetResource()
+	namespace := resource.GetNamespace()
+	if resource.GetType() == pkgK8s.Namespace && resource.GetName() != "" {
+		namespace = resource.GetName()
+	}
+
+	return resourceAttributes{
+		verb:      "list",
+		resource:  "pods",
+		namespace: namespace,
+	}
+}
+
+// tapAttributes returns the access required to tap the targeted resource.
+func tapAttributes(target *pb.ResourceSelection) resourceAttributes {
+	resource := target.GetResource()
+	attrs := resourceAttributes{
+		verb:      "watch",
+		group:     tapAPIGroup,
+		resource:  pluralResourceType(resource.GetType()),
+		namespace: resource.GetNamespace(),
+		name:      resource.GetName(),
+	}
+	if resource.GetType() == pkgK8s.Namespace {
+		attrs.namespace = resource.GetName()
+	}
+	return attrs
+}
+
+func pluralResourceType(resourceType string) string {
+	if resourceType == pkgK8s.Authority {
+		return "authorities"
+	}
+	return resourceType + "s"
+}
@@ -157,6 +157,7 @@ func (c *grpcOverHTTPClient) post(ctx context.Context, url *url.URL, req proto.M
 	if err != nil {
 		return nil, err
 	}
+	setUserHeaders(ctx, httpReq)
 
 	rsp, err := c.httpClient.Do(httpReq.WithContext(ctx))
 	if err != nil {
@@ -33,6 +33,12 @@ type APIServer interface {
 	discoveryPb.DiscoveryServer
 }
 
+// HealthChecks provides the results of the health checks that the control
+// plane runs periodically, which are included in SelfCheck responses.
+type HealthChecks interface {
+	SelfCheckResults() []*healthcheckPb.CheckResult
+}
+
 type grpcServer struct {
 	prometheusAPI         promv1.API
 	tapClient             tapPb.TapClient
@@ -42,6 +48,7 @@ type grpcServer struct {
 	ignoredNamespaces     []string
 	mountPathGlobalConfig string
 	mountPathProxyConfig  string
+	healthChecks          HealthChecks
 }
 
 type podReport struct {
@@ -223,6 +230,9 @@ func (s *grpcServer) SelfCheck(ctx context.Context, in *healthcheckPb.SelfCheckR
 			promClientCheck,
 		},
 	}
+	if s.healthChecks != nil {
+		response.Results = append(response.Results, s.healthChecks.SelfCheckResults()...)
+	}
 	return response, nil
 }
 
@@ -11,6 +11,7 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/duration"
 	"github.com/linkerd/linkerd2/controller/api/discovery"
+	healthcheckPb "github.com/linkerd/linkerd2/controller/gen/common/healthcheck"
 	discoveryPb "github.com/linkerd/linkerd2/controller/gen/controller/discovery"
 	pb "github.com/linkerd/linkerd2/controller/gen/public"
 	"github.com/linkerd/linkerd2/controller/k8s"
@@ -612,3 +613,43 @@ func TestConfig(t *testing.T) {
 		}
 	})
 }
+
+type mockHealthChecks []*healthcheckPb.CheckResult
+
+func (m mockHealthChecks) SelfCheckResults() []*healthcheckPb.CheckResult {
+	return m
+}
+
+func TestSelfCheck(t *testing.T) {
+	_, fakeGrpcServer, err := newMockGrpcServer(expectedStatRPC{
+		mockPromResponse: model.Vector{},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	healthCheck := &healthcheckPb.CheckResult{
+		SubsystemName:         "linkerd-config",
+		CheckDescription:      "control plane Namespace exists",
+		Status:                healthcheckPb.CheckStatus_FAIL,
+		FriendlyMessageToUser: "The \"linkerd\" namespace does not exist",
+	}
+	fakeGrpcServer.healthChecks = mockHealthChecks{healthCheck}
+
+	rsp, err := fakeGrpcServer.SelfCheck(context.Background(), &healthcheckPb.SelfCheckRequest{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(rsp.Results) != 3 {
+		t.Fatalf("Expected 3 results, got %d: %v", len(rsp.Results), rsp.Results)
+	}
+	for _, result := range rsp.Results[:2] {
+		if result.Status != healthcheckPb.CheckStatus_OK {
+			t.Fatalf("Expected %s to pass, got %s", result.CheckDescription, result.FriendlyMessageToUser)
+		}
+	}
+	if !proto.Equal(rsp.Results[2], healthCheck) {
+		t.Fatalf("Expected %v, got %v", healthCheck, rsp.Results[2])
+	}
+}
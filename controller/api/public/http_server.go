@@ -15,6 +15,7 @@ import (
 	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc/metadata"
+	"k8s.io/client-go/kubernetes"
 )
 
 var (
@@ -32,6 +33,7 @@ var (
 
 type handler struct {
 	grpcServer APIServer
+	k8sClient  kubernetes.Interface
 }
 
 func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
@@ -81,6 +83,11 @@ func (h *handler) handleStatSummary(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if err := h.authorize(req, selectionAttributes(protoRequest.GetSelector())); err != nil {
+		writeErrorToHTTPResponse(w, err)
+		return
+	}
+
 	rsp, err := h.grpcServer.StatSummary(req.Context(), &protoRequest)
 	if err != nil {
 		writeErrorToHTTPResponse(w, err)
@@ -102,6 +109,11 @@ func (h *handler) handleEdges(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if err := h.authorize(req, selectionAttributes(protoRequest.GetSelector())); err != nil {
+		writeErrorToHTTPResponse(w, err)
+		return
+	}
+
 	rsp, err := h.grpcServer.Edges(req.Context(), &protoRequest)
 	if err != nil {
 		writeErrorToHTTPResponse(w, err)
@@ -123,6 +135,11 @@ func (h *handler) handleTopRoutes(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if err := h.authorize(req, selectionAttributes(protoRequest.GetSelector())); err != nil {
+		writeErrorToHTTPResponse(w, err)
+		return
+	}
+
 	rsp, err := h.grpcServer.TopRoutes(req.Context(), &protoRequest)
 	if err != nil {
 		writeErrorToHTTPResponse(w, err)
@@ -185,6 +202,15 @@ func (h *handler) handleListPods(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	attrs := selectionAttributes(protoRequest.GetSelector())
+	if namespace := protoRequest.GetNamespace(); namespace != "" {
+		attrs.namespace = namespace
+	}
+	if err := h.authorize(req, attrs); err != nil {
+		writeErrorToHTTPResponse(w, err)
+		return
+	}
+
 	rsp, err := h.grpcServer.ListPods(req.Context(), &protoRequest)
 	if err != nil {
 		writeErrorToHTTPResponse(w, err)
@@ -207,6 +233,11 @@ func (h *handler) handleListServices(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if err := h.authorize(req, resourceAttributes{verb: "list", resource: "services", namespace: protoRequest.GetNamespace()}); err != nil {
+		writeErrorToHTTPResponse(w, err)
+		return
+	}
+
 	rsp, err := h.grpcServer.ListServices(req.Context(), &protoRequest)
 	if err != nil {
 		writeErrorToHTTPResponse(w, err)
@@ -234,6 +265,11 @@ func (h *handler) handleTapByResource(w http.ResponseWriter, req *http.Request)
 		return
 	}
 
+	if err := h.authorize(req, tapAttributes(protoRequest.GetTarget())); err != nil {
+		writeErrorToHTTPResponse(w, err)
+		return
+	}
+
 	server := tapServer{w: flushableWriter, req: req}
 	err = h.grpcServer.TapByResource(&protoRequest, server)
 	if err != nil {
@@ -292,6 +328,11 @@ func fullURLPathFor(method string) string {
 }
 
 func (h *handler) handleEndpoints(w http.ResponseWriter, req *http.Request) {
+	if err := h.authorize(req, resourceAttributes{verb: "list", resource: "endpoints"}); err != nil {
+		writeErrorToHTTPResponse(w, err)
+		return
+	}
+
 	rsp, err := h.grpcServer.Endpoints(req.Context(), &discoveryPb.EndpointsParams{})
 	if err != nil {
 		writeErrorToHTTPResponse(w, err)
@@ -313,16 +354,20 @@ func NewServer(
 	k8sAPI *k8s.API,
 	controllerNamespace string,
 	ignoredNamespaces []string,
+	healthChecks HealthChecks,
 ) *http.Server {
+	grpcServer := newGrpcServer(
+		promv1.NewAPI(prometheusClient),
+		tapClient,
+		discoveryClient,
+		k8sAPI,
+		controllerNamespace,
+		ignoredNamespaces,
+	)
+	grpcServer.healthChecks = healthChecks
 	baseHandler := &handler{
-		grpcServer: newGrpcServer(
-			promv1.NewAPI(prometheusClient),
-			tapClient,
-			discoveryClient,
-			k8sAPI,
-			controllerNamespace,
-			ignoredNamespaces,
-		),
+		grpcServer: grpcServer,
+		k8sClient:  k8sAPI.Client,
 	}
 
 	instrumentedHandler := prometheus.WithTelemetry(baseHandler)
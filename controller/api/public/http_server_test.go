@@ -12,6 +12,7 @@ import (
 	configPb "github.com/linkerd/linkerd2/controller/gen/config"
 	discoveryPb "github.com/linkerd/linkerd2/controller/gen/controller/discovery"
 	pb "github.com/linkerd/linkerd2/controller/gen/public"
+	pkgK8s "github.com/linkerd/linkerd2/pkg/k8s"
 )
 
 type mockServer struct {
@@ -255,6 +256,61 @@ func TestServer(t *testing.T) {
 			t.Fatalf("Expecting error, got nothing")
 		}
 	})
+
+	t.Run("Checks that users are authorized by Kubernetes RBAC", func(t *testing.T) {
+		mockGrpcServer := &mockGrpcServer{}
+
+		// the fake client never allows subject access reviews
+		k8sClient, err := pkgK8s.NewFakeAPI()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		listener, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			t.Fatalf("Could not start listener: %v", err)
+		}
+
+		go func() {
+			handler := &handler{
+				grpcServer: mockGrpcServer,
+				k8sClient:  k8sClient,
+			}
+			err := http.Serve(listener, handler)
+			if err != nil {
+				t.Fatalf("Could not start server: %v", err)
+			}
+		}()
+
+		client, err := NewInternalClient("linkerd", listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		mockGrpcServer.ResponseToReturn = &pb.ListPodsResponse{}
+		listPodsReq := &pb.ListPodsRequest{
+			Selector: &pb.ResourceSelection{Resource: &pb.Resource{Namespace: "emojivoto"}},
+		}
+
+		if _, err := client.ListPods(context.TODO(), listPodsReq); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		ctx := NewUserContext(context.TODO(), User{Name: "alice", Groups: []string{"devs"}})
+		_, err = client.ListPods(ctx, listPodsReq)
+		expected := "alice is not authorized to list pods"
+		if err == nil || err.Error() != expected {
+			t.Fatalf("Expecting error [%s], got [%v]", expected, err)
+		}
+
+		_, err = client.TapByResource(ctx, &pb.TapByResourceRequest{
+			Target: &pb.ResourceSelection{Resource: &pb.Resource{Namespace: "emojivoto", Type: pkgK8s.Deployment, Name: "web"}},
+		})
+		expected = "alice is not authorized to watch deployments.tap.linkerd.io"
+		if err == nil || err.Error() != expected {
+			t.Fatalf("Expecting error [%s], got [%v]", expected, err)
+		}
+	})
 }
 
 func assertCallWasForwarded(t *testing.T, mockServer *mockServer, expectedRequest proto.Message, expectedResponse proto.Message, functionCall func() (proto.Message, error)) {
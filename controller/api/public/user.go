@@ -0,0 +1,66 @@
+package public
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+const (
+	// UserHeader is the header carrying the name of the user on whose behalf a
+	// request is made to the Public API.
+	UserHeader = "l5d-user"
+
+	// GroupsHeader is the header carrying the comma-separated groups of the
+	// user named by UserHeader.
+	GroupsHeader = "l5d-user-groups"
+)
+
+// User is the identity of the user on whose behalf a request is made, such as
+// a user logged into the dashboard. When the Public API receives a request
+// with a user, it checks that Kubernetes RBAC allows the user to access the
+// requested resources. Requests without a user are not checked, so the user
+// can only narrow what a client may access.
+type User struct {
+	Name   string
+	Groups []string
+}
+
+type userKey struct{}
+
+// NewUserContext returns a context carrying the user, whose identity the
+// client forwards in each request made with the context.
+func NewUserContext(ctx context.Context, user User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext returns the user carried by the context, if any.
+func UserFromContext(ctx context.Context) (User, bool) {
+	user, ok := ctx.Value(userKey{}).(User)
+	return user, ok
+}
+
+func setUserHeaders(ctx context.Context, req *http.Request) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	req.Header.Set(UserHeader, user.Name)
+	if len(user.Groups) > 0 {
+		req.Header.Set(GroupsHeader, strings.Join(user.Groups, ","))
+	}
+}
+
+func userFromHeaders(req *http.Request) (User, bool) {
+	name := req.Header.Get(UserHeader)
+	if name == "" {
+		return User{}, false
+	}
+
+	user := User{Name: name}
+	if groups := req.Header.Get(GroupsHeader); groups != "" {
+		user.Groups = strings.Split(groups, ",")
+	}
+	return user, true
+}
@@ -1,15 +1,21 @@
 package main
 
 import (
+	"crypto"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/golang/protobuf/ptypes"
+	"github.com/linkerd/linkerd2/controller/gen/client/clientset/versioned"
 	idctl "github.com/linkerd/linkerd2/controller/identity"
 	"github.com/linkerd/linkerd2/pkg/admin"
 	"github.com/linkerd/linkerd2/pkg/config"
@@ -22,9 +28,6 @@ import (
 	"google.golang.org/grpc"
 )
 
-// TODO watch trustAnchorsPath for changes
-// TODO watch issuerPath for changes
-// TODO restrict servicetoken audiences (and lifetimes)
 func main() {
 	addr := flag.String("addr", ":8080", "address to serve on")
 	adminAddr := flag.String("admin-addr", ":9990", "address of HTTP admin server")
@@ -32,6 +35,47 @@ func main() {
 	issuerPath := flag.String("issuer",
 		"/var/run/linkerd/identity/issuer",
 		"path to directory containing issuer credentials")
+	issuerReloadInterval := flag.Duration("issuer-reload-interval",
+		identity.DefaultIssuerReloadInterval,
+		"interval at which issuer credentials and trust anchors are checked for changes")
+	tokenAudience := flag.String("token-audience", consts.IdentityTokenAudience,
+		"audience requested when reviewing projected service account tokens; empty to only accept default tokens")
+	requireTokenAudience := flag.Bool("require-token-audience", false,
+		"reject service account tokens that are not bound to the token audience")
+	issuerBackend := flag.String("issuer-backend", idctl.IssuerBackendLocal,
+		fmt.Sprintf("backend used to sign certificates (one of: %s)", strings.Join(idctl.IssuerBackends, ", ")))
+	issuerTimeout := flag.Duration("issuer-timeout", idctl.DefaultExternalIssuerTimeout,
+		"maximum time allowed for an external issuer backend to sign a certificate")
+	certManagerIssuerName := flag.String("cert-manager-issuer-name", "",
+		"name of the cert-manager issuer used by the cert-manager backend")
+	certManagerIssuerKind := flag.String("cert-manager-issuer-kind", "Issuer",
+		"kind of the cert-manager issuer (Issuer or ClusterIssuer)")
+	certManagerIssuerGroup := flag.String("cert-manager-issuer-group", "cert-manager.io",
+		"API group of the cert-manager issuer")
+	vaultAddr := flag.String("vault-addr", "", "address of the Vault server used by the vault backend")
+	vaultPKIMount := flag.String("vault-pki-mount", "pki", "path at which the Vault PKI secrets engine is mounted")
+	vaultRole := flag.String("vault-role", "", "Vault PKI role used to sign certificates")
+	vaultTokenPath := flag.String("vault-token-path", "/var/run/secrets/vault/token",
+		"path to a file containing the Vault token")
+	webhookURL := flag.String("webhook-url", "", "URL to which the webhook backend posts signing requests")
+	auditLogPath := flag.String("audit-log", "",
+		"path of a file to which a JSON record of every issued certificate is appended; \"-\" for stdout")
+	spiffeURISANs := flag.Bool("spiffe-uri-sans", false,
+		"add a URI SAN with the SPIFFE ID (spiffe://<trust-domain>/ns/<ns>/sa/<sa>) to issued certificates")
+	enableDenylist := flag.Bool("enable-denylist", false,
+		"consult the linkerd-identity-denylist ConfigMap before issuing certificates")
+	workloadIdentity := flag.Bool("workload-identity", false,
+		"certify workloads outside of Kubernetes that present a WorkloadIdentity bootstrap token or attestation document")
+	workloadAttestationKey := flag.String("workload-attestation-key", "",
+		"path to a PEM-encoded public key that signs workload attestation documents; attestations are rejected if unset")
+	identityRateLimit := flag.Float64("rate-limit-identity", 0,
+		"average rate, per second, of certification requests allowed for each identity; 0 for no limit")
+	identityRateLimitBurst := flag.Int("rate-limit-identity-burst", 10,
+		"number of certification requests allowed in a burst for each identity")
+	ipRateLimit := flag.Float64("rate-limit-ip", 0,
+		"average rate, per second, of certification requests allowed from each source IP; 0 for no limit")
+	ipRateLimitBurst := flag.Int("rate-limit-ip-burst", 10,
+		"number of certification requests allowed in a burst from each source IP")
 	flags.ConfigureAndParse()
 
 	cfg, err := config.Global(consts.MountPathGlobalConfig)
@@ -55,24 +99,6 @@ func main() {
 		log.Fatalf("Invalid trust domain: %s", err.Error())
 	}
 
-	trustAnchors, err := tls.DecodePEMCertPool(idctx.GetTrustAnchorsPem())
-	if err != nil {
-		log.Fatalf("Failed to read trust anchors: %s", err)
-	}
-
-	creds, err := tls.ReadPEMCreds(
-		filepath.Join(*issuerPath, consts.IdentityIssuerKeyName),
-		filepath.Join(*issuerPath, consts.IdentityIssuerCrtName),
-	)
-	if err != nil {
-		log.Fatalf("Failed to read CA from %s: %s", *issuerPath, err)
-	}
-
-	expectedName := fmt.Sprintf("identity.%s.%s", controllerNS, trustDomain)
-	if err := creds.Crt.Verify(trustAnchors, expectedName); err != nil {
-		log.Fatalf("Failed to verify issuer credentials for '%s' with trust anchors: %s", expectedName, err)
-	}
-
 	validity := tls.Validity{
 		ClockSkewAllowance: tls.DefaultClockSkewAllowance,
 		Lifetime:           identity.DefaultIssuanceLifetime,
@@ -94,18 +120,97 @@ func main() {
 		}
 	}
 
-	ca := tls.NewCA(*creds, validity)
-
 	k8s, err := k8s.NewAPI(*kubeConfigPath, "", 0)
 	if err != nil {
 		log.Fatalf("Failed to load kubeconfig: %s: %s", *kubeConfigPath, err)
 	}
-	v, err := idctl.NewK8sTokenValidator(k8s, dom)
+	var v identity.Validator
+	v, err = idctl.NewK8sTokenValidator(k8s, dom, *tokenAudience, *requireTokenAudience)
 	if err != nil {
 		log.Fatalf("Failed to initialize identity service: %s", err)
 	}
 
-	svc := identity.NewService(v, ca)
+	var audit io.Writer
+	switch *auditLogPath {
+	case "":
+	case "-":
+		audit = os.Stdout
+	default:
+		f, err := os.OpenFile(*auditLogPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			log.Fatalf("Failed to open audit log: %s", err)
+		}
+		defer f.Close()
+		audit = f
+	}
+
+	if *workloadIdentity {
+		v = workloadValidator(k8s, dom, *workloadAttestationKey, v, audit)
+	}
+
+	done := make(chan struct{})
+	var issuer tls.Issuer
+	if *issuerBackend == idctl.IssuerBackendLocal {
+		issuer = localIssuer(*issuerPath, controllerNS, trustDomain, validity, *issuerReloadInterval, done)
+	} else {
+		kubeClient, err := k8s.NewClient()
+		if err != nil {
+			log.Fatalf("Failed to initialize kubernetes client: %s", err)
+		}
+		trustAnchors, err := tls.DecodePEMCertPool(idctx.GetTrustAnchorsPem())
+		if err != nil {
+			log.Fatalf("Failed to read trust anchors: %s", err)
+		}
+		issuer, err = idctl.NewExternalIssuer(idctl.ExternalIssuerConfig{
+			Backend:      *issuerBackend,
+			Lifetime:     validity.Lifetime,
+			Timeout:      *issuerTimeout,
+			TrustAnchors: trustAnchors,
+			CertManager: idctl.CertManagerConfig{
+				Namespace:   controllerNS,
+				IssuerName:  *certManagerIssuerName,
+				IssuerKind:  *certManagerIssuerKind,
+				IssuerGroup: *certManagerIssuerGroup,
+			},
+			Vault: idctl.VaultConfig{
+				Addr:      *vaultAddr,
+				Mount:     *vaultPKIMount,
+				Role:      *vaultRole,
+				TokenPath: *vaultTokenPath,
+			},
+			Webhook: idctl.WebhookConfig{URL: *webhookURL},
+		}, kubeClient, k8s.Config.Host)
+		if err != nil {
+			log.Fatalf("Failed to initialize %s issuer: %s", *issuerBackend, err)
+		}
+		log.Infof("using %s issuer backend", *issuerBackend)
+	}
+
+	svc := identity.NewService(v, issuer)
+	if audit != nil {
+		svc.EnableAuditLog(audit)
+	}
+	if *enableDenylist {
+		denylist, err := idctl.NewConfigMapDenylist(k8s, controllerNS, dom, done)
+		if err != nil {
+			log.Fatalf("Failed to initialize identity denylist: %s", err)
+		}
+		svc.EnableDenylist(denylist)
+	}
+	if err := svc.EnableRateLimits(
+		identity.RateLimit{Rate: *identityRateLimit, Burst: *identityRateLimitBurst},
+		identity.RateLimit{Rate: *ipRateLimit, Burst: *ipRateLimitBurst},
+	); err != nil {
+		log.Fatalf("Failed to configure rate limits: %s", err)
+	}
+	if *spiffeURISANs {
+		if *issuerBackend == idctl.IssuerBackendCertManager {
+			log.Fatalf("SPIFFE URI SANs are not supported by the %s issuer backend", *issuerBackend)
+		}
+		if err := svc.EnableURISANs(); err != nil {
+			log.Fatalf("Failed to enable SPIFFE URI SANs: %s", err)
+		}
+	}
 
 	go admin.StartServer(*adminAddr)
 	lis, err := net.Listen("tcp", *addr)
@@ -120,6 +225,77 @@ func main() {
 		srv.Serve(lis)
 	}()
 	<-stop
+	close(done)
 	log.Infof("shutting down gRPC server on %s", *addr)
 	srv.GracefulStop()
 }
+
+// workloadValidator wraps next with a validator for workloads registered with
+// WorkloadIdentity resources.
+func workloadValidator(
+	k8sAPI *k8s.KubernetesAPI,
+	dom *idctl.TrustDomain,
+	attestationKeyPath string,
+	next identity.Validator,
+	audit io.Writer,
+) identity.Validator {
+	var attestationKey crypto.PublicKey
+	if attestationKeyPath != "" {
+		pem, err := ioutil.ReadFile(attestationKeyPath)
+		if err != nil {
+			log.Fatalf("Failed to read workload attestation key: %s", err)
+		}
+		attestationKey, err = tls.DecodePEMPublicKey(string(pem))
+		if err != nil {
+			log.Fatalf("Failed to decode workload attestation key: %s", err)
+		}
+	}
+
+	client, err := versioned.NewForConfig(k8sAPI.Config)
+	if err != nil {
+		log.Fatalf("Failed to initialize WorkloadIdentity client: %s", err)
+	}
+	v, err := idctl.NewWorkloadValidator(client.IdentityV1alpha1(), dom, attestationKey, next)
+	if err != nil {
+		log.Fatalf("Failed to initialize workload validator: %s", err)
+	}
+	if audit != nil {
+		v.EnableAuditLog(audit)
+	}
+	log.Info("certifying workloads registered with WorkloadIdentity resources")
+	return v
+}
+
+// localIssuer loads the mounted issuer credentials and watches them for
+// changes until done is closed.
+func localIssuer(
+	issuerPath, controllerNS, trustDomain string,
+	validity tls.Validity,
+	reloadInterval time.Duration,
+	done <-chan struct{},
+) tls.Issuer {
+	// Trust anchors are re-read from the global config so that they may be
+	// updated alongside the issuer credentials.
+	trustAnchors := func() (string, error) {
+		cfg, err := config.Global(consts.MountPathGlobalConfig)
+		if err != nil {
+			return "", err
+		}
+		return cfg.GetIdentityContext().GetTrustAnchorsPem(), nil
+	}
+
+	expectedName := fmt.Sprintf("identity.%s.%s", controllerNS, trustDomain)
+	issuer, err := identity.NewReloadingIssuer(
+		filepath.Join(issuerPath, consts.IdentityIssuerKeyName),
+		filepath.Join(issuerPath, consts.IdentityIssuerCrtName),
+		expectedName,
+		validity,
+		trustAnchors,
+	)
+	if err != nil {
+		log.Fatalf("Failed to load issuer credentials from %s: %s", issuerPath, err)
+	}
+
+	go issuer.Watch(reloadInterval, done)
+	return issuer
+}
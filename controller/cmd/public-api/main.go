@@ -3,10 +3,12 @@ package main
 import (
 	"context"
 	"flag"
+	"net"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/linkerd/linkerd2/controller/api/discovery"
 	"github.com/linkerd/linkerd2/controller/api/public"
@@ -14,6 +16,8 @@ import (
 	"github.com/linkerd/linkerd2/controller/tap"
 	"github.com/linkerd/linkerd2/pkg/admin"
 	"github.com/linkerd/linkerd2/pkg/flags"
+	"github.com/linkerd/linkerd2/pkg/healthcheck"
+	"github.com/linkerd/linkerd2/pkg/version"
 	promApi "github.com/prometheus/client_golang/api"
 	log "github.com/sirupsen/logrus"
 )
@@ -27,6 +31,7 @@ func main() {
 	tapAddr := flag.String("tap-addr", "127.0.0.1:8088", "address of tap service")
 	controllerNamespace := flag.String("controller-namespace", "linkerd", "namespace in which Linkerd is installed")
 	ignoredNamespaces := flag.String("ignore-namespaces", "kube-system", "comma separated list of namespaces to not list pods from")
+	healthCheckInterval := flag.Duration("health-check-interval", time.Minute, "interval at which health checks are run; 0 disables them")
 	flags.ConfigureAndParse()
 
 	stop := make(chan os.Signal, 1)
@@ -57,6 +62,13 @@ func main() {
 		log.Fatal(err.Error())
 	}
 
+	var healthChecks public.HealthChecks
+	var monitor *healthcheck.Monitor
+	if *healthCheckInterval > 0 {
+		monitor = newHealthCheckMonitor(*addr, *controllerNamespace, *prometheusURL, *healthCheckInterval)
+		healthChecks = monitor
+	}
+
 	server := public.NewServer(
 		*addr,
 		prometheusClient,
@@ -65,6 +77,7 @@ func main() {
 		k8sAPI,
 		*controllerNamespace,
 		strings.Split(*ignoredNamespaces, ","),
+		healthChecks,
 	)
 
 	k8sAPI.Sync() // blocks until caches are synced
@@ -74,10 +87,44 @@ func main() {
 		server.ListenAndServe()
 	}()
 
+	stopMonitor := make(chan struct{})
+	if monitor != nil {
+		log.Infof("running health checks every %s", *healthCheckInterval)
+		go monitor.Run(stopMonitor)
+	}
+
 	go admin.StartServer(*metricsAddr)
 
 	<-stop
 
+	close(stopMonitor)
 	log.Infof("shutting down HTTP server on %+v", *addr)
 	server.Shutdown(context.Background())
 }
+
+// newHealthCheckMonitor creates a Monitor for the health checks that can run
+// in the cluster. The public API is queried through addr and Prometheus
+// through prometheusURL, and the version checks compare the control plane and
+// the data plane against the version of this controller instead of the latest
+// release. The identity checks are left out, since they read the issuer's
+// private key, which the public API must not have access to.
+func newHealthCheckMonitor(addr, controllerNamespace, prometheusURL string, interval time.Duration) *healthcheck.Monitor {
+	host, port, err := net.SplitHostPort(addr)
+	if err == nil && (host == "" || host == "0.0.0.0" || host == "::") {
+		addr = net.JoinHostPort("localhost", port)
+	}
+
+	checks := []healthcheck.CategoryID{
+		healthcheck.KubernetesAPIChecks,
+		healthcheck.LinkerdVersionChecks,
+		healthcheck.LinkerdConfigChecks,
+		healthcheck.LinkerdControlPlaneExistenceChecks,
+		healthcheck.LinkerdDataPlaneChecks,
+	}
+	return healthcheck.NewMonitor(checks, &healthcheck.Options{
+		ControlPlaneNamespace: controllerNamespace,
+		APIAddr:               addr,
+		VersionOverride:       version.Version,
+		PrometheusURL:         prometheusURL,
+	}, interval)
+}
@@ -0,0 +1,4 @@
+package workloadidentity
+
+// GroupName identifies the API Group Name for a WorkloadIdentity.
+const GroupName = "identity.linkerd.io"
@@ -0,0 +1,85 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WorkloadIdentity registers a workload that runs outside of Kubernetes, such
+// as a VM, with the identity service. The workload is certified with the
+// identity of a service account in the resource's namespace.
+type WorkloadIdentity struct {
+	// TypeMeta is the metadata for the resource, like kind and apiversion
+	metav1.TypeMeta `json:",inline"`
+	// ObjectMeta contains the metadata for the particular object
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec is the custom resource spec
+	Spec WorkloadIdentitySpec `json:"spec"`
+
+	// Status records how the workload's bootstrap tokens have been used
+	Status WorkloadIdentityStatus `json:"status,omitempty"`
+}
+
+// WorkloadIdentitySpec specifies a WorkloadIdentity resource.
+type WorkloadIdentitySpec struct {
+	// ServiceAccount is the name of the service account whose identity is
+	// certified for the workload.
+	ServiceAccount string `json:"serviceAccount"`
+
+	// BootstrapTokens may be presented by the workload to be certified.
+	BootstrapTokens []BootstrapToken `json:"bootstrapTokens,omitempty"`
+
+	// Revoked stops the workload from being certified, whether with a
+	// bootstrap token or an attestation document.
+	Revoked bool `json:"revoked,omitempty"`
+}
+
+// BootstrapToken describes a single-use bootstrap token. A token is bound to
+// the key of the first certificate request in which it is presented, and may
+// then only be used to renew certificates for that key.
+type BootstrapToken struct {
+	// Name identifies the token in the resource's status and in audit logs.
+	Name string `json:"name"`
+
+	// SHA256 is the hex-encoded SHA-256 digest of the token's secret.
+	SHA256 string `json:"sha256"`
+
+	// Expires, if set, is the time after which the token may no longer be
+	// bound. It does not affect a token that has already been bound.
+	Expires *metav1.Time `json:"expires,omitempty"`
+
+	// Revoked stops the token from being used.
+	Revoked bool `json:"revoked,omitempty"`
+}
+
+// WorkloadIdentityStatus describes the status of a WorkloadIdentity resource.
+type WorkloadIdentityStatus struct {
+	// BoundTokens are the bootstrap tokens that have been used.
+	BoundTokens []BoundToken `json:"boundTokens,omitempty"`
+}
+
+// BoundToken describes a bootstrap token that has been bound to a key.
+type BoundToken struct {
+	// Name is the name of the bootstrap token.
+	Name string `json:"name"`
+
+	// KeySHA256 is the hex-encoded SHA-256 digest of the DER-encoded public
+	// key to which the token is bound.
+	KeySHA256 string `json:"keySHA256"`
+
+	// BoundAt is the time at which the token was first used.
+	BoundAt metav1.Time `json:"boundAt"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WorkloadIdentityList is a list of WorkloadIdentity resources.
+type WorkloadIdentityList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []WorkloadIdentity `json:"items"`
+}
@@ -20,6 +20,7 @@ package versioned
 
 import (
 	linkerdv1alpha1 "github.com/linkerd/linkerd2/controller/gen/client/clientset/versioned/typed/serviceprofile/v1alpha1"
+	identityv1alpha1 "github.com/linkerd/linkerd2/controller/gen/client/clientset/versioned/typed/workloadidentity/v1alpha1"
 	discovery "k8s.io/client-go/discovery"
 	rest "k8s.io/client-go/rest"
 	flowcontrol "k8s.io/client-go/util/flowcontrol"
@@ -30,13 +31,17 @@ type Interface interface {
 	LinkerdV1alpha1() linkerdv1alpha1.LinkerdV1alpha1Interface
 	// Deprecated: please explicitly pick a version if possible.
 	Linkerd() linkerdv1alpha1.LinkerdV1alpha1Interface
+	IdentityV1alpha1() identityv1alpha1.IdentityV1alpha1Interface
+	// Deprecated: please explicitly pick a version if possible.
+	Identity() identityv1alpha1.IdentityV1alpha1Interface
 }
 
 // Clientset contains the clients for groups. Each group has exactly one
 // version included in a Clientset.
 type Clientset struct {
 	*discovery.DiscoveryClient
-	linkerdV1alpha1 *linkerdv1alpha1.LinkerdV1alpha1Client
+	linkerdV1alpha1  *linkerdv1alpha1.LinkerdV1alpha1Client
+	identityV1alpha1 *identityv1alpha1.IdentityV1alpha1Client
 }
 
 // LinkerdV1alpha1 retrieves the LinkerdV1alpha1Client
@@ -50,6 +55,17 @@ func (c *Clientset) Linkerd() linkerdv1alpha1.LinkerdV1alpha1Interface {
 	return c.linkerdV1alpha1
 }
 
+// IdentityV1alpha1 retrieves the IdentityV1alpha1Client
+func (c *Clientset) IdentityV1alpha1() identityv1alpha1.IdentityV1alpha1Interface {
+	return c.identityV1alpha1
+}
+
+// Deprecated: Identity retrieves the default version of IdentityClient.
+// Please explicitly pick a version.
+func (c *Clientset) Identity() identityv1alpha1.IdentityV1alpha1Interface {
+	return c.identityV1alpha1
+}
+
 // Discovery retrieves the DiscoveryClient
 func (c *Clientset) Discovery() discovery.DiscoveryInterface {
 	if c == nil {
@@ -70,6 +86,10 @@ func NewForConfig(c *rest.Config) (*Clientset, error) {
 	if err != nil {
 		return nil, err
 	}
+	cs.identityV1alpha1, err = identityv1alpha1.NewForConfig(&configShallowCopy)
+	if err != nil {
+		return nil, err
+	}
 
 	cs.DiscoveryClient, err = discovery.NewDiscoveryClientForConfig(&configShallowCopy)
 	if err != nil {
@@ -83,6 +103,7 @@ func NewForConfig(c *rest.Config) (*Clientset, error) {
 func NewForConfigOrDie(c *rest.Config) *Clientset {
 	var cs Clientset
 	cs.linkerdV1alpha1 = linkerdv1alpha1.NewForConfigOrDie(c)
+	cs.identityV1alpha1 = identityv1alpha1.NewForConfigOrDie(c)
 
 	cs.DiscoveryClient = discovery.NewDiscoveryClientForConfigOrDie(c)
 	return &cs
@@ -92,6 +113,7 @@ func NewForConfigOrDie(c *rest.Config) *Clientset {
 func New(c rest.Interface) *Clientset {
 	var cs Clientset
 	cs.linkerdV1alpha1 = linkerdv1alpha1.New(c)
+	cs.identityV1alpha1 = identityv1alpha1.New(c)
 
 	cs.DiscoveryClient = discovery.NewDiscoveryClient(c)
 	return &cs
@@ -22,6 +22,8 @@ import (
 	clientset "github.com/linkerd/linkerd2/controller/gen/client/clientset/versioned"
 	linkerdv1alpha1 "github.com/linkerd/linkerd2/controller/gen/client/clientset/versioned/typed/serviceprofile/v1alpha1"
 	fakelinkerdv1alpha1 "github.com/linkerd/linkerd2/controller/gen/client/clientset/versioned/typed/serviceprofile/v1alpha1/fake"
+	identityv1alpha1 "github.com/linkerd/linkerd2/controller/gen/client/clientset/versioned/typed/workloadidentity/v1alpha1"
+	fakeidentityv1alpha1 "github.com/linkerd/linkerd2/controller/gen/client/clientset/versioned/typed/workloadidentity/v1alpha1/fake"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/discovery"
@@ -80,3 +82,13 @@ func (c *Clientset) LinkerdV1alpha1() linkerdv1alpha1.LinkerdV1alpha1Interface {
 func (c *Clientset) Linkerd() linkerdv1alpha1.LinkerdV1alpha1Interface {
 	return &fakelinkerdv1alpha1.FakeLinkerdV1alpha1{Fake: &c.Fake}
 }
+
+// IdentityV1alpha1 retrieves the IdentityV1alpha1Client
+func (c *Clientset) IdentityV1alpha1() identityv1alpha1.IdentityV1alpha1Interface {
+	return &fakeidentityv1alpha1.FakeIdentityV1alpha1{Fake: &c.Fake}
+}
+
+// Identity retrieves the IdentityV1alpha1Client
+func (c *Clientset) Identity() identityv1alpha1.IdentityV1alpha1Interface {
+	return &fakeidentityv1alpha1.FakeIdentityV1alpha1{Fake: &c.Fake}
+}
@@ -21,7 +21,8 @@ package externalversions
 import (
 	"fmt"
 
-	v1alpha1 "github.com/linkerd/linkerd2/controller/gen/apis/serviceprofile/v1alpha1"
+	serviceprofilev1alpha1 "github.com/linkerd/linkerd2/controller/gen/apis/serviceprofile/v1alpha1"
+	v1alpha1 "github.com/linkerd/linkerd2/controller/gen/apis/workloadidentity/v1alpha1"
 	schema "k8s.io/apimachinery/pkg/runtime/schema"
 	cache "k8s.io/client-go/tools/cache"
 )
@@ -52,8 +53,12 @@ func (f *genericInformer) Lister() cache.GenericLister {
 // TODO extend this to unknown resources with a client pool
 func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource) (GenericInformer, error) {
 	switch resource {
-	// Group=linkerd.io, Version=v1alpha1
-	case v1alpha1.SchemeGroupVersion.WithResource("serviceprofiles"):
+	// Group=identity.linkerd.io, Version=v1alpha1
+	case v1alpha1.SchemeGroupVersion.WithResource("workloadidentities"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Identity().V1alpha1().WorkloadIdentities().Informer()}, nil
+
+		// Group=linkerd.io, Version=v1alpha1
+	case serviceprofilev1alpha1.SchemeGroupVersion.WithResource("serviceprofiles"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Linkerd().V1alpha1().ServiceProfiles().Informer()}, nil
 
 	}
@@ -0,0 +1,271 @@
+package identity
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/linkerd/linkerd2/pkg/tls"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	certManagerAPIVersion   = "cert-manager.io/v1alpha2"
+	certManagerPollInterval = 250 * time.Millisecond
+)
+
+type (
+	// CertManagerConfig configures a CertManagerIssuer.
+	CertManagerConfig struct {
+		// Namespace is the namespace in which CertificateRequests are created.
+		Namespace string
+
+		// IssuerName is the name of the cert-manager Issuer or ClusterIssuer.
+		IssuerName string
+
+		// IssuerKind is either "Issuer" or "ClusterIssuer".
+		IssuerKind string
+
+		// IssuerGroup is the API group of the issuer.
+		IssuerGroup string
+	}
+
+	// CertManagerIssuer implements tls.Issuer by creating cert-manager
+	// CertificateRequest resources and waiting for them to be signed.
+	//
+	// The identity service account must be allowed to create, get and delete
+	// certificaterequests in the configured namespace.
+	CertManagerIssuer struct {
+		cfg      CertManagerConfig
+		client   *http.Client
+		host     string
+		roots    *x509.CertPool
+		lifetime time.Duration
+		timeout  time.Duration
+	}
+
+	certificateRequest struct {
+		APIVersion string                   `json:"apiVersion"`
+		Kind       string                   `json:"kind"`
+		Metadata   certificateRequestMeta   `json:"metadata"`
+		Spec       certificateRequestSpec   `json:"spec"`
+		Status     certificateRequestStatus `json:"status,omitempty"`
+	}
+
+	certificateRequestMeta struct {
+		Name         string            `json:"name,omitempty"`
+		GenerateName string            `json:"generateName,omitempty"`
+		Namespace    string            `json:"namespace,omitempty"`
+		Annotations  map[string]string `json:"annotations,omitempty"`
+	}
+
+	certificateRequestSpec struct {
+		CSR       []byte   `json:"csr"`
+		Duration  string   `json:"duration,omitempty"`
+		IsCA      bool     `json:"isCA"`
+		Usages    []string `json:"usages,omitempty"`
+		IssuerRef struct {
+			Name  string `json:"name"`
+			Kind  string `json:"kind,omitempty"`
+			Group string `json:"group,omitempty"`
+		} `json:"issuerRef"`
+	}
+
+	certificateRequestStatus struct {
+		Certificate []byte                        `json:"certificate,omitempty"`
+		CA          []byte                        `json:"ca,omitempty"`
+		Conditions  []certificateRequestCondition `json:"conditions,omitempty"`
+	}
+
+	certificateRequestCondition struct {
+		Type    string `json:"type"`
+		Status  string `json:"status"`
+		Reason  string `json:"reason,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+// NewCertManagerIssuer creates a CertManagerIssuer that talks to the
+// Kubernetes API at host with the provided client. Its certificates must chain
+// to roots.
+func NewCertManagerIssuer(
+	cfg CertManagerConfig,
+	client *http.Client,
+	host string,
+	roots *x509.CertPool,
+	lifetime, timeout time.Duration,
+) (*CertManagerIssuer, error) {
+	if cfg.IssuerName == "" {
+		return nil, errors.New("cert-manager issuer name must be specified")
+	}
+	if cfg.Namespace == "" {
+		return nil, errors.New("cert-manager namespace must be specified")
+	}
+	if cfg.IssuerKind == "" {
+		cfg.IssuerKind = "Issuer"
+	}
+	if cfg.IssuerGroup == "" {
+		cfg.IssuerGroup = "cert-manager.io"
+	}
+	if client == nil {
+		return nil, errors.New("cert-manager issuer requires a Kubernetes client")
+	}
+	if roots == nil {
+		return nil, errors.New("trust anchors must be specified")
+	}
+
+	return &CertManagerIssuer{
+		cfg:      cfg,
+		client:   client,
+		host:     strings.TrimSuffix(host, "/"),
+		roots:    roots,
+		lifetime: lifetime,
+		timeout:  timeout,
+	}, nil
+}
+
+// IssueEndEntityCrt creates a CertificateRequest for the CSR and waits for
+// cert-manager to sign it. The CertificateRequest is deleted once it has been
+// processed.
+func (cm *CertManagerIssuer) IssueEndEntityCrt(csr *x509.CertificateRequest) (tls.Crt, error) {
+	return cm.issue(csr, cm.lifetime)
+}
+
+// IssueEndEntityCrtWithLifetime is like IssueEndEntityCrt, but requests a
+// duration of no more than lifetime.
+func (cm *CertManagerIssuer) IssueEndEntityCrtWithLifetime(csr *x509.CertificateRequest, lifetime time.Duration) (tls.Crt, error) {
+	return cm.issue(csr, shorterLifetime(cm.lifetime, lifetime))
+}
+
+func (cm *CertManagerIssuer) issue(csr *x509.CertificateRequest, lifetime time.Duration) (tls.Crt, error) {
+	csrPEM, err := encodeCSR(csr)
+	if err != nil {
+		return tls.Crt{}, err
+	}
+
+	cr := certificateRequest{
+		APIVersion: certManagerAPIVersion,
+		Kind:       "CertificateRequest",
+		Metadata: certificateRequestMeta{
+			GenerateName: "linkerd-identity-",
+			Namespace:    cm.cfg.Namespace,
+		},
+	}
+	if len(csr.DNSNames) > 0 {
+		cr.Metadata.Annotations = map[string]string{"linkerd.io/identity": csr.DNSNames[0]}
+	}
+	cr.Spec.CSR = csrPEM
+	cr.Spec.Usages = []string{"digital signature", "key encipherment", "server auth", "client auth"}
+	cr.Spec.IssuerRef.Name = cm.cfg.IssuerName
+	cr.Spec.IssuerRef.Kind = cm.cfg.IssuerKind
+	cr.Spec.IssuerRef.Group = cm.cfg.IssuerGroup
+	if lifetime != 0 {
+		cr.Spec.Duration = lifetime.String()
+	}
+
+	created, err := cm.do(http.MethodPost, cm.collectionURL(), &cr)
+	if err != nil {
+		return tls.Crt{}, fmt.Errorf("failed to create CertificateRequest: %s", err)
+	}
+	name := created.Metadata.Name
+	defer func() {
+		if _, err := cm.do(http.MethodDelete, cm.resourceURL(name), nil); err != nil {
+			log.Warnf("failed to delete CertificateRequest %s/%s: %s", cm.cfg.Namespace, name, err)
+		}
+	}()
+
+	deadline := time.Now().Add(cm.timeout)
+	current := created
+	for {
+		signed, err := current.signed()
+		if err != nil {
+			return tls.Crt{}, fmt.Errorf("CertificateRequest %s/%s %s", cm.cfg.Namespace, name, err)
+		}
+		if signed {
+			return decodeIssuedCrt(csr, cm.roots, string(current.Status.Certificate), string(current.Status.CA))
+		}
+
+		if time.Now().After(deadline) {
+			return tls.Crt{}, fmt.Errorf("timed out waiting for CertificateRequest %s/%s to be signed", cm.cfg.Namespace, name)
+		}
+		time.Sleep(certManagerPollInterval)
+
+		current, err = cm.do(http.MethodGet, cm.resourceURL(name), nil)
+		if err != nil {
+			return tls.Crt{}, fmt.Errorf("failed to get CertificateRequest %s/%s: %s", cm.cfg.Namespace, name, err)
+		}
+	}
+}
+
+// signed returns true once the CertificateRequest is ready. An error is
+// returned if cert-manager will not sign the request.
+func (cr *certificateRequest) signed() (bool, error) {
+	for _, c := range cr.Status.Conditions {
+		if c.Type != "Ready" {
+			continue
+		}
+		if c.Status == "True" {
+			return len(cr.Status.Certificate) > 0, nil
+		}
+		switch c.Reason {
+		case "Failed", "Denied":
+			return false, fmt.Errorf("was not signed: %s: %s", c.Reason, c.Message)
+		}
+	}
+	return false, nil
+}
+
+func (cm *CertManagerIssuer) collectionURL() string {
+	return fmt.Sprintf("%s/apis/%s/namespaces/%s/certificaterequests",
+		cm.host, certManagerAPIVersion, cm.cfg.Namespace)
+}
+
+func (cm *CertManagerIssuer) resourceURL(name string) string {
+	return fmt.Sprintf("%s/%s", cm.collectionURL(), name)
+}
+
+func (cm *CertManagerIssuer) do(method, url string, in *certificateRequest) (*certificateRequest, error) {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	rsp, err := cm.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+
+	rspBody, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		return nil, fmt.Errorf("unexpected response (HTTP %d): %s", rsp.StatusCode, rspBody)
+	}
+
+	out := &certificateRequest{}
+	if method == http.MethodDelete {
+		return out, nil
+	}
+	if err := json.Unmarshal(rspBody, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,205 @@
+package identity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linkerd/linkerd2/pkg/identity"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// DefaultRestrictedLifetime is the lifetime of certificates issued to
+	// restricted identities, unless the denylist configures another.
+	DefaultRestrictedLifetime = 10 * time.Minute
+
+	// Keys of the denylist ConfigMap. The denied and restricted keys hold one
+	// entry per line, in one of the forms:
+	//
+	//   <identity>                 e.g. web.emojivoto.serviceaccount.identity.linkerd.cluster.local
+	//   <namespace>/<serviceaccount>
+	//   <namespace>/*
+	//
+	// Lines that are empty or start with '#' are ignored.
+	denylistDeniedKey             = "denied"
+	denylistRestrictedKey         = "restricted"
+	denylistRestrictedLifetimeKey = "restrictedLifetime"
+
+	// denylistSyncTimeout bounds how long NewConfigMapDenylist waits for the
+	// denylist to be read, e.g. when the ConfigMap can't be watched.
+	denylistSyncTimeout = 60 * time.Second
+)
+
+type (
+	// ConfigMapDenylist implements identity.Denylist for the
+	// linkerd-identity-denylist ConfigMap in the control plane namespace.
+	//
+	// Denied identities may not be issued certificates at all. Restricted
+	// identities are issued certificates with a short lifetime, so that their
+	// certificates expire soon after they are denied.
+	ConfigMapDenylist struct {
+		domain *TrustDomain
+
+		mu                 sync.RWMutex
+		denied, restricted denylistRules
+		restrictedLifetime time.Duration
+	}
+
+	// denylistRules match identities, either exactly or by namespace.
+	denylistRules struct {
+		identities map[string]struct{}
+		namespaces map[string]struct{}
+	}
+)
+
+// NewConfigMapDenylist watches the denylist ConfigMap in namespace until stop
+// is closed. It returns once the ConfigMap has been read, or with an error if
+// it could not be read within denylistSyncTimeout.
+//
+// A missing ConfigMap denies nothing.
+func NewConfigMapDenylist(
+	client kubernetes.Interface,
+	namespace string,
+	domain *TrustDomain,
+	stop <-chan struct{},
+) (*ConfigMapDenylist, error) {
+	d := &ConfigMapDenylist{domain: domain, restrictedLifetime: DefaultRestrictedLifetime}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 10*time.Minute,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fmt.Sprintf("metadata.name=%s", k8s.IdentityDenylistConfigMapName)
+		}),
+	)
+	informer := factory.Core().V1().ConfigMaps().Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			d.update(obj.(*corev1.ConfigMap))
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			d.update(obj.(*corev1.ConfigMap))
+		},
+		DeleteFunc: func(interface{}) {
+			d.update(nil)
+		},
+	})
+	factory.Start(stop)
+
+	ctx, cancel := context.WithTimeout(context.Background(), denylistSyncTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return nil, fmt.Errorf("failed to sync the identity denylist within %s", denylistSyncTimeout)
+	}
+	return d, nil
+}
+
+// Check returns an identity.Denied error for denied identities and the
+// restricted lifetime for restricted identities.
+func (d *ConfigMapDenylist) Check(id string) (time.Duration, error) {
+	ns := ""
+	if _, _, idns, err := d.domain.parseIdentity(id); err == nil {
+		ns = idns
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.denied.matches(id, ns) {
+		return 0, identity.Denied{Reason: fmt.Sprintf("%s is denied by %s", id, k8s.IdentityDenylistConfigMapName)}
+	}
+	if d.restricted.matches(id, ns) {
+		return d.restrictedLifetime, nil
+	}
+	return 0, nil
+}
+
+// update replaces the denylist with the contents of cm, which may be nil.
+// Invalid entries are logged and ignored.
+func (d *ConfigMapDenylist) update(cm *corev1.ConfigMap) {
+	var data map[string]string
+	if cm != nil {
+		data = cm.Data
+	}
+
+	denied := d.parseRules(data[denylistDeniedKey])
+	restricted := d.parseRules(data[denylistRestrictedKey])
+	lifetime := DefaultRestrictedLifetime
+	if l := strings.TrimSpace(data[denylistRestrictedLifetimeKey]); l != "" {
+		parsed, err := time.ParseDuration(l)
+		if err != nil || parsed <= 0 {
+			log.Errorf("invalid %s in %s: %s; using %s",
+				denylistRestrictedLifetimeKey, k8s.IdentityDenylistConfigMapName, l, lifetime)
+		} else {
+			lifetime = parsed
+		}
+	}
+
+	d.mu.Lock()
+	d.denied, d.restricted, d.restrictedLifetime = denied, restricted, lifetime
+	d.mu.Unlock()
+
+	log.Infof("updated identity denylist: %d denied, %d restricted (for %s)",
+		denied.len(), restricted.len(), lifetime)
+}
+
+func (d *ConfigMapDenylist) parseRules(entries string) denylistRules {
+	rules := denylistRules{
+		identities: make(map[string]struct{}),
+		namespaces: make(map[string]struct{}),
+	}
+
+	for _, line := range strings.Split(entries, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Split(line, "/")
+		switch {
+		case len(parts) == 1:
+			rules.identities[line] = struct{}{}
+		case len(parts) == 2 && parts[1] == "*":
+			rules.namespaces[parts[0]] = struct{}{}
+		case len(parts) == 2:
+			id, err := d.domain.Identity("serviceaccount", parts[1], parts[0])
+			if err != nil {
+				log.Errorf("invalid denylist entry '%s': %s", line, err)
+				continue
+			}
+			rules.identities[id] = struct{}{}
+		default:
+			log.Errorf("invalid denylist entry '%s'", line)
+		}
+	}
+
+	return rules
+}
+
+func (r denylistRules) matches(id, ns string) bool {
+	if _, ok := r.identities[id]; ok {
+		return true
+	}
+	_, ok := r.namespaces[ns]
+	return ns != "" && ok
+}
+
+func (r denylistRules) len() int {
+	return len(r.identities) + len(r.namespaces)
+}
@@ -0,0 +1,83 @@
+package identity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/linkerd/linkerd2/pkg/identity"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestConfigMapDenylist(t *testing.T) {
+	dom, err := NewTrustDomain("linkerd", "cluster.local")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	cs := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      k8s.IdentityDenylistConfigMapName,
+			Namespace: "linkerd",
+		},
+		Data: map[string]string{
+			"denied": `
+# compromised
+web.emojivoto.serviceaccount.identity.linkerd.cluster.local
+books/authors
+not/a/valid/entry
+`,
+			"restricted": `
+books/*
+web.emojivoto.serviceaccount.identity.linkerd.cluster.local
+`,
+			"restrictedLifetime": "5m",
+		},
+	})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	d, err := NewConfigMapDenylist(cs, "linkerd", dom, stop)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	testCases := []struct {
+		identity string
+		denied   bool
+		lifetime time.Duration
+	}{
+		{"web.emojivoto.serviceaccount.identity.linkerd.cluster.local", true, 0},
+		{"authors.books.serviceaccount.identity.linkerd.cluster.local", true, 0},
+		{"webapp.books.serviceaccount.identity.linkerd.cluster.local", false, 5 * time.Minute},
+		{"emoji.emojivoto.serviceaccount.identity.linkerd.cluster.local", false, 0},
+		{"webapp.books.serviceaccount.identity.other.cluster.local", false, 0},
+	}
+	for _, tc := range testCases {
+		tc := tc // pin
+		t.Run(tc.identity, func(t *testing.T) {
+			lifetime, err := d.Check(tc.identity)
+			if tc.denied {
+				if _, ok := err.(identity.Denied); !ok {
+					t.Fatalf("expected a Denied error, got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if lifetime != tc.lifetime {
+				t.Fatalf("expected lifetime %s, got %s", tc.lifetime, lifetime)
+			}
+		})
+	}
+
+	t.Run("Denies nothing without a ConfigMap", func(t *testing.T) {
+		d.update(nil)
+		if _, err := d.Check("web.emojivoto.serviceaccount.identity.linkerd.cluster.local"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+}
@@ -2,6 +2,8 @@ package identity
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/util/validation"
 )
@@ -34,3 +36,47 @@ func (d *TrustDomain) Identity(typ, nm, ns string) (string, error) {
 	id := fmt.Sprintf("%s.%s.%s.identity.%s.%s", nm, ns, typ, d.controlNS, d.domain)
 	return id, nil
 }
+
+// URI formats the SPIFFE ID for a K8s user, in the form
+// spiffe://<domain>/ns/<ns>/sa/<nm>. Only service accounts have SPIFFE IDs.
+func (d *TrustDomain) URI(typ, nm, ns string) (*url.URL, error) {
+	for _, l := range []string{typ, nm, ns} {
+		if errs := validation.IsDNS1123Label(l); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid label '%s': %s", l, errs[0])
+		}
+	}
+	if typ != "serviceaccount" {
+		return nil, fmt.Errorf("SPIFFE IDs are only supported for service accounts, not '%s'", typ)
+	}
+
+	return &url.URL{
+		Scheme: "spiffe",
+		Host:   d.domain,
+		Path:   fmt.Sprintf("/ns/%s/sa/%s", ns, nm),
+	}, nil
+}
+
+// IdentityURI returns the SPIFFE ID for an identity formatted by Identity.
+func (d *TrustDomain) IdentityURI(id string) (*url.URL, error) {
+	typ, nm, ns, err := d.parseIdentity(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.URI(typ, nm, ns)
+}
+
+// parseIdentity returns the type, name and namespace of an identity formatted
+// by Identity.
+func (d *TrustDomain) parseIdentity(id string) (string, string, string, error) {
+	suffix := fmt.Sprintf(".identity.%s.%s", d.controlNS, d.domain)
+	if !strings.HasSuffix(id, suffix) {
+		return "", "", "", fmt.Errorf("identity '%s' is not in the trust domain", id)
+	}
+	parts := strings.Split(strings.TrimSuffix(id, suffix), ".")
+	if len(parts) != 3 {
+		return "", "", "", fmt.Errorf("invalid identity '%s'", id)
+	}
+
+	return parts[2], parts[0], parts[1], nil
+}
@@ -0,0 +1,56 @@
+package identity
+
+import (
+	"testing"
+)
+
+func TestTrustDomainURI(t *testing.T) {
+	dom, err := NewTrustDomain("linkerd", "cluster.local")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	t.Run("Formats SPIFFE IDs for service accounts", func(t *testing.T) {
+		uri, err := dom.URI("serviceaccount", "foo", "ns")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if uri.String() != "spiffe://cluster.local/ns/ns/sa/foo" {
+			t.Fatalf("unexpected URI: %s", uri)
+		}
+	})
+
+	t.Run("Rejects other identity types", func(t *testing.T) {
+		if _, err := dom.URI("user", "foo", "ns"); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	testCases := []struct {
+		identity string
+		uri      string
+	}{
+		{"foo.ns.serviceaccount.identity.linkerd.cluster.local", "spiffe://cluster.local/ns/ns/sa/foo"},
+		{"foo.ns.serviceaccount.identity.other.cluster.local", ""},
+		{"foo.serviceaccount.identity.linkerd.cluster.local", ""},
+		{"foo.ns.serviceaccount.identity.linkerd.example.com", ""},
+	}
+	for _, tc := range testCases {
+		tc := tc // pin
+		t.Run(tc.identity, func(t *testing.T) {
+			uri, err := dom.IdentityURI(tc.identity)
+			if tc.uri == "" {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", uri)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if uri.String() != tc.uri {
+				t.Fatalf("expected %s, got %s", tc.uri, uri)
+			}
+		})
+	}
+}
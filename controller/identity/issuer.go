@@ -0,0 +1,162 @@
+package identity
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/linkerd/linkerd2/pkg/tls"
+)
+
+const (
+	// IssuerBackendLocal signs certificates with issuer credentials mounted
+	// into the identity service.
+	IssuerBackendLocal = "local"
+
+	// IssuerBackendCertManager signs certificates by creating cert-manager
+	// CertificateRequest resources.
+	IssuerBackendCertManager = "cert-manager"
+
+	// IssuerBackendVault signs certificates with a Vault PKI secrets engine.
+	IssuerBackendVault = "vault"
+
+	// IssuerBackendWebhook signs certificates by posting CSRs to an HTTP
+	// endpoint.
+	IssuerBackendWebhook = "webhook"
+
+	// DefaultExternalIssuerTimeout is the default time allowed for an external
+	// issuer to sign a certificate.
+	DefaultExternalIssuerTimeout = 10 * time.Second
+)
+
+// IssuerBackends lists the supported issuer backends.
+var IssuerBackends = []string{
+	IssuerBackendLocal,
+	IssuerBackendCertManager,
+	IssuerBackendVault,
+	IssuerBackendWebhook,
+}
+
+// ExternalIssuerConfig configures an issuer backend that signs certificates
+// outside of the identity service.
+type ExternalIssuerConfig struct {
+	// Backend is one of IssuerBackendCertManager, IssuerBackendVault or
+	// IssuerBackendWebhook.
+	Backend string
+
+	// Lifetime is the requested lifetime of issued certificates.
+	Lifetime time.Duration
+
+	// Timeout bounds the time spent signing a single certificate.
+	Timeout time.Duration
+
+	// TrustAnchors are the roots that issued certificates must chain to.
+	TrustAnchors *x509.CertPool
+
+	CertManager CertManagerConfig
+	Vault       VaultConfig
+	Webhook     WebhookConfig
+}
+
+// NewExternalIssuer creates a tls.Issuer for the configured backend.
+//
+// The kubernetes client and host are only used by the cert-manager backend.
+func NewExternalIssuer(cfg ExternalIssuerConfig, kubeClient *http.Client, kubeHost string) (tls.Issuer, error) {
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = DefaultExternalIssuerTimeout
+	}
+
+	switch cfg.Backend {
+	case IssuerBackendCertManager:
+		return NewCertManagerIssuer(cfg.CertManager, kubeClient, kubeHost, cfg.TrustAnchors, cfg.Lifetime, timeout)
+	case IssuerBackendVault:
+		return NewVaultIssuer(cfg.Vault, &http.Client{Timeout: timeout}, cfg.TrustAnchors, cfg.Lifetime)
+	case IssuerBackendWebhook:
+		return NewWebhookIssuer(cfg.Webhook, &http.Client{Timeout: timeout}, cfg.TrustAnchors, cfg.Lifetime)
+	default:
+		return nil, fmt.Errorf("unsupported issuer backend: %s", cfg.Backend)
+	}
+}
+
+// encodeCSR emits the DER-encoded certificate request as PEM-encoded text.
+func encodeCSR(csr *x509.CertificateRequest) ([]byte, error) {
+	if len(csr.Raw) == 0 {
+		return nil, errors.New("certificate request is not DER-encoded")
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr.Raw}), nil
+}
+
+// shorterLifetime returns max if it is set and shorter than lifetime, which
+// may be zero to use the backend's default.
+func shorterLifetime(lifetime, max time.Duration) time.Duration {
+	if max > 0 && (lifetime == 0 || max < lifetime) {
+		return max
+	}
+	return lifetime
+}
+
+// uriStrings returns the URI SANs added to a certificate request. The raw
+// request, which is signed by the requester, doesn't include them.
+func uriStrings(csr *x509.CertificateRequest) []string {
+	var uris []string
+	for _, u := range csr.URIs {
+		uris = append(uris, u.String())
+	}
+	return uris
+}
+
+// decodeIssuedCrt parses a PEM-encoded certificate and the PEM-encoded chain of
+// its issuers, ordered from leaf to root, into a Crt.
+//
+// External issuers aren't trusted to sign what they were asked to: the
+// certificate must certify the CSR's key for the CSR's identity, and chain to
+// the trust anchors.
+func decodeIssuedCrt(csr *x509.CertificateRequest, roots *x509.CertPool, crtPEM string, chainPEM ...string) (tls.Crt, error) {
+	pems := []string{strings.TrimSpace(crtPEM)}
+	for _, c := range chainPEM {
+		if c = strings.TrimSpace(c); c != "" {
+			pems = append(pems, c)
+		}
+	}
+
+	crt, err := tls.DecodePEMCrt(strings.Join(pems, "\n"))
+	if err != nil {
+		return tls.Crt{}, fmt.Errorf("failed to decode issued certificate: %s", err)
+	}
+	if err := verifyIssuedCrt(csr, roots, crt); err != nil {
+		return tls.Crt{}, fmt.Errorf("invalid issued certificate: %s", err)
+	}
+	return *crt, nil
+}
+
+func verifyIssuedCrt(csr *x509.CertificateRequest, roots *x509.CertPool, crt *tls.Crt) error {
+	if !bytes.Equal(crt.Certificate.RawSubjectPublicKeyInfo, csr.RawSubjectPublicKeyInfo) {
+		return errors.New("public key does not match the certificate request")
+	}
+
+	if len(csr.DNSNames) != 1 {
+		return errors.New("certificate request must have exactly one DNS name")
+	}
+	name := csr.DNSNames[0]
+	if len(crt.Certificate.DNSNames) != 1 || crt.Certificate.DNSNames[0] != name {
+		return fmt.Errorf("DNS names %v do not match the requested identity %s", crt.Certificate.DNSNames, name)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range crt.TrustChain {
+		intermediates.AddCert(c)
+	}
+	_, err := crt.Certificate.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		DNSName:       name,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
@@ -0,0 +1,331 @@
+package identity
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/linkerd/linkerd2/pkg/tls"
+)
+
+const testIdentity = "foo.ns.serviceaccount.identity.linkerd.cluster.local"
+
+func newTestCA(t *testing.T) *tls.CA {
+	root, err := tls.GenerateRootCAWithDefaults("root")
+	if err != nil {
+		t.Fatalf("failed to create root CA: %s", err)
+	}
+	return root
+}
+
+func newTestCSR(t *testing.T) *x509.CertificateRequest {
+	key, err := tls.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: testIdentity},
+		DNSNames: []string{testIdentity},
+	}, key)
+	if err != nil {
+		t.Fatalf("failed to create CSR: %s", err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatalf("failed to parse CSR: %s", err)
+	}
+	return csr
+}
+
+// sign decodes a PEM-encoded CSR and signs it with the CA, returning the
+// PEM-encoded leaf certificate.
+func sign(ca *tls.CA, csrPEM []byte) (string, error) {
+	block, _ := pem.Decode(csrPEM)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return "", fmt.Errorf("invalid CSR: %s", csrPEM)
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+	crt, err := ca.IssueEndEntityCrt(csr)
+	if err != nil {
+		return "", err
+	}
+	return crt.EncodeCertificatePEM(), nil
+}
+
+func checkIssued(t *testing.T, ca *tls.CA, crt tls.Crt) {
+	if err := crt.Verify(ca.Cred.Crt.CertPool(), testIdentity); err != nil {
+		t.Fatalf("issued certificate does not verify: %s", err)
+	}
+	if len(crt.TrustChain) != 1 || !crt.TrustChain[0].Equal(ca.Cred.Crt.Certificate) {
+		t.Fatalf("unexpected trust chain: %v", crt.TrustChain)
+	}
+}
+
+func TestDecodeIssuedCrt(t *testing.T) {
+	ca := newTestCA(t)
+	roots := ca.Cred.Crt.CertPool()
+	csr := newTestCSR(t)
+	issue := func(csr *x509.CertificateRequest) string {
+		crt, err := ca.IssueEndEntityCrt(csr)
+		if err != nil {
+			t.Fatalf("failed to issue certificate: %s", err)
+		}
+		return crt.EncodeCertificatePEM()
+	}
+	caPEM := ca.Cred.Crt.EncodeCertificatePEM()
+
+	t.Run("Accepts certificates for the requested key and identity", func(t *testing.T) {
+		crt, err := decodeIssuedCrt(csr, roots, issue(csr), caPEM)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		checkIssued(t, ca, crt)
+	})
+
+	t.Run("Rejects certificates for another key", func(t *testing.T) {
+		_, err := decodeIssuedCrt(csr, roots, issue(newTestCSR(t)), caPEM)
+		if err == nil || !strings.Contains(err.Error(), "public key does not match") {
+			t.Fatalf("expected key mismatch, got: %v", err)
+		}
+	})
+
+	t.Run("Rejects certificates for another identity", func(t *testing.T) {
+		other := *csr
+		other.DNSNames = []string{"bar.ns.serviceaccount.identity.linkerd.cluster.local"}
+		_, err := decodeIssuedCrt(csr, roots, issue(&other), caPEM)
+		if err == nil || !strings.Contains(err.Error(), "do not match the requested identity") {
+			t.Fatalf("expected name mismatch, got: %v", err)
+		}
+	})
+
+	t.Run("Rejects certificates that don't chain to the trust anchors", func(t *testing.T) {
+		_, err := decodeIssuedCrt(csr, newTestCA(t).Cred.Crt.CertPool(), issue(csr), caPEM)
+		if err == nil || !strings.Contains(err.Error(), "unknown authority") {
+			t.Fatalf("expected unknown authority, got: %v", err)
+		}
+	})
+}
+
+func TestVaultIssuer(t *testing.T) {
+	ca := newTestCA(t)
+
+	tokenFile, err := ioutil.TempFile("", "vault-token")
+	if err != nil {
+		t.Fatalf("failed to create token file: %s", err)
+	}
+	defer os.Remove(tokenFile.Name())
+	tokenFile.WriteString("s.secret\n")
+	tokenFile.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/pki/sign/linkerd" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":["no handler for route"]}`))
+			return
+		}
+		if r.Header.Get("X-Vault-Token") != "s.secret" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+
+		var req vaultSignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req.CommonName != testIdentity || req.AltNames != testIdentity || req.TTL != "24h0m0s" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"errors":["unexpected request: %+v"]}`, req)
+			return
+		}
+		crt, err := sign(ca, []byte(req.CSR))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var rsp vaultSignResponse
+		rsp.Data.Certificate = crt
+		rsp.Data.IssuingCA = ca.Cred.Crt.EncodeCertificatePEM()
+		json.NewEncoder(w).Encode(&rsp)
+	}))
+	defer srv.Close()
+
+	t.Run("Signs certificates", func(t *testing.T) {
+		v, err := NewVaultIssuer(VaultConfig{Addr: srv.URL, Role: "linkerd", TokenPath: tokenFile.Name()}, srv.Client(), ca.Cred.Crt.CertPool(), 24*time.Hour)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		crt, err := v.IssueEndEntityCrt(newTestCSR(t))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		checkIssued(t, ca, crt)
+	})
+
+	t.Run("Reports errors from vault", func(t *testing.T) {
+		v, err := NewVaultIssuer(VaultConfig{Addr: srv.URL, Role: "other", TokenPath: tokenFile.Name()}, srv.Client(), ca.Cred.Crt.CertPool(), 24*time.Hour)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		_, err = v.IssueEndEntityCrt(newTestCSR(t))
+		if err == nil || !strings.Contains(err.Error(), "no handler for route") {
+			t.Fatalf("expected vault error, got: %v", err)
+		}
+	})
+}
+
+func TestWebhookIssuer(t *testing.T) {
+	ca := newTestCA(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req WebhookSignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req.Identity != testIdentity {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(&WebhookSignResponse{Error: "identity not allowed"})
+			return
+		}
+		crt, err := sign(ca, []byte(req.CSR))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&WebhookSignResponse{Error: err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(&WebhookSignResponse{
+			Certificate: crt + ca.Cred.Crt.EncodeCertificatePEM(),
+		})
+	}))
+	defer srv.Close()
+
+	w, err := NewWebhookIssuer(WebhookConfig{URL: srv.URL}, srv.Client(), ca.Cred.Crt.CertPool(), time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	t.Run("Signs certificates", func(t *testing.T) {
+		crt, err := w.IssueEndEntityCrt(newTestCSR(t))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		checkIssued(t, ca, crt)
+	})
+
+	t.Run("Reports errors from the webhook", func(t *testing.T) {
+		csr := newTestCSR(t)
+		csr.DNSNames = []string{"bar.ns.serviceaccount.identity.linkerd.cluster.local"}
+		_, err := w.IssueEndEntityCrt(csr)
+		if err == nil || !strings.Contains(err.Error(), "identity not allowed") {
+			t.Fatalf("expected webhook error, got: %v", err)
+		}
+	})
+}
+
+func TestCertManagerIssuer(t *testing.T) {
+	ca := newTestCA(t)
+
+	// The stand-in API server signs CertificateRequests on the first GET after
+	// they are created, denying requests that reference an unknown issuer.
+	var mu sync.Mutex
+	requests := map[string]*certificateRequest{}
+	const path = "/apis/cert-manager.io/v1alpha2/namespaces/linkerd/certificaterequests"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == path:
+			var cr certificateRequest
+			if err := json.NewDecoder(r.Body).Decode(&cr); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			cr.Metadata.Name = fmt.Sprintf("%s%d", cr.Metadata.GenerateName, len(requests))
+			requests[cr.Metadata.Name] = &cr
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(&cr)
+
+		case strings.HasPrefix(r.URL.Path, path+"/"):
+			name := strings.TrimPrefix(r.URL.Path, path+"/")
+			cr, ok := requests[name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if r.Method == http.MethodDelete {
+				delete(requests, name)
+				w.Write([]byte(`{}`))
+				return
+			}
+
+			cr.Status.Conditions = cr.Status.Conditions[:0]
+			if cr.Spec.IssuerRef.Name != "linkerd-issuer" {
+				cr.Status.Conditions = append(cr.Status.Conditions, certificateRequestCondition{"Ready", "False", "Failed", "issuer not found"})
+			} else {
+				crt, err := sign(ca, cr.Spec.CSR)
+				if err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				cr.Status.Certificate = []byte(crt)
+				cr.Status.CA = []byte(ca.Cred.Crt.EncodeCertificatePEM())
+				cr.Status.Conditions = append(cr.Status.Conditions, certificateRequestCondition{"Ready", "True", "Issued", ""})
+			}
+			json.NewEncoder(w).Encode(cr)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	t.Run("Signs certificates", func(t *testing.T) {
+		cfg := CertManagerConfig{Namespace: "linkerd", IssuerName: "linkerd-issuer"}
+		cm, err := NewCertManagerIssuer(cfg, srv.Client(), srv.URL, ca.Cred.Crt.CertPool(), time.Hour, 5*time.Second)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		crt, err := cm.IssueEndEntityCrt(newTestCSR(t))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		checkIssued(t, ca, crt)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(requests) != 0 {
+			t.Fatalf("expected CertificateRequests to be deleted, found %d", len(requests))
+		}
+	})
+
+	t.Run("Reports failed requests", func(t *testing.T) {
+		cfg := CertManagerConfig{Namespace: "linkerd", IssuerName: "unknown"}
+		cm, err := NewCertManagerIssuer(cfg, srv.Client(), srv.URL, ca.Cred.Crt.CertPool(), time.Hour, 5*time.Second)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		_, err = cm.IssueEndEntityCrt(newTestCSR(t))
+		if err == nil || !strings.Contains(err.Error(), "issuer not found") {
+			t.Fatalf("expected failed CertificateRequest, got: %v", err)
+		}
+	})
+}
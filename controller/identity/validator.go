@@ -2,7 +2,9 @@ package identity
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/url"
 	"strings"
 
 	"github.com/linkerd/linkerd2/pkg/identity"
@@ -18,33 +20,64 @@ import (
 type K8sTokenValidator struct {
 	authn  kauthn.AuthenticationV1Interface
 	domain *TrustDomain
+
+	// audience, if set, is requested when reviewing tokens so that projected
+	// tokens scoped to the identity service are accepted.
+	audience string
+
+	// requireAudience rejects tokens that are not bound to audience, such as
+	// the default service account tokens mounted into every pod.
+	requireAudience bool
 }
 
 // NewK8sTokenValidator takes a kubernetes client and trust domain to create a
 // K8sTokenValidator.
 //
+// If audience is set, tokens bound to that audience are accepted. Unless
+// requireAudience is also set, tokens without an audience continue to be
+// accepted so that proxies can migrate to projected tokens gradually.
+//
 // The kubernetes client is used immediately to validate that the client has
 // sufficient privileges to perform token reviews. An error is returned if this
 // access check fails.
 func NewK8sTokenValidator(
 	k8s k8s.Interface,
 	domain *TrustDomain,
+	audience string,
+	requireAudience bool,
 ) (identity.Validator, error) {
+	if requireAudience && audience == "" {
+		return nil, errors.New("a token audience must be configured when audiences are required")
+	}
 	if err := checkAccess(k8s.AuthorizationV1()); err != nil {
 		return nil, err
 	}
 
 	authn := k8s.AuthenticationV1()
-	return &K8sTokenValidator{authn, domain}, nil
+	return &K8sTokenValidator{authn, domain, audience, requireAudience}, nil
 }
 
 // Validate accepts kubernetes bearer tokens and returns a DNS-form linkerd ID.
 func (k *K8sTokenValidator) Validate(_ context.Context, tok []byte) (string, error) {
-	// TODO: Set/check `audience`
-	tr := kauthnApi.TokenReview{Spec: kauthnApi.TokenReviewSpec{Token: string(tok)}}
-	rvw, err := k.authn.TokenReviews().Create(&tr)
-	if err != nil {
-		return "", err
+	var rvw *kauthnApi.TokenReview
+	var err error
+	if k.audience != "" {
+		rvw, err = k.review(tok, []string{k.audience})
+		if err != nil {
+			return "", err
+		}
+		if rvw.Status.Authenticated && !hasAudience(rvw.Status.Audiences, k.audience) {
+			// The API server did not bind the token to the requested audience.
+			rvw.Status.Authenticated = false
+		}
+	}
+
+	if !k.requireAudience && (rvw == nil || !rvw.Status.Authenticated) {
+		// Fall back to reviewing the token against the API server's audiences.
+		rvw, err = k.review(tok, nil)
+		if err != nil {
+			return "", err
+		}
 	}
 
 	if rvw.Status.Error != "" {
@@ -70,6 +103,30 @@ func (k *K8sTokenValidator) Validate(_ context.Context, tok []byte) (string, err
 	return k.domain.Identity(uns[0], uns[2], uns[1])
 }
 
+// URI returns the SPIFFE ID for an identity returned by Validate.
+func (k *K8sTokenValidator) URI(id string) (*url.URL, error) {
+	return k.domain.IdentityURI(id)
+}
+
+func (k *K8sTokenValidator) review(tok []byte, audiences []string) (*kauthnApi.TokenReview, error) {
+	tr := kauthnApi.TokenReview{
+		Spec: kauthnApi.TokenReviewSpec{
+			Token:     string(tok),
+			Audiences: audiences,
+		},
+	}
+	return k.authn.TokenReviews().Create(&tr)
+}
+
+func hasAudience(audiences []string, audience string) bool {
+	for _, a := range audiences {
+		if a == audience {
+			return true
+		}
+	}
+	return false
+}
+
 func checkAccess(authz kauthz.AuthorizationV1Interface) error {
 	r := &kauthzApi.SelfSubjectAccessReview{
 		Spec: kauthzApi.SelfSubjectAccessReviewSpec{
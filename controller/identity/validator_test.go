@@ -0,0 +1,111 @@
+package identity
+
+import (
+	"context"
+	"testing"
+
+	"github.com/linkerd/linkerd2/pkg/identity"
+	kauthnApi "k8s.io/api/authentication/v1"
+	kauthzApi "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testAudience = "identity.l5d.io"
+
+// newFakeClientset returns a clientset that reviews two tokens: "projected" is
+// only valid for testAudience, and "default" is only valid for the API
+// server's own audiences.
+func newFakeClientset() *fake.Clientset {
+	cs := fake.NewSimpleClientset()
+	cs.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		rvw := action.(k8stesting.CreateAction).GetObject().(*kauthzApi.SelfSubjectAccessReview)
+		rvw.Status.Allowed = true
+		return true, rvw, nil
+	})
+	cs.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		rvw := action.(k8stesting.CreateAction).GetObject().(*kauthnApi.TokenReview)
+		audiences := rvw.Spec.Audiences
+		if len(audiences) == 0 {
+			audiences = []string{"https://kubernetes.default.svc"}
+		}
+
+		var tokenAudience string
+		switch rvw.Spec.Token {
+		case "projected":
+			tokenAudience = testAudience
+		case "default":
+			tokenAudience = "https://kubernetes.default.svc"
+		default:
+			rvw.Status.Error = "invalid bearer token"
+			return true, rvw, nil
+		}
+
+		if !hasAudience(audiences, tokenAudience) {
+			rvw.Status.Error = "token audiences are invalid for the target audiences"
+			return true, rvw, nil
+		}
+		rvw.Status.Authenticated = true
+		rvw.Status.Audiences = []string{tokenAudience}
+		rvw.Status.User.Username = "system:serviceaccount:ns:foo"
+		return true, rvw, nil
+	})
+	return cs
+}
+
+func TestK8sTokenValidator(t *testing.T) {
+	dom, err := NewTrustDomain("linkerd", "cluster.local")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := "foo.ns.serviceaccount.identity.linkerd.cluster.local"
+
+	testCases := []struct {
+		name            string
+		audience        string
+		requireAudience bool
+		token           string
+		valid           bool
+	}{
+		{"default token without audience", "", false, "default", true},
+		{"projected token without audience", "", false, "projected", false},
+		{"default token with optional audience", testAudience, false, "default", true},
+		{"projected token with optional audience", testAudience, false, "projected", true},
+		{"default token with required audience", testAudience, true, "default", false},
+		{"projected token with required audience", testAudience, true, "projected", true},
+		{"invalid token", testAudience, false, "bogus", false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // pin
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := NewK8sTokenValidator(newFakeClientset(), dom, tc.audience, tc.requireAudience)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			id, err := v.Validate(context.Background(), []byte(tc.token))
+			if !tc.valid {
+				switch err.(type) {
+				case identity.InvalidToken, identity.NotAuthenticated:
+				default:
+					t.Fatalf("expected token to be rejected, got: %v (%v)", id, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if id != expected {
+				t.Fatalf("expected identity %s, got %s", expected, id)
+			}
+		})
+	}
+
+	t.Run("requires an audience when audiences are required", func(t *testing.T) {
+		if _, err := NewK8sTokenValidator(newFakeClientset(), dom, "", true); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package identity
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/linkerd/linkerd2/pkg/tls"
+)
+
+type (
+	// VaultConfig configures a VaultIssuer.
+	VaultConfig struct {
+		// Addr is the base URL of the Vault server, e.g. https://vault:8200.
+		Addr string
+
+		// Mount is the path at which the PKI secrets engine is mounted.
+		Mount string
+
+		// Role is the PKI role used to sign certificates.
+		Role string
+
+		// TokenPath is the path to a file containing the Vault token. The file is
+		// read for every request so that the token may be renewed externally.
+		TokenPath string
+	}
+
+	// VaultIssuer implements tls.Issuer by signing CSRs with the `sign`
+	// endpoint of a Vault PKI secrets engine.
+	VaultIssuer struct {
+		cfg      VaultConfig
+		client   *http.Client
+		roots    *x509.CertPool
+		lifetime time.Duration
+	}
+
+	vaultSignRequest struct {
+		CSR        string `json:"csr"`
+		CommonName string `json:"common_name"`
+		AltNames   string `json:"alt_names,omitempty"`
+		URISANs    string `json:"uri_sans,omitempty"`
+		TTL        string `json:"ttl,omitempty"`
+		Format     string `json:"format"`
+	}
+
+	vaultSignResponse struct {
+		Data struct {
+			Certificate string   `json:"certificate"`
+			IssuingCA   string   `json:"issuing_ca"`
+			CAChain     []string `json:"ca_chain"`
+		} `json:"data"`
+		Errors []string `json:"errors"`
+	}
+)
+
+// NewVaultIssuer creates a VaultIssuer whose certificates must chain to roots.
+func NewVaultIssuer(cfg VaultConfig, client *http.Client, roots *x509.CertPool, lifetime time.Duration) (*VaultIssuer, error) {
+	if cfg.Addr == "" {
+		return nil, errors.New("vault address must be specified")
+	}
+	if cfg.Role == "" {
+		return nil, errors.New("vault PKI role must be specified")
+	}
+	if roots == nil {
+		return nil, errors.New("trust anchors must be specified")
+	}
+	if cfg.Mount == "" {
+		cfg.Mount = "pki"
+	}
+	return &VaultIssuer{cfg, client, roots, lifetime}, nil
+}
+
+// IssueEndEntityCrt signs the certificate request with Vault.
+func (v *VaultIssuer) IssueEndEntityCrt(csr *x509.CertificateRequest) (tls.Crt, error) {
+	return v.issue(csr, v.lifetime)
+}
+
+// IssueEndEntityCrtWithLifetime signs the certificate request with Vault,
+// requesting a TTL of no more than lifetime.
+func (v *VaultIssuer) IssueEndEntityCrtWithLifetime(csr *x509.CertificateRequest, lifetime time.Duration) (tls.Crt, error) {
+	return v.issue(csr, shorterLifetime(v.lifetime, lifetime))
+}
+
+func (v *VaultIssuer) issue(csr *x509.CertificateRequest, lifetime time.Duration) (tls.Crt, error) {
+	csrPEM, err := encodeCSR(csr)
+	if err != nil {
+		return tls.Crt{}, err
+	}
+
+	token, err := ioutil.ReadFile(v.cfg.TokenPath)
+	if err != nil {
+		return tls.Crt{}, fmt.Errorf("failed to read vault token: %s", err)
+	}
+
+	sr := vaultSignRequest{
+		CSR:        string(csrPEM),
+		CommonName: csr.Subject.CommonName,
+		AltNames:   strings.Join(csr.DNSNames, ","),
+		URISANs:    strings.Join(uriStrings(csr), ","),
+		Format:     "pem",
+	}
+	if sr.CommonName == "" && len(csr.DNSNames) > 0 {
+		sr.CommonName = csr.DNSNames[0]
+	}
+	if lifetime != 0 {
+		sr.TTL = lifetime.String()
+	}
+	body, err := json.Marshal(&sr)
+	if err != nil {
+		return tls.Crt{}, err
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/sign/%s",
+		strings.TrimSuffix(v.cfg.Addr, "/"), strings.Trim(v.cfg.Mount, "/"), v.cfg.Role)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return tls.Crt{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", strings.TrimSpace(string(token)))
+
+	rsp, err := v.client.Do(req)
+	if err != nil {
+		return tls.Crt{}, fmt.Errorf("vault request failed: %s", err)
+	}
+	defer rsp.Body.Close()
+
+	var sign vaultSignResponse
+	if err := json.NewDecoder(rsp.Body).Decode(&sign); err != nil {
+		return tls.Crt{}, fmt.Errorf("failed to decode vault response (HTTP %d): %s", rsp.StatusCode, err)
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return tls.Crt{}, fmt.Errorf("vault failed to sign certificate (HTTP %d): %s",
+			rsp.StatusCode, strings.Join(sign.Errors, "; "))
+	}
+
+	chain := sign.Data.CAChain
+	if len(chain) == 0 {
+		chain = []string{sign.Data.IssuingCA}
+	}
+	return decodeIssuedCrt(csr, v.roots, sign.Data.Certificate, chain...)
+}
@@ -0,0 +1,123 @@
+package identity
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/linkerd/linkerd2/pkg/tls"
+)
+
+type (
+	// WebhookConfig configures a WebhookIssuer.
+	WebhookConfig struct {
+		// URL is the endpoint to which signing requests are posted.
+		URL string
+	}
+
+	// WebhookIssuer implements tls.Issuer by posting certificate signing
+	// requests to an HTTP endpoint.
+	//
+	// The endpoint receives a JSON-encoded WebhookSignRequest and must respond
+	// with a JSON-encoded WebhookSignResponse.
+	WebhookIssuer struct {
+		url      string
+		client   *http.Client
+		roots    *x509.CertPool
+		lifetime time.Duration
+	}
+
+	// WebhookSignRequest is the body of requests sent by a WebhookIssuer.
+	WebhookSignRequest struct {
+		// CSR is the PEM-encoded certificate signing request.
+		CSR string `json:"csr"`
+
+		// Identity is the DNS-like identity being certified.
+		Identity string `json:"identity"`
+
+		// URIs are the URI SANs to include in the certificate, if any.
+		URIs []string `json:"uris,omitempty"`
+
+		// Lifetime is the requested validity of the certificate, in seconds.
+		Lifetime int64 `json:"lifetimeSeconds,omitempty"`
+	}
+
+	// WebhookSignResponse is the body of responses expected by a WebhookIssuer.
+	WebhookSignResponse struct {
+		// Certificate is the PEM-encoded certificate followed by its chain of
+		// issuer certificates, ordered from leaf to root.
+		Certificate string `json:"certificate"`
+
+		// Error describes why the certificate could not be signed.
+		Error string `json:"error,omitempty"`
+	}
+)
+
+// NewWebhookIssuer creates a WebhookIssuer whose certificates must chain to
+// roots.
+func NewWebhookIssuer(cfg WebhookConfig, client *http.Client, roots *x509.CertPool, lifetime time.Duration) (*WebhookIssuer, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("webhook URL must be specified")
+	}
+	if roots == nil {
+		return nil, errors.New("trust anchors must be specified")
+	}
+	return &WebhookIssuer{cfg.URL, client, roots, lifetime}, nil
+}
+
+// IssueEndEntityCrt posts the certificate request to the webhook.
+func (w *WebhookIssuer) IssueEndEntityCrt(csr *x509.CertificateRequest) (tls.Crt, error) {
+	return w.issue(csr, w.lifetime)
+}
+
+// IssueEndEntityCrtWithLifetime posts the certificate request to the webhook,
+// requesting a lifetime of no more than lifetime.
+func (w *WebhookIssuer) IssueEndEntityCrtWithLifetime(csr *x509.CertificateRequest, lifetime time.Duration) (tls.Crt, error) {
+	return w.issue(csr, shorterLifetime(w.lifetime, lifetime))
+}
+
+func (w *WebhookIssuer) issue(csr *x509.CertificateRequest, lifetime time.Duration) (tls.Crt, error) {
+	csrPEM, err := encodeCSR(csr)
+	if err != nil {
+		return tls.Crt{}, err
+	}
+
+	sr := WebhookSignRequest{
+		CSR:      string(csrPEM),
+		URIs:     uriStrings(csr),
+		Lifetime: int64(lifetime.Seconds()),
+	}
+	if len(csr.DNSNames) > 0 {
+		sr.Identity = csr.DNSNames[0]
+	}
+	body, err := json.Marshal(&sr)
+	if err != nil {
+		return tls.Crt{}, err
+	}
+
+	rsp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return tls.Crt{}, fmt.Errorf("webhook request failed: %s", err)
+	}
+	defer rsp.Body.Close()
+
+	rspBody, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return tls.Crt{}, err
+	}
+
+	var sign WebhookSignResponse
+	if err := json.Unmarshal(rspBody, &sign); err != nil {
+		return tls.Crt{}, fmt.Errorf("failed to decode webhook response (HTTP %d): %s", rsp.StatusCode, err)
+	}
+	if rsp.StatusCode != http.StatusOK || sign.Error != "" {
+		return tls.Crt{}, fmt.Errorf("webhook failed to sign certificate (HTTP %d): %s", rsp.StatusCode, sign.Error)
+	}
+
+	return decodeIssuedCrt(csr, w.roots, sign.Certificate)
+}
@@ -0,0 +1,334 @@
+package identity
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	wiv1alpha1 "github.com/linkerd/linkerd2/controller/gen/apis/workloadidentity/v1alpha1"
+	wiclient "github.com/linkerd/linkerd2/controller/gen/client/clientset/versioned/typed/workloadidentity/v1alpha1"
+	"github.com/linkerd/linkerd2/pkg/identity"
+	log "github.com/sirupsen/logrus"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// BootstrapTokenPrefix prefixes bootstrap tokens, which are formatted as
+	// l5d-bootstrap.<namespace>.<name>.<secret>, where namespace and name
+	// identify a WorkloadIdentity.
+	BootstrapTokenPrefix = "l5d-bootstrap."
+
+	// AttestationPrefix prefixes attestation documents, which are formatted as
+	// l5d-attest.<payload>.<signature>, where payload is a base64url-encoded
+	// JSON Attestation and signature is the base64url-encoded signature of the
+	// encoded payload by the attestation key.
+	//
+	// RSA signatures use PKCS#1 v1.5 and ECDSA signatures are ASN.1-encoded;
+	// both are over the SHA-256 digest of the encoded payload.
+	AttestationPrefix = "l5d-attest."
+
+	// bindAttempts is the number of times binding a bootstrap token is
+	// attempted when the WorkloadIdentity is modified concurrently.
+	bindAttempts = 3
+)
+
+type (
+	// Attestation is the payload of an attestation document.
+	Attestation struct {
+		// Namespace and Name identify the WorkloadIdentity being attested.
+		Namespace string `json:"namespace"`
+		Name      string `json:"name"`
+
+		// Expires is the time, in seconds since the UNIX epoch, after which the
+		// attestation is no longer valid.
+		Expires int64 `json:"expires"`
+	}
+
+	// WorkloadValidator implements identity.Validator for workloads that run
+	// outside of Kubernetes and are registered with WorkloadIdentity
+	// resources. Workloads present either a single-use bootstrap token or an
+	// attestation document signed by a configured key. All other tokens are
+	// validated by the next Validator.
+	//
+	// The identity service account must be allowed to get workloadidentities
+	// and to update their status.
+	WorkloadValidator struct {
+		next   identity.Validator
+		client wiclient.IdentityV1alpha1Interface
+		domain *TrustDomain
+
+		// attestationKey, if set, verifies attestation documents.
+		attestationKey crypto.PublicKey
+
+		// audit, if set, records every use of a bootstrap token or attestation.
+		audit *log.Logger
+	}
+
+	// ecdsaSignature is the ASN.1 form of an ECDSA signature.
+	ecdsaSignature struct {
+		R, S *big.Int
+	}
+)
+
+// NewWorkloadValidator creates a WorkloadValidator that looks up
+// WorkloadIdentity resources with client. If attestationKey is nil,
+// attestation documents are rejected.
+func NewWorkloadValidator(
+	client wiclient.IdentityV1alpha1Interface,
+	domain *TrustDomain,
+	attestationKey crypto.PublicKey,
+	next identity.Validator,
+) (*WorkloadValidator, error) {
+	switch attestationKey.(type) {
+	case nil, *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+	default:
+		return nil, fmt.Errorf("unsupported attestation key type: %T", attestationKey)
+	}
+
+	return &WorkloadValidator{
+		next:           next,
+		client:         client,
+		domain:         domain,
+		attestationKey: attestationKey,
+	}, nil
+}
+
+// EnableAuditLog configures the validator to write a JSON-formatted record of
+// every use of a bootstrap token or attestation document to w.
+//
+// It must be called before the validator is used.
+func (w *WorkloadValidator) EnableAuditLog(wr io.Writer) {
+	audit := log.New()
+	audit.Out = wr
+	audit.Formatter = &log.JSONFormatter{}
+	audit.Level = log.InfoLevel
+	w.audit = audit
+}
+
+// Validate validates attestation documents and delegates tokens that aren't
+// bootstrap tokens to the next Validator. Bootstrap tokens can only be
+// validated with ValidateCSR.
+func (w *WorkloadValidator) Validate(ctx context.Context, tok []byte) (string, error) {
+	switch t := string(tok); {
+	case strings.HasPrefix(t, BootstrapTokenPrefix):
+		return "", identity.InvalidToken{Reason: "bootstrap tokens must be presented with a certificate signing request"}
+	case strings.HasPrefix(t, AttestationPrefix):
+		return w.validateAttestation(strings.TrimPrefix(t, AttestationPrefix))
+	default:
+		return w.next.Validate(ctx, tok)
+	}
+}
+
+// ValidateCSR validates bootstrap tokens, binding each to the key of the first
+// CSR in which it is presented, and attestation documents. Other tokens are
+// delegated to the next Validator.
+func (w *WorkloadValidator) ValidateCSR(ctx context.Context, tok []byte, csr *x509.CertificateRequest) (string, error) {
+	switch t := string(tok); {
+	case strings.HasPrefix(t, BootstrapTokenPrefix):
+		return w.validateBootstrapToken(strings.TrimPrefix(t, BootstrapTokenPrefix), csr)
+	case strings.HasPrefix(t, AttestationPrefix):
+		return w.validateAttestation(strings.TrimPrefix(t, AttestationPrefix))
+	default:
+		if next, ok := w.next.(identity.CSRValidator); ok {
+			return next.ValidateCSR(ctx, tok, csr)
+		}
+		return w.next.Validate(ctx, tok)
+	}
+}
+
+// URI returns the SPIFFE ID for an identity returned by Validate.
+func (w *WorkloadValidator) URI(id string) (*url.URL, error) {
+	return w.domain.IdentityURI(id)
+}
+
+func (w *WorkloadValidator) validateBootstrapToken(tok string, csr *x509.CertificateRequest) (string, error) {
+	parts := strings.SplitN(tok, ".", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", identity.InvalidToken{Reason: "malformed bootstrap token"}
+	}
+	ns, name := parts[0], parts[1]
+	digest := sha256.Sum256([]byte(parts[2]))
+	keyDigest := sha256.Sum256(csr.RawSubjectPublicKeyInfo)
+	keySHA256 := hex.EncodeToString(keyDigest[:])
+
+	for attempt := 1; ; attempt++ {
+		wi, err := w.getWorkloadIdentity(ns, name, "bootstrap")
+		if err != nil {
+			return "", err
+		}
+
+		var token *wiv1alpha1.BootstrapToken
+		for i, t := range wi.Spec.BootstrapTokens {
+			expected, err := hex.DecodeString(t.SHA256)
+			if err == nil && subtle.ConstantTimeCompare(expected, digest[:]) == 1 {
+				token = &wi.Spec.BootstrapTokens[i]
+				break
+			}
+		}
+		if token == nil {
+			return "", w.reject(wi, "bootstrap", "", "unknown bootstrap token")
+		}
+		if token.Revoked {
+			return "", w.reject(wi, "bootstrap", token.Name, "bootstrap token is revoked")
+		}
+
+		for _, b := range wi.Status.BoundTokens {
+			if b.Name != token.Name {
+				continue
+			}
+			if b.KeySHA256 != keySHA256 {
+				return "", w.reject(wi, "bootstrap", token.Name, "bootstrap token is bound to another key")
+			}
+			w.record(wi, "bootstrap", token.Name, "bootstrap token used")
+			return w.workloadIdentity(wi)
+		}
+
+		// The token hasn't been used, so bind it to the requester's key.
+		if token.Expires != nil && token.Expires.Time.Before(time.Now()) {
+			return "", w.reject(wi, "bootstrap", token.Name, "bootstrap token has expired")
+		}
+		wi.Status.BoundTokens = append(wi.Status.BoundTokens, wiv1alpha1.BoundToken{
+			Name:      token.Name,
+			KeySHA256: keySHA256,
+			BoundAt:   metav1.Now(),
+		})
+		if _, err := w.client.WorkloadIdentities(ns).UpdateStatus(wi); err != nil {
+			if kerrors.IsConflict(err) && attempt < bindAttempts {
+				continue
+			}
+			return "", fmt.Errorf("failed to bind bootstrap token %s for %s/%s: %s", token.Name, ns, name, err)
+		}
+		w.record(wi, "bootstrap", token.Name, "bootstrap token bound")
+		return w.workloadIdentity(wi)
+	}
+}
+
+func (w *WorkloadValidator) validateAttestation(doc string) (string, error) {
+	if w.attestationKey == nil {
+		return "", identity.InvalidToken{Reason: "attestation documents are not accepted"}
+	}
+
+	parts := strings.Split(doc, ".")
+	if len(parts) != 2 {
+		return "", identity.InvalidToken{Reason: "malformed attestation document"}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", identity.InvalidToken{Reason: fmt.Sprintf("malformed attestation payload: %s", err)}
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", identity.InvalidToken{Reason: fmt.Sprintf("malformed attestation signature: %s", err)}
+	}
+	if !verifySignature(w.attestationKey, []byte(parts[0]), sig) {
+		log.Infof("attestation document has an invalid signature")
+		return "", identity.NotAuthenticated{}
+	}
+
+	var att Attestation
+	if err := json.Unmarshal(payload, &att); err != nil {
+		return "", identity.InvalidToken{Reason: fmt.Sprintf("invalid attestation: %s", err)}
+	}
+	if att.Namespace == "" || att.Name == "" {
+		return "", identity.InvalidToken{Reason: "attestation does not name a workload"}
+	}
+
+	wi, err := w.getWorkloadIdentity(att.Namespace, att.Name, "attestation")
+	if err != nil {
+		return "", err
+	}
+	if time.Unix(att.Expires, 0).Before(time.Now()) {
+		return "", w.reject(wi, "attestation", "", "attestation has expired")
+	}
+	w.record(wi, "attestation", "", "attestation used")
+	return w.workloadIdentity(wi)
+}
+
+// getWorkloadIdentity fetches a WorkloadIdentity, returning NotAuthenticated
+// if it doesn't exist or is revoked.
+func (w *WorkloadValidator) getWorkloadIdentity(ns, name, method string) (*wiv1alpha1.WorkloadIdentity, error) {
+	wi, err := w.client.WorkloadIdentities(ns).Get(name, metav1.GetOptions{})
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			wi = &wiv1alpha1.WorkloadIdentity{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}
+			return nil, w.reject(wi, method, "", "workload is not registered")
+		}
+		return nil, err
+	}
+	if wi.Spec.Revoked {
+		return nil, w.reject(wi, method, "", "workload is revoked")
+	}
+	return wi, nil
+}
+
+func (w *WorkloadValidator) workloadIdentity(wi *wiv1alpha1.WorkloadIdentity) (string, error) {
+	id, err := w.domain.Identity("serviceaccount", wi.Spec.ServiceAccount, wi.GetNamespace())
+	if err != nil {
+		return "", fmt.Errorf("invalid WorkloadIdentity %s/%s: %s", wi.GetNamespace(), wi.GetName(), err)
+	}
+	return id, nil
+}
+
+// reject records a rejected token and returns a NotAuthenticated error.
+func (w *WorkloadValidator) reject(wi *wiv1alpha1.WorkloadIdentity, method, token, reason string) error {
+	log.Infof("rejected %s for %s/%s: %s", method, wi.GetNamespace(), wi.GetName(), reason)
+	if w.audit != nil {
+		w.audit.WithFields(w.auditFields(wi, method, token)).WithField("reason", reason).Warn("workload rejected")
+	}
+	return identity.NotAuthenticated{}
+}
+
+// record records an accepted token.
+func (w *WorkloadValidator) record(wi *wiv1alpha1.WorkloadIdentity, method, token, event string) {
+	log.Infof("%s for %s/%s", event, wi.GetNamespace(), wi.GetName())
+	if w.audit != nil {
+		w.audit.WithFields(w.auditFields(wi, method, token)).Info(event)
+	}
+}
+
+func (w *WorkloadValidator) auditFields(wi *wiv1alpha1.WorkloadIdentity, method, token string) log.Fields {
+	fields := log.Fields{
+		"workload": fmt.Sprintf("%s/%s", wi.GetNamespace(), wi.GetName()),
+		"method":   method,
+	}
+	if token != "" {
+		fields["token"] = token
+	}
+	return fields
+}
+
+// verifySignature verifies sig as a signature of msg by key.
+func verifySignature(key crypto.PublicKey, msg, sig []byte) bool {
+	digest := sha256.Sum256(msg)
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, msg, sig)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil
+	case *ecdsa.PublicKey:
+		var s ecdsaSignature
+		rest, err := asn1.Unmarshal(sig, &s)
+		if err != nil || len(rest) > 0 || s.R == nil || s.S == nil {
+			return false
+		}
+		return ecdsa.Verify(k, digest[:], s.R, s.S)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,215 @@
+package identity
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	wiv1alpha1 "github.com/linkerd/linkerd2/controller/gen/apis/workloadidentity/v1alpha1"
+	"github.com/linkerd/linkerd2/controller/gen/client/clientset/versioned/fake"
+	"github.com/linkerd/linkerd2/pkg/identity"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const vmIdentity = "vm-sa.ns.serviceaccount.identity.linkerd.cluster.local"
+
+func tokenSHA256(secret string) string {
+	digest := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(digest[:])
+}
+
+func newTestWorkloadValidator(t *testing.T, key ed25519.PublicKey, next identity.Validator) (*WorkloadValidator, *fake.Clientset) {
+	dom, err := NewTrustDomain("linkerd", "cluster.local")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expired := metav1.NewTime(time.Now().Add(-time.Hour))
+	cs := fake.NewSimpleClientset(
+		&wiv1alpha1.WorkloadIdentity{
+			ObjectMeta: metav1.ObjectMeta{Name: "vm", Namespace: "ns"},
+			Spec: wiv1alpha1.WorkloadIdentitySpec{
+				ServiceAccount: "vm-sa",
+				BootstrapTokens: []wiv1alpha1.BootstrapToken{
+					{Name: "first", SHA256: tokenSHA256("s3cret")},
+					{Name: "expired", SHA256: tokenSHA256("old"), Expires: &expired},
+					{Name: "revoked", SHA256: tokenSHA256("leaked"), Revoked: true},
+				},
+			},
+		},
+		&wiv1alpha1.WorkloadIdentity{
+			ObjectMeta: metav1.ObjectMeta{Name: "decommissioned", Namespace: "ns"},
+			Spec: wiv1alpha1.WorkloadIdentitySpec{
+				ServiceAccount: "vm-sa",
+				BootstrapTokens: []wiv1alpha1.BootstrapToken{
+					{Name: "first", SHA256: tokenSHA256("s3cret")},
+				},
+				Revoked: true,
+			},
+		},
+	)
+
+	var attestationKey interface{}
+	if key != nil {
+		attestationKey = key
+	}
+	v, err := NewWorkloadValidator(cs.IdentityV1alpha1(), dom, attestationKey, next)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return v, cs
+}
+
+func expectRejected(t *testing.T, id string, err error) {
+	t.Helper()
+	switch err.(type) {
+	case identity.InvalidToken, identity.NotAuthenticated:
+	default:
+		t.Fatalf("expected token to be rejected, got: %v (%v)", id, err)
+	}
+}
+
+func TestWorkloadValidatorBootstrapTokens(t *testing.T) {
+	v, cs := newTestWorkloadValidator(t, nil, nil)
+	ctx := context.Background()
+	csr := newTestCSR(t)
+
+	t.Run("binds a token on first use", func(t *testing.T) {
+		id, err := v.ValidateCSR(ctx, []byte("l5d-bootstrap.ns.vm.s3cret"), csr)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if id != vmIdentity {
+			t.Fatalf("expected identity %s, got %s", vmIdentity, id)
+		}
+
+		wi, err := cs.IdentityV1alpha1().WorkloadIdentities("ns").Get("vm", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(wi.Status.BoundTokens) != 1 || wi.Status.BoundTokens[0].Name != "first" {
+			t.Fatalf("expected token to be bound, got: %+v", wi.Status.BoundTokens)
+		}
+	})
+
+	t.Run("accepts a bound token with the same key", func(t *testing.T) {
+		id, err := v.ValidateCSR(ctx, []byte("l5d-bootstrap.ns.vm.s3cret"), csr)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if id != vmIdentity {
+			t.Fatalf("expected identity %s, got %s", vmIdentity, id)
+		}
+	})
+
+	testCases := []struct {
+		name  string
+		token string
+	}{
+		{"rejects a bound token with another key", "l5d-bootstrap.ns.vm.s3cret"},
+		{"rejects an unknown token", "l5d-bootstrap.ns.vm.guess"},
+		{"rejects an expired token", "l5d-bootstrap.ns.vm.old"},
+		{"rejects a revoked token", "l5d-bootstrap.ns.vm.leaked"},
+		{"rejects a revoked workload", "l5d-bootstrap.ns.decommissioned.s3cret"},
+		{"rejects an unregistered workload", "l5d-bootstrap.ns.unknown.s3cret"},
+		{"rejects a malformed token", "l5d-bootstrap.ns.vm"},
+	}
+	for _, tc := range testCases {
+		tc := tc // pin
+		t.Run(tc.name, func(t *testing.T) {
+			id, err := v.ValidateCSR(ctx, []byte(tc.token), newTestCSR(t))
+			expectRejected(t, id, err)
+		})
+	}
+
+	t.Run("requires a CSR", func(t *testing.T) {
+		id, err := v.Validate(ctx, []byte("l5d-bootstrap.ns.vm.s3cret"))
+		expectRejected(t, id, err)
+	})
+}
+
+func TestWorkloadValidatorAttestations(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, otherPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	attest := func(key ed25519.PrivateKey, att Attestation) string {
+		payload, err := json.Marshal(att)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		encoded := base64.RawURLEncoding.EncodeToString(payload)
+		sig := ed25519.Sign(key, []byte(encoded))
+		return AttestationPrefix + encoded + "." + base64.RawURLEncoding.EncodeToString(sig)
+	}
+	valid := time.Now().Add(time.Hour).Unix()
+
+	v, _ := newTestWorkloadValidator(t, pub, nil)
+
+	t.Run("accepts a signed attestation", func(t *testing.T) {
+		tok := attest(priv, Attestation{Namespace: "ns", Name: "vm", Expires: valid})
+		id, err := v.Validate(context.Background(), []byte(tok))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if id != vmIdentity {
+			t.Fatalf("expected identity %s, got %s", vmIdentity, id)
+		}
+	})
+
+	testCases := []struct {
+		name  string
+		token string
+	}{
+		{"rejects another signer", attest(otherPriv, Attestation{Namespace: "ns", Name: "vm", Expires: valid})},
+		{"rejects an expired attestation", attest(priv, Attestation{Namespace: "ns", Name: "vm", Expires: time.Now().Add(-time.Minute).Unix()})},
+		{"rejects a revoked workload", attest(priv, Attestation{Namespace: "ns", Name: "decommissioned", Expires: valid})},
+		{"rejects an unregistered workload", attest(priv, Attestation{Namespace: "ns", Name: "unknown", Expires: valid})},
+		{"rejects a malformed attestation", AttestationPrefix + "bogus"},
+	}
+	for _, tc := range testCases {
+		tc := tc // pin
+		t.Run(tc.name, func(t *testing.T) {
+			id, err := v.ValidateCSR(context.Background(), []byte(tc.token), newTestCSR(t))
+			expectRejected(t, id, err)
+		})
+	}
+
+	t.Run("rejects attestations without a key", func(t *testing.T) {
+		v, _ := newTestWorkloadValidator(t, nil, nil)
+		tok := attest(priv, Attestation{Namespace: "ns", Name: "vm", Expires: valid})
+		id, err := v.Validate(context.Background(), []byte(tok))
+		expectRejected(t, id, err)
+	})
+}
+
+func TestWorkloadValidatorDelegates(t *testing.T) {
+	dom, err := NewTrustDomain("linkerd", "cluster.local")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	next, err := NewK8sTokenValidator(newFakeClientset(), dom, "", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	v, _ := newTestWorkloadValidator(t, nil, next)
+
+	id, err := v.ValidateCSR(context.Background(), []byte("default"), newTestCSR(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if id != testIdentity {
+		t.Fatalf("expected identity %s, got %s", testIdentity, id)
+	}
+}
@@ -0,0 +1,258 @@
+package healthcheck
+
+import (
+	"context"
+	cryptotls "crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	"github.com/linkerd/linkerd2/pkg/tls"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// CertExpiryWarningThreshold is the remaining lifetime below which the
+	// trust anchors, the issuer certificate and the webhook certificates are
+	// reported as close to expiry.
+	CertExpiryWarningThreshold = 60 * 24 * time.Hour
+
+	// proxyCertFetchConcurrency is the number of proxies whose certificates
+	// are fetched at a time.
+	proxyCertFetchConcurrency = 10
+
+	// proxyCertCheckTimeout bounds the data plane certificates check, which
+	// port-forwards to every proxy and so takes longer than most checks.
+	proxyCertCheckTimeout = 2 * time.Minute
+)
+
+// FetchProxyCertificate performs a TLS handshake with the inbound port of a
+// pod's proxy, through a port-forward, and returns the certificate it serves
+// for identity. The certificate is not verified. It gives up once ctx
+// expires.
+func FetchProxyCertificate(ctx context.Context, k8sAPI *k8s.KubernetesAPI, pod corev1.Pod, identity string, emitLogs bool) (*tls.Crt, error) {
+	portforward, err := k8s.NewProxyInboundForward(k8sAPI, pod, emitLogs)
+	if err != nil {
+		return nil, err
+	}
+
+	defer portforward.Stop()
+
+	if err := portforward.Init(ctx); err != nil {
+		log.Debugf("Error running port-forward: %s", err)
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	rawConn, err := dialer.DialContext(ctx, "tcp", portforward.Address())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the proxy's certificate: %s", err)
+	}
+	conn := cryptotls.Client(rawConn, &cryptotls.Config{
+		ServerName: identity,
+		// The certificate is verified against the trust anchors by the caller,
+		// so that it can be inspected even if it is invalid.
+		InsecureSkipVerify: true,
+	})
+	defer conn.Close()
+
+	// The handshake is bounded by the dialer's timeout and by ctx.
+	deadline := time.Now().Add(dialer.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	if err := conn.Handshake(); err != nil {
+		return nil, fmt.Errorf("failed to fetch the proxy's certificate: %s", err)
+	}
+
+	peers := conn.ConnectionState().PeerCertificates
+	if len(peers) == 0 {
+		return nil, errors.New("the proxy did not present a certificate")
+	}
+
+	// Peer certificates are ordered from leaf to root.
+	crt := &tls.Crt{Certificate: peers[0]}
+	for i := len(peers) - 1; i > 0; i-- {
+		crt.TrustChain = append(crt.TrustChain, peers[i])
+	}
+	return crt, nil
+}
+
+// fetchWebhookCrts reads the certificates of the proxy injector and service
+// profile validator webhooks from their secrets.
+func (hc *HealthChecker) fetchWebhookCrts() ([]*x509.Certificate, error) {
+	var crts []*x509.Certificate
+	for _, webhook := range webhooks() {
+		secret, err := hc.kubeAPI.CoreV1().Secrets(hc.ControlPlaneNamespace).Get(k8s.WebhookSecretName(webhook), metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		crt, err := tls.DecodePEMCrt(string(secret.Data["crt.pem"]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s certificate: %s", webhook, err)
+		}
+		crts = append(crts, crt.Certificate)
+	}
+	return crts, nil
+}
+
+// checkDataPlaneCertificates fetches the certificate served by each running
+// data plane proxy, and checks that it is valid for the proxy's identity and
+// signed by the trust anchors. The certificates that aren't fetched before ctx
+// expires are reported as errors.
+func (hc *HealthChecker) checkDataPlaneCertificates(ctx context.Context) error {
+	pods, err := hc.kubeAPI.CoreV1().Pods(hc.DataPlaneNamespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", k8s.ControllerNSLabel, hc.ControlPlaneNamespace),
+	})
+	if err != nil {
+		return err
+	}
+
+	proxies, err := proxyTrustAnchors(pods.Items)
+	if err != nil {
+		return err
+	}
+
+	var (
+		results = make(map[string]error)
+		sem     = make(chan struct{}, proxyCertFetchConcurrency)
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+	)
+	for _, pod := range pods.Items {
+		name := fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)
+		if _, ok := proxies[name]; !ok || pod.Status.Phase != corev1.PodRunning {
+			// Proxies without identity don't have certificates.
+			continue
+		}
+
+		wg.Add(1)
+		go func(pod corev1.Pod) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				mu.Lock()
+				results[name] = ctx.Err()
+				mu.Unlock()
+				return
+			}
+
+			identity := hc.proxyIdentity(&pod)
+			crt, err := FetchProxyCertificate(ctx, hc.kubeAPI, pod, identity, false)
+			if err == nil {
+				err = checkProxyCertificate(crt, identity, hc.trustAnchors, time.Now())
+			}
+
+			mu.Lock()
+			results[name] = err
+			mu.Unlock()
+		}(pod)
+	}
+	wg.Wait()
+
+	return proxyCertificateErrors(results)
+}
+
+// proxyIdentity returns the identity of a pod's proxy.
+func (hc *HealthChecker) proxyIdentity(pod *corev1.Pod) string {
+	sa, ns := k8s.GetServiceAccountAndNS(pod)
+	return fmt.Sprintf("%s.%s.serviceaccount.identity.%s.%s", sa, ns, hc.ControlPlaneNamespace, hc.trustDomain)
+}
+
+// checkProxyCertificate checks that crt is valid for identity at now and is
+// signed by one of anchors.
+func checkProxyCertificate(crt *tls.Crt, identity string, anchors []*x509.Certificate, now time.Time) error {
+	if err := checkCertsValidity("proxy", []*x509.Certificate{crt.Certificate}, now); err != nil {
+		return err
+	}
+
+	roots := x509.NewCertPool()
+	for _, anchor := range anchors {
+		roots.AddCert(anchor)
+	}
+	if err := crt.Verify(roots, identity); err != nil {
+		return fmt.Errorf("certificate is not valid for %s: %s", identity, err)
+	}
+	return nil
+}
+
+// proxyCertificateErrors summarizes the errors found for each proxy, keyed by
+// "namespace/pod".
+func proxyCertificateErrors(results map[string]error) error {
+	var failed []string
+	for name, err := range results {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("\t* %s: %s", name, err))
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	sort.Strings(failed)
+	return fmt.Errorf("some proxies do not have valid certificates:\n%s", strings.Join(failed, "\n"))
+}
+
+// checkCertsValidity fails if any of crts is expired or not yet valid at now.
+func checkCertsValidity(kind string, crts []*x509.Certificate, now time.Time) error {
+	for _, crt := range crts {
+		if now.Before(crt.NotBefore) {
+			return fmt.Errorf("%s certificate %s is not valid before %s",
+				kind, crt.Subject.CommonName, crt.NotBefore.UTC().Format(time.RFC3339))
+		}
+		if now.After(crt.NotAfter) {
+			return fmt.Errorf("%s certificate %s expired on %s",
+				kind, crt.Subject.CommonName, crt.NotAfter.UTC().Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// checkCertsExpiry fails if any of crts expires within threshold of now.
+func checkCertsExpiry(kind string, crts []*x509.Certificate, threshold time.Duration, now time.Time) error {
+	for _, crt := range crts {
+		if crt.NotAfter.Sub(now) < threshold {
+			return fmt.Errorf("%s certificate %s will expire on %s",
+				kind, crt.Subject.CommonName, crt.NotAfter.UTC().Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// checkIssuerExpiryAnnotation fails if the expiry recorded on the issuer
+// secret, which is written at install and upgrade time, doesn't match the
+// issuer certificate, e.g. because the certificate was replaced manually.
+func checkIssuerExpiryAnnotation(annotation string, crt *x509.Certificate) error {
+	if annotation == "" {
+		return fmt.Errorf("the %s secret has no %s annotation",
+			k8s.IdentityIssuerSecretName, k8s.IdentityIssuerExpiryAnnotation)
+	}
+	expiry, err := time.Parse(time.RFC3339, annotation)
+	if err != nil {
+		return fmt.Errorf("invalid %s annotation: %s", k8s.IdentityIssuerExpiryAnnotation, err)
+	}
+	if !expiry.Equal(crt.NotAfter) {
+		return fmt.Errorf("the %s annotation (%s) does not match the issuer certificate, which expires on %s",
+			k8s.IdentityIssuerExpiryAnnotation, annotation, crt.NotAfter.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// describeThreshold formats a threshold for check descriptions.
+func describeThreshold(d time.Duration) string {
+	if days := int(d.Hours() / 24); days > 0 && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%d days", days)
+	}
+	return d.String()
+}
@@ -0,0 +1,163 @@
+package healthcheck
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	"github.com/linkerd/linkerd2/pkg/tls"
+)
+
+func TestCertificateExpiryChecks(t *testing.T) {
+	root, err := tls.GenerateRootCAWithDefaults("root")
+	if err != nil {
+		t.Fatalf("failed to create CA: %s", err)
+	}
+	crt := root.Cred.Crt.Certificate
+
+	t.Run("Fails outside of the validity period", func(t *testing.T) {
+		if err := checkCertsValidity("issuer", []*x509.Certificate{crt}, time.Now()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		err := checkCertsValidity("issuer", []*x509.Certificate{crt}, crt.NotAfter.Add(time.Second))
+		if err == nil || !strings.Contains(err.Error(), "issuer certificate root expired on") {
+			t.Fatalf("expected an expiry error, got: %v", err)
+		}
+		err = checkCertsValidity("issuer", []*x509.Certificate{crt}, crt.NotBefore.Add(-time.Second))
+		if err == nil || !strings.Contains(err.Error(), "is not valid before") {
+			t.Fatalf("expected a not-yet-valid error, got: %v", err)
+		}
+	})
+
+	t.Run("Warns close to expiry", func(t *testing.T) {
+		if err := checkCertsExpiry("webhook", []*x509.Certificate{crt}, CertExpiryWarningThreshold, time.Now()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		soon := crt.NotAfter.Add(-CertExpiryWarningThreshold / 2)
+		err := checkCertsExpiry("webhook", []*x509.Certificate{crt}, CertExpiryWarningThreshold, soon)
+		if err == nil || !strings.Contains(err.Error(), "webhook certificate root will expire on") {
+			t.Fatalf("expected an expiry warning, got: %v", err)
+		}
+	})
+
+	t.Run("Checks the issuer expiry annotation", func(t *testing.T) {
+		if err := checkIssuerExpiryAnnotation(crt.NotAfter.UTC().Format(time.RFC3339), crt); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		for _, annotation := range []string{"", "tomorrow", "2019-01-01T00:00:00Z"} {
+			if err := checkIssuerExpiryAnnotation(annotation, crt); err == nil {
+				t.Fatalf("expected annotation %q to be rejected", annotation)
+			}
+		}
+	})
+
+	t.Run("Describes thresholds", func(t *testing.T) {
+		if d := describeThreshold(CertExpiryWarningThreshold); d != "60 days" {
+			t.Fatalf("unexpected description: %s", d)
+		}
+		if d := describeThreshold(90 * time.Minute); d != "1h30m0s" {
+			t.Fatalf("unexpected description: %s", d)
+		}
+	})
+}
+
+func TestCheckProxyCertificate(t *testing.T) {
+	root, err := tls.GenerateRootCAWithDefaults("root")
+	if err != nil {
+		t.Fatalf("failed to create CA: %s", err)
+	}
+	other, err := tls.GenerateRootCAWithDefaults("other")
+	if err != nil {
+		t.Fatalf("failed to create CA: %s", err)
+	}
+	identity := "web.emojivoto.serviceaccount.identity.linkerd.cluster.local"
+	cred, err := root.GenerateEndEntityCred(identity)
+	if err != nil {
+		t.Fatalf("failed to issue certificate: %s", err)
+	}
+	anchors := []*x509.Certificate{root.Cred.Crt.Certificate}
+
+	if err := checkProxyCertificate(&cred.Crt, identity, anchors, time.Now()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	testCases := []struct {
+		name     string
+		identity string
+		anchors  []*x509.Certificate
+		now      time.Time
+	}{
+		{"expired", identity, anchors, cred.Crt.Certificate.NotAfter.Add(time.Second)},
+		{"wrong identity", "emoji.emojivoto.serviceaccount.identity.linkerd.cluster.local", anchors, time.Now()},
+		{"untrusted", identity, []*x509.Certificate{other.Cred.Crt.Certificate}, time.Now()},
+	}
+	for _, tc := range testCases {
+		tc := tc // pin
+		t.Run(tc.name, func(t *testing.T) {
+			if err := checkProxyCertificate(&cred.Crt, tc.identity, tc.anchors, tc.now); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+
+	t.Run("Summarizes failures", func(t *testing.T) {
+		err := proxyCertificateErrors(map[string]error{
+			"emojivoto/web":    nil,
+			"emojivoto/voting": errors.New("expired"),
+			"emojivoto/emoji":  errors.New("untrusted"),
+		})
+		expected := "some proxies do not have valid certificates:\n\t* emojivoto/emoji: untrusted\n\t* emojivoto/voting: expired"
+		if err == nil || err.Error() != expected {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := proxyCertificateErrors(map[string]error{"emojivoto/web": nil}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+}
+
+func TestFetchWebhookCrts(t *testing.T) {
+	secret := func(webhook string) string {
+		root, err := tls.GenerateRootCAWithDefaults(webhook)
+		if err != nil {
+			t.Fatalf("failed to create CA: %s", err)
+		}
+		return fmt.Sprintf(`
+apiVersion: v1
+kind: Secret
+metadata:
+  name: %s
+  namespace: linkerd
+data:
+  crt.pem: %s
+`, k8s.WebhookSecretName(webhook), base64.StdEncoding.EncodeToString([]byte(root.Cred.Crt.EncodeCertificatePEM())))
+	}
+
+	hc := NewHealthChecker([]CategoryID{}, &Options{ControlPlaneNamespace: "linkerd"})
+	var err error
+	hc.kubeAPI, err = k8s.NewFakeAPI(secret(k8s.ProxyInjectorWebhookServiceName), secret(k8s.SPValidatorWebhookServiceName))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	crts, err := hc.fetchWebhookCrts()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(crts) != 2 || crts[0].Subject.CommonName != k8s.ProxyInjectorWebhookServiceName {
+		t.Fatalf("unexpected certificates: %v", crts)
+	}
+
+	hc.kubeAPI, err = k8s.NewFakeAPI(secret(k8s.ProxyInjectorWebhookServiceName))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := hc.fetchWebhookCrts(); err == nil {
+		t.Fatal("expected an error for a missing secret")
+	}
+}
@@ -0,0 +1,202 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	configPb "github.com/linkerd/linkerd2/controller/gen/config"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// meshedWorkloads holds the pods and services in the namespaces that are part
+// of the mesh, i.e. that have meshed pods or are annotated for injection.
+type meshedWorkloads struct {
+	pods     []corev1.Pod
+	services []corev1.Service
+}
+
+// fetchMeshedWorkloads lists the meshed workloads and reads the proxy
+// configuration, once per run, for the checks looking for conflicts with the
+// proxy. Nothing is set if either fails.
+func (hc *HealthChecker) fetchMeshedWorkloads(ctx context.Context) error {
+	hc.meshedWorkloads, hc.proxyConfig = nil, nil
+
+	configs, err := hc.fetchLinkerdConfig()
+	if err != nil {
+		return err
+	}
+	workloads, err := hc.getMeshedWorkloads(ctx)
+	if err != nil {
+		return err
+	}
+
+	return commitState(ctx, func() {
+		hc.meshedWorkloads, hc.proxyConfig = workloads, configs.GetProxy()
+	})
+}
+
+// getMeshedWorkloads lists the pods and services in the data plane
+// namespaces that are part of the mesh. It returns early if ctx expires
+// between the API calls.
+func (hc *HealthChecker) getMeshedWorkloads(ctx context.Context) (*meshedWorkloads, error) {
+	namespaces := make(map[string]bool)
+	if hc.DataPlaneNamespace != "" {
+		ns, err := hc.kubeAPI.CoreV1().Namespaces().Get(hc.DataPlaneNamespace, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		namespaces[ns.Name] = ns.Annotations[k8s.ProxyInjectAnnotation] == k8s.ProxyInjectEnabled
+	} else {
+		nsList, err := hc.kubeAPI.CoreV1().Namespaces().List(metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range nsList.Items {
+			namespaces[ns.Name] = ns.Annotations[k8s.ProxyInjectAnnotation] == k8s.ProxyInjectEnabled
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	pods, err := hc.kubeAPI.CoreV1().Pods(hc.DataPlaneNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	services, err := hc.kubeAPI.CoreV1().Services(hc.DataPlaneNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return filterMeshedWorkloads(namespaces, pods.Items, services.Items, hc.ControlPlaneNamespace), nil
+}
+
+// filterMeshedWorkloads returns the pods and services in namespaces that are
+// annotated for injection, as indicated by namespaces, or that have pods
+// meshed with the controlPlaneNamespace control plane. The control plane's
+// own namespace is excluded.
+func filterMeshedWorkloads(namespaces map[string]bool, pods []corev1.Pod, services []corev1.Service, controlPlaneNamespace string) *meshedWorkloads {
+	for i := range pods {
+		if k8s.IsMeshed(&pods[i], controlPlaneNamespace) {
+			namespaces[pods[i].Namespace] = true
+		}
+	}
+	delete(namespaces, controlPlaneNamespace)
+
+	workloads := &meshedWorkloads{}
+	for _, pod := range pods {
+		if namespaces[pod.Namespace] {
+			workloads.pods = append(workloads.pods, pod)
+		}
+	}
+	for _, svc := range services {
+		if namespaces[svc.Namespace] {
+			workloads.services = append(workloads.services, svc)
+		}
+	}
+	return workloads
+}
+
+// checkOtherMeshSidecars fails if any pod runs the sidecars of another
+// service mesh.
+func checkOtherMeshSidecars(pods []corev1.Pod) error {
+	var conflicts []string
+	for _, pod := range pods {
+		if names := OtherMeshSidecars(&pod.Spec); len(names) > 0 {
+			conflicts = append(conflicts, fmt.Sprintf("\t* %s/%s (%s)", pod.Namespace, pod.Name, strings.Join(names, ", ")))
+		}
+	}
+
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return fmt.Errorf("some meshed pods run sidecars of other service meshes:\n%s", strings.Join(conflicts, "\n"))
+	}
+	return nil
+}
+
+// checkProxyPortCollisions fails if an application container in any pod
+// declares a port that the proxy uses. The proxy's ports are read from the
+// proxy config, unless they're overridden by the pod's annotations.
+func checkProxyPortCollisions(pods []corev1.Pod, proxyConfig *configPb.Proxy) error {
+	var collisions []string
+	for _, pod := range pods {
+		ports := proxyPorts(&pod, proxyConfig)
+		for _, container := range pod.Spec.Containers {
+			if container.Name == k8s.ProxyContainerName {
+				continue
+			}
+			for _, port := range container.Ports {
+				if name, ok := ports[uint32(port.ContainerPort)]; ok {
+					collisions = append(collisions, fmt.Sprintf("\t* %s/%s: container %s uses the proxy's %s port %d",
+						pod.Namespace, pod.Name, container.Name, name, port.ContainerPort))
+				}
+			}
+		}
+	}
+
+	if len(collisions) > 0 {
+		sort.Strings(collisions)
+		return fmt.Errorf("some containers use ports reserved for the proxy:\n%s", strings.Join(collisions, "\n"))
+	}
+	return nil
+}
+
+// proxyPorts returns the names of the proxy's ports in a pod, keyed by port
+// number.
+func proxyPorts(pod *corev1.Pod, proxyConfig *configPb.Proxy) map[uint32]string {
+	ports := make(map[uint32]string)
+	add := func(name, annotation string, port *configPb.Port) {
+		if override, err := strconv.ParseUint(pod.Annotations[annotation], 10, 32); err == nil {
+			ports[uint32(override)] = name
+		} else if port.GetPort() != 0 {
+			ports[port.GetPort()] = name
+		}
+	}
+	add("inbound", k8s.ProxyInboundPortAnnotation, proxyConfig.GetInboundPort())
+	add("outbound", k8s.ProxyOutboundPortAnnotation, proxyConfig.GetOutboundPort())
+	add("admin", k8s.ProxyAdminPortAnnotation, proxyConfig.GetAdminPort())
+	add("control", k8s.ProxyControlPortAnnotation, proxyConfig.GetControlPort())
+	return ports
+}
+
+// checkIgnoredServicePorts fails if any service routes to a port that the
+// proxy is configured to ignore, so that its traffic bypasses the mesh. Named
+// target ports are not resolved.
+func checkIgnoredServicePorts(services []corev1.Service, proxyConfig *configPb.Proxy) error {
+	ignored := make(map[uint32]struct{})
+	for _, port := range proxyConfig.GetIgnoreInboundPorts() {
+		ignored[port.GetPort()] = struct{}{}
+	}
+	if len(ignored) == 0 {
+		return nil
+	}
+
+	var collisions []string
+	for _, svc := range services {
+		for _, port := range svc.Spec.Ports {
+			target := port.Port
+			if port.TargetPort.IntVal != 0 {
+				target = port.TargetPort.IntVal
+			} else if port.TargetPort.StrVal != "" {
+				continue
+			}
+			if _, ok := ignored[uint32(target)]; ok {
+				collisions = append(collisions, fmt.Sprintf("\t* %s/%s: port %d", svc.Namespace, svc.Name, target))
+			}
+		}
+	}
+
+	if len(collisions) > 0 {
+		sort.Strings(collisions)
+		return fmt.Errorf("some services route to ports that are not proxied (ignore_inbound_ports):\n%s", strings.Join(collisions, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package healthcheck
+
+import (
+	"reflect"
+	"testing"
+
+	configPb "github.com/linkerd/linkerd2/controller/gen/config"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestFilterMeshedWorkloads(t *testing.T) {
+	pod := func(ns, name string, meshed bool) corev1.Pod {
+		p := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name, Labels: map[string]string{}}}
+		if meshed {
+			p.Labels[k8s.ControllerNSLabel] = "linkerd"
+		}
+		return p
+	}
+	svc := func(ns, name string) corev1.Service {
+		return corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}
+	}
+
+	namespaces := map[string]bool{"emojivoto": false, "books": true, "kube-system": false, "linkerd": false}
+	workloads := filterMeshedWorkloads(namespaces,
+		[]corev1.Pod{
+			pod("emojivoto", "web", true),
+			pod("emojivoto", "vote-bot", false),
+			pod("books", "authors", false),
+			pod("kube-system", "kube-dns", false),
+			pod("linkerd", "linkerd-controller", true),
+		},
+		[]corev1.Service{svc("emojivoto", "web-svc"), svc("kube-system", "kube-dns")},
+		"linkerd",
+	)
+
+	if len(workloads.pods) != 3 {
+		t.Fatalf("expected 3 pods in meshed namespaces, got %d", len(workloads.pods))
+	}
+	for _, p := range workloads.pods {
+		if p.Namespace == "kube-system" || p.Namespace == "linkerd" {
+			t.Fatalf("unexpected pod %s/%s", p.Namespace, p.Name)
+		}
+	}
+	if len(workloads.services) != 1 || workloads.services[0].Name != "web-svc" {
+		t.Fatalf("unexpected services: %v", workloads.services)
+	}
+}
+
+func TestCheckOtherMeshSidecars(t *testing.T) {
+	pods := []corev1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "emojivoto", Name: "web"},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "web"}, {Name: k8s.ProxyContainerName}},
+			},
+		},
+	}
+	if err := checkOtherMeshSidecars(pods); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	pods = append(pods, corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "emojivoto", Name: "voting"},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "istio-init"}},
+			Containers:     []corev1.Container{{Name: "voting"}, {Name: "istio-proxy"}},
+		},
+	})
+	err := checkOtherMeshSidecars(pods)
+	expected := "some meshed pods run sidecars of other service meshes:\n\t* emojivoto/voting (istio-proxy, istio-init)"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCheckProxyPortCollisions(t *testing.T) {
+	proxyConfig := &configPb.Proxy{
+		InboundPort:  &configPb.Port{Port: 4143},
+		OutboundPort: &configPb.Port{Port: 4140},
+		AdminPort:    &configPb.Port{Port: 4191},
+		ControlPort:  &configPb.Port{Port: 4190},
+	}
+	pod := func(name string, annotations map[string]string, ports ...int32) corev1.Pod {
+		container := corev1.Container{Name: "app"}
+		for _, p := range ports {
+			container.Ports = append(container.Ports, corev1.ContainerPort{ContainerPort: p})
+		}
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "emojivoto", Name: name, Annotations: annotations},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					container,
+					{Name: k8s.ProxyContainerName, Ports: []corev1.ContainerPort{{ContainerPort: 4143}, {ContainerPort: 4191}}},
+				},
+			},
+		}
+	}
+
+	if err := checkProxyPortCollisions([]corev1.Pod{pod("web", nil, 8080)}, proxyConfig); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err := checkProxyPortCollisions([]corev1.Pod{
+		pod("web", nil, 8080, 4191),
+		pod("emoji", map[string]string{k8s.ProxyInboundPortAnnotation: "5143"}, 4143, 5143),
+	}, proxyConfig)
+	expected := "some containers use ports reserved for the proxy:\n" +
+		"\t* emojivoto/emoji: container app uses the proxy's inbound port 5143\n" +
+		"\t* emojivoto/web: container app uses the proxy's admin port 4191"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCheckIgnoredServicePorts(t *testing.T) {
+	proxyConfig := &configPb.Proxy{
+		IgnoreInboundPorts: []*configPb.Port{{Port: 3306}},
+	}
+	svc := func(name string, port int32, target intstr.IntOrString) corev1.Service {
+		return corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "books", Name: name},
+			Spec: corev1.ServiceSpec{
+				Ports: []corev1.ServicePort{{Port: port, TargetPort: target}},
+			},
+		}
+	}
+
+	err := checkIgnoredServicePorts([]corev1.Service{
+		svc("web", 80, intstr.FromInt(8080)),
+		svc("named", 3306, intstr.FromString("mysql")),
+		svc("mysql", 3306, intstr.IntOrString{}),
+		svc("proxy-sql", 6033, intstr.FromInt(3306)),
+	}, proxyConfig)
+	expected := "some services route to ports that are not proxied (ignore_inbound_ports):\n" +
+		"\t* books/mysql: port 3306\n" +
+		"\t* books/proxy-sql: port 3306"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := checkIgnoredServicePorts([]corev1.Service{svc("mysql", 3306, intstr.IntOrString{})}, &configPb.Proxy{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestMeshedWorkloadsChecks(t *testing.T) {
+	hc := NewHealthChecker([]CategoryID{}, &Options{ControlPlaneNamespace: "linkerd"})
+	var err error
+	hc.kubeAPI, err = k8s.NewFakeAPI(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: linkerd-config
+  namespace: linkerd
+data:
+  proxy: |
+    {"inboundPort":{"port":4143},"adminPort":{"port":4191}}
+`, `
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: emojivoto
+`, `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+  namespace: emojivoto
+  labels:
+    linkerd.io/control-plane-ns: linkerd
+spec:
+  containers:
+  - name: web
+    ports:
+    - containerPort: 4191
+`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	hc.addCheckAsCategory("conflicts", LinkerdDataPlaneChecks, "data plane pods do not run other service mesh sidecars")
+	hc.addCheckAsCategory("conflicts", LinkerdDataPlaneChecks, "data plane containers do not use proxy ports")
+	hc.addCheckAsCategory("conflicts", LinkerdDataPlaneChecks, "data plane services do not use ignored inbound ports")
+
+	obs := newObserver()
+	hc.RunChecks(obs.resultFn)
+	expected := []string{
+		"conflicts data plane pods do not run other service mesh sidecars",
+		"conflicts data plane containers do not use proxy ports: some containers use ports reserved for the proxy:\n\t* emojivoto/web: container web uses the proxy's admin port 4191",
+		"conflicts data plane services do not use ignored inbound ports",
+	}
+	if !reflect.DeepEqual(obs.results, expected) {
+		t.Fatalf("Expected results %v, got %v", expected, obs.results)
+	}
+
+	// the workloads are listed once for all the checks
+	lists := 0
+	for _, action := range hc.kubeAPI.Interface.(*fake.Clientset).Actions() {
+		if action.GetVerb() == "list" && action.GetResource().Resource == "pods" {
+			lists++
+		}
+	}
+	if lists != 1 {
+		t.Fatalf("Expected pods to be listed once, got %d lists", lists)
+	}
+}
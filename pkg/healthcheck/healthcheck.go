@@ -2,20 +2,25 @@ package healthcheck
 
 import (
 	"context"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/linkerd/linkerd2/controller/api/public"
 	spclient "github.com/linkerd/linkerd2/controller/gen/client/clientset/versioned"
 	healthcheckPb "github.com/linkerd/linkerd2/controller/gen/common/healthcheck"
+	configPb "github.com/linkerd/linkerd2/controller/gen/config"
 	pb "github.com/linkerd/linkerd2/controller/gen/public"
+	"github.com/linkerd/linkerd2/pkg/config"
 	"github.com/linkerd/linkerd2/pkg/k8s"
 	"github.com/linkerd/linkerd2/pkg/profiles"
 	"github.com/linkerd/linkerd2/pkg/tls"
 	"github.com/linkerd/linkerd2/pkg/version"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	log "github.com/sirupsen/logrus"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -78,6 +83,15 @@ const (
 	// checks must be added first.
 	LinkerdAPIChecks CategoryID = "linkerd-api"
 
+	// LinkerdIdentityChecks adds a series of checks to validate that the
+	// identity issuer is signed by the configured trust anchors and that
+	// data plane proxies trust them. These checks also track the progress of
+	// a trust anchor rotation, and warn when the trust anchors, the issuer and
+	// the webhook certificates are close to expiry.
+	// These checks are dependent on the output of KubernetesAPIChecks, so those
+	// checks must be added first.
+	LinkerdIdentityChecks CategoryID = "linkerd-identity"
+
 	// LinkerdVersionChecks adds a series of checks to query for the latest
 	// version, and validate the the CLI is up to date.
 	LinkerdVersionChecks CategoryID = "linkerd-version"
@@ -91,8 +105,9 @@ const (
 	LinkerdControlPlaneVersionChecks CategoryID = "control-plane-version"
 
 	// LinkerdDataPlaneChecks adds data plane checks to validate that the data
-	// plane namespace exists, and that the the proxy containers are in a ready
-	// state and running the latest available version.
+	// plane namespace exists, that meshed workloads don't conflict with other
+	// service meshes or with the proxy's ports, and that the the proxy
+	// containers are in a ready state and running the latest available version.
 	// These checks are dependent on the output of KubernetesAPIChecks,
 	// `apiClient` from LinkerdControlPlaneExistenceChecks, and `latestVersions`
 	// from LinkerdVersionChecks, so those checks must be added first.
@@ -139,6 +154,15 @@ type checker struct {
 	// retried; if the deadline has passed, the check fails (default: no retries)
 	retryDeadline time.Time
 
+	// timeout bounds each attempt of the check; if an attempt doesn't complete
+	// in time, it fails (default: requestTimeout)
+	timeout time.Duration
+
+	// parallel indicates that the check doesn't depend on the checks around it,
+	// so that it may run concurrently with the adjacent parallel checks of its
+	// category; results are still reported in order (default false)
+	parallel bool
+
 	// check is the function that's called to execute the check; if the function
 	// returns an error, the check fails
 	check func(context.Context) error
@@ -147,6 +171,10 @@ type checker struct {
 	// check using the SelfCheck gRPC endpoint; check status is based on the value
 	// of the gRPC response
 	checkRPC func(context.Context) (*healthcheckPb.SelfCheckResponse, error)
+
+	// remediation, if set, fixes the condition detected when the check fails;
+	// it's only applied if the Options allow it (default: no fix)
+	remediation *remediation
 }
 
 // CheckResult encapsulates a check's identifying information and output
@@ -159,6 +187,15 @@ type CheckResult struct {
 	Retry       bool
 	Warning     bool
 	Err         error
+	// Duration is the time spent running the check, including retries.
+	Duration time.Duration
+	// Fix describes the automated fix available for a failed check; it's empty
+	// if the check has to be fixed manually.
+	Fix string
+	// Remediation indicates that the result reports the outcome of applying a
+	// fix, in which case Changes describes the changes that were made.
+	Remediation bool
+	Changes     []string
 }
 
 type checkObserver func(*CheckResult)
@@ -178,6 +215,15 @@ type Options struct {
 	APIAddr               string
 	VersionOverride       string
 	RetryDeadline         time.Time
+
+	// PrometheusURL is the address of the control plane's Prometheus; if it's
+	// empty, Prometheus is reached through a port-forward.
+	PrometheusURL string
+
+	// ConfirmFix, if set, is called with each failed check that has an
+	// automated fix; the fix is applied and the check run again if it returns
+	// true.
+	ConfirmFix func(*CheckResult) bool
 }
 
 // HealthChecker encapsulates all health check checkers, and clients required to
@@ -193,6 +239,17 @@ type HealthChecker struct {
 	apiClient        public.APIClient
 	latestVersions   version.Channels
 	serverVersion    string
+	issuerCrt        *tls.Crt
+	trustAnchors     []*x509.Certificate
+	trustDomain      string
+	webhookCrts      []*x509.Certificate
+	promAPI          promv1.API
+	promPortForward  *k8s.PortForward
+	scrapeTargets    []promv1.ActiveTarget
+	meshedWorkloads  *meshedWorkloads
+	proxyConfig      *configPb.Proxy
+
+	issuerExpiryAnnotation string
 }
 
 // NewHealthChecker returns an initialized HealthChecker
@@ -236,18 +293,24 @@ func (hc *HealthChecker) allCategories() []category {
 					description: "can initialize the client",
 					hintAnchor:  "k8s-api",
 					fatal:       true,
-					check: func(context.Context) (err error) {
-						hc.kubeAPI, err = k8s.NewAPI(hc.KubeConfig, hc.KubeContext, requestTimeout)
-						return
+					check: func(ctx context.Context) error {
+						kubeAPI, err := k8s.NewAPI(hc.KubeConfig, hc.KubeContext, requestTimeout)
+						if err != nil {
+							return err
+						}
+						return commitState(ctx, func() { hc.kubeAPI = kubeAPI })
 					},
 				},
 				{
 					description: "can query the Kubernetes API",
 					hintAnchor:  "k8s-api",
 					fatal:       true,
-					check: func(ctx context.Context) (err error) {
-						hc.kubeVersion, err = hc.kubeAPI.GetVersionInfo()
-						return
+					check: func(ctx context.Context) error {
+						kubeVersion, err := hc.kubeAPI.GetVersionInfo()
+						if err != nil {
+							return err
+						}
+						return commitState(ctx, func() { hc.kubeVersion = kubeVersion })
 					},
 				},
 			},
@@ -258,6 +321,7 @@ func (hc *HealthChecker) allCategories() []category {
 				{
 					description: "is running the minimum Kubernetes API version",
 					hintAnchor:  "k8s-version",
+					parallel:    true,
 					check: func(context.Context) error {
 						return hc.kubeAPI.CheckVersion(hc.kubeVersion)
 					},
@@ -265,6 +329,7 @@ func (hc *HealthChecker) allCategories() []category {
 				{
 					description: "is running the minimum kubectl version",
 					hintAnchor:  "kubectl-version",
+					parallel:    true,
 					check: func(context.Context) error {
 						return k8s.CheckKubectlVersion()
 					},
@@ -277,6 +342,7 @@ func (hc *HealthChecker) allCategories() []category {
 				{
 					description: "control plane namespace does not already exist",
 					hintAnchor:  "pre-ns",
+					parallel:    true,
 					check: func(context.Context) error {
 						return hc.CheckNamespace(hc.ControlPlaneNamespace, false)
 					},
@@ -284,6 +350,7 @@ func (hc *HealthChecker) allCategories() []category {
 				{
 					description: "can create Namespaces",
 					hintAnchor:  "pre-k8s-cluster-k8s",
+					parallel:    true,
 					check: func(context.Context) error {
 						return hc.checkCanCreate("", "", "v1", "namespaces")
 					},
@@ -291,6 +358,7 @@ func (hc *HealthChecker) allCategories() []category {
 				{
 					description: "can create ClusterRoles",
 					hintAnchor:  "pre-k8s-cluster-k8s",
+					parallel:    true,
 					check: func(context.Context) error {
 						return hc.checkCanCreate("", "rbac.authorization.k8s.io", "v1beta1", "clusterroles")
 					},
@@ -298,6 +366,7 @@ func (hc *HealthChecker) allCategories() []category {
 				{
 					description: "can create ClusterRoleBindings",
 					hintAnchor:  "pre-k8s-cluster-k8s",
+					parallel:    true,
 					check: func(context.Context) error {
 						return hc.checkCanCreate("", "rbac.authorization.k8s.io", "v1beta1", "clusterrolebindings")
 					},
@@ -305,6 +374,7 @@ func (hc *HealthChecker) allCategories() []category {
 				{
 					description: "can create CustomResourceDefinitions",
 					hintAnchor:  "pre-k8s-cluster-k8s",
+					parallel:    true,
 					check: func(context.Context) error {
 						return hc.checkCanCreate("", "apiextensions.k8s.io", "v1beta1", "customresourcedefinitions")
 					},
@@ -312,6 +382,7 @@ func (hc *HealthChecker) allCategories() []category {
 				{
 					description: "can create PodSecurityPolicies",
 					hintAnchor:  "pre-k8s",
+					parallel:    true,
 					check: func(context.Context) error {
 						return hc.checkCanCreate(hc.ControlPlaneNamespace, "policy", "v1beta1", "podsecuritypolicies")
 					},
@@ -319,6 +390,7 @@ func (hc *HealthChecker) allCategories() []category {
 				{
 					description: "can create ServiceAccounts",
 					hintAnchor:  "pre-k8s",
+					parallel:    true,
 					check: func(context.Context) error {
 						return hc.checkCanCreate(hc.ControlPlaneNamespace, "", "v1", "serviceaccounts")
 					},
@@ -326,6 +398,7 @@ func (hc *HealthChecker) allCategories() []category {
 				{
 					description: "can create Services",
 					hintAnchor:  "pre-k8s",
+					parallel:    true,
 					check: func(context.Context) error {
 						return hc.checkCanCreate(hc.ControlPlaneNamespace, "", "v1", "services")
 					},
@@ -333,6 +406,7 @@ func (hc *HealthChecker) allCategories() []category {
 				{
 					description: "can create Deployments",
 					hintAnchor:  "pre-k8s",
+					parallel:    true,
 					check: func(context.Context) error {
 						return hc.checkCanCreate(hc.ControlPlaneNamespace, "extensions", "v1beta1", "deployments")
 					},
@@ -340,6 +414,7 @@ func (hc *HealthChecker) allCategories() []category {
 				{
 					description: "can create ConfigMaps",
 					hintAnchor:  "pre-k8s",
+					parallel:    true,
 					check: func(context.Context) error {
 						return hc.checkCanCreate(hc.ControlPlaneNamespace, "", "v1", "configmaps")
 					},
@@ -347,6 +422,7 @@ func (hc *HealthChecker) allCategories() []category {
 				{
 					description: "no clock skew detected",
 					hintAnchor:  "pre-k8s-clock-skew",
+					parallel:    true,
 					check: func(context.Context) error {
 						return hc.checkClockSkew()
 					},
@@ -380,6 +456,7 @@ func (hc *HealthChecker) allCategories() []category {
 				{
 					description: "control plane ClusterRoles exist",
 					hintAnchor:  "l5d-existence-cr",
+					parallel:    true,
 					fatal:       true,
 					check: func(context.Context) error {
 						return hc.checkClusterRoles()
@@ -388,22 +465,33 @@ func (hc *HealthChecker) allCategories() []category {
 				{
 					description: "control plane ClusterRoleBindings exist",
 					hintAnchor:  "l5d-existence-crb",
+					parallel:    true,
 					fatal:       true,
 					check: func(context.Context) error {
 						return hc.checkClusterRoleBindings()
 					},
+					remediation: &remediation{
+						description: "create the missing ClusterRoleBindings",
+						fix:         hc.createClusterRoleBindings,
+					},
 				},
 				{
 					description: "control plane ServiceAccounts exist",
 					hintAnchor:  "l5d-existence-sa",
+					parallel:    true,
 					fatal:       true,
 					check: func(context.Context) error {
 						return hc.checkServiceAccounts()
 					},
+					remediation: &remediation{
+						description: "create the missing ServiceAccounts",
+						fix:         hc.createServiceAccounts,
+					},
 				},
 				{
 					description: "control plane CustomResourceDefinitions exist",
 					hintAnchor:  "l5d-existence-crd",
+					parallel:    true,
 					fatal:       true,
 					check: func(context.Context) error {
 						return hc.checkCustomResourceDefinitions()
@@ -448,26 +536,33 @@ func (hc *HealthChecker) allCategories() []category {
 					check: func(ctx context.Context) error {
 						// save this into hc.controlPlanePods, since this check only
 						// succeeds when all pods are up
-						var err error
-						hc.controlPlanePods, err = hc.kubeAPI.GetPodsByNamespace(hc.ControlPlaneNamespace)
+						pods, err := hc.kubeAPI.GetPodsByNamespace(hc.ControlPlaneNamespace)
 						if err != nil {
 							return err
 						}
+						if err := commitState(ctx, func() { hc.controlPlanePods = pods }); err != nil {
+							return err
+						}
 
-						return checkControllerRunning(hc.controlPlanePods)
+						return checkControllerRunning(pods)
 					},
 				},
 				{
 					description: "can initialize the client",
 					hintAnchor:  "l5d-existence-client",
 					fatal:       true,
-					check: func(context.Context) (err error) {
+					check: func(ctx context.Context) error {
+						var apiClient public.APIClient
+						var err error
 						if hc.APIAddr != "" {
-							hc.apiClient, err = public.NewInternalClient(hc.ControlPlaneNamespace, hc.APIAddr)
+							apiClient, err = public.NewInternalClient(hc.ControlPlaneNamespace, hc.APIAddr)
 						} else {
-							hc.apiClient, err = public.NewExternalClient(hc.ControlPlaneNamespace, hc.kubeAPI)
+							apiClient, err = public.NewExternalClient(hc.ControlPlaneNamespace, hc.kubeAPI)
 						}
-						return
+						if err != nil {
+							return err
+						}
+						return commitState(ctx, func() { hc.apiClient = apiClient })
 					},
 				},
 				{
@@ -475,9 +570,42 @@ func (hc *HealthChecker) allCategories() []category {
 					hintAnchor:    "l5d-existence-api",
 					retryDeadline: hc.RetryDeadline,
 					fatal:         true,
-					check: func(ctx context.Context) (err error) {
-						hc.serverVersion, err = GetServerVersion(ctx, hc.apiClient)
-						return
+					check: func(ctx context.Context) error {
+						serverVersion, err := GetServerVersion(ctx, hc.apiClient)
+						if err != nil {
+							return err
+						}
+						return commitState(ctx, func() { hc.serverVersion = serverVersion })
+					},
+				},
+				{
+					// passes when the control plane doesn't use linkerd-cni
+					description: "linkerd-cni plugin is configured on every node",
+					hintAnchor:  "l5d-cni-plugin-configured",
+					check: func(ctx context.Context) error {
+						configs, err := hc.fetchLinkerdConfig()
+						if err != nil {
+							return err
+						}
+						if !configs.GetGlobal().GetCniEnabled() {
+							return nil
+						}
+						// the linkerd-cni DaemonSet may be installed in any
+						// namespace, and doesn't run on every node
+						pods, err := hc.kubeAPI.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{
+							LabelSelector: k8s.CNIPluginPodSelector,
+						})
+						if err != nil {
+							return err
+						}
+						if err := ctx.Err(); err != nil {
+							return err
+						}
+						nodes, err := hc.kubeAPI.CoreV1().Nodes().List(metav1.ListOptions{})
+						if err != nil {
+							return err
+						}
+						return checkCNIPluginNodes(nodes.Items, pods.Items)
 					},
 				},
 			},
@@ -490,13 +618,15 @@ func (hc *HealthChecker) allCategories() []category {
 					hintAnchor:    "l5d-api-control-ready",
 					retryDeadline: hc.RetryDeadline,
 					fatal:         true,
-					check: func(context.Context) error {
-						var err error
-						hc.controlPlanePods, err = hc.kubeAPI.GetPodsByNamespace(hc.ControlPlaneNamespace)
+					check: func(ctx context.Context) error {
+						pods, err := hc.kubeAPI.GetPodsByNamespace(hc.ControlPlaneNamespace)
 						if err != nil {
 							return err
 						}
-						return validateControlPlanePods(hc.controlPlanePods)
+						if err := commitState(ctx, func() { hc.controlPlanePods = pods }); err != nil {
+							return err
+						}
+						return validateControlPlanePods(pods)
 					},
 				},
 				{
@@ -518,15 +648,185 @@ func (hc *HealthChecker) allCategories() []category {
 				},
 			},
 		},
+		{
+			id: LinkerdIdentityChecks,
+			checkers: []checker{
+				{
+					description: "certificate config is valid",
+					hintAnchor:  "l5d-identity-cert-config-valid",
+					fatal:       true,
+					check: func(ctx context.Context) error {
+						return hc.fetchIdentityCredentials(ctx)
+					},
+				},
+				{
+					description: "trust anchors are within their validity period",
+					hintAnchor:  "l5d-identity-trust-anchors-are-time-valid",
+					parallel:    true,
+					check: func(context.Context) error {
+						return checkCertsValidity("trust anchor", hc.trustAnchors, time.Now())
+					},
+				},
+				{
+					description: fmt.Sprintf("trust anchors are valid for at least %s", describeThreshold(CertExpiryWarningThreshold)),
+					hintAnchor:  "l5d-identity-trust-anchors-not-expiring-soon",
+					parallel:    true,
+					warning:     true,
+					check: func(context.Context) error {
+						return checkCertsExpiry("trust anchor", hc.trustAnchors, CertExpiryWarningThreshold, time.Now())
+					},
+				},
+				{
+					description: "issuer cert is within its validity period",
+					hintAnchor:  "l5d-identity-issuer-cert-is-time-valid",
+					parallel:    true,
+					check: func(context.Context) error {
+						if hc.issuerCrt == nil {
+							return nil
+						}
+						return checkCertsValidity("issuer", []*x509.Certificate{hc.issuerCrt.Certificate}, time.Now())
+					},
+				},
+				{
+					description: fmt.Sprintf("issuer cert is valid for at least %s", describeThreshold(CertExpiryWarningThreshold)),
+					hintAnchor:  "l5d-identity-issuer-cert-not-expiring-soon",
+					parallel:    true,
+					warning:     true,
+					check: func(context.Context) error {
+						if hc.issuerCrt == nil {
+							return nil
+						}
+						return checkCertsExpiry("issuer", []*x509.Certificate{hc.issuerCrt.Certificate}, CertExpiryWarningThreshold, time.Now())
+					},
+				},
+				{
+					description: "issuer cert expiry annotation is up-to-date",
+					hintAnchor:  "l5d-identity-issuer-expiry-annotation",
+					parallel:    true,
+					warning:     true,
+					check: func(context.Context) error {
+						if hc.issuerCrt == nil {
+							return nil
+						}
+						return checkIssuerExpiryAnnotation(hc.issuerExpiryAnnotation, hc.issuerCrt.Certificate)
+					},
+				},
+				{
+					description: "issuer cert is signed by a trust anchor",
+					hintAnchor:  "l5d-identity-issuer-signed-by-trust-anchor",
+					fatal:       true,
+					check: func(context.Context) error {
+						if hc.issuerCrt == nil {
+							// identity is disabled
+							return nil
+						}
+						if len(issuerTrustAnchors(hc.issuerCrt, hc.trustAnchors)) == 0 {
+							return errors.New("the issuer certificate is not signed by any of the trust anchors")
+						}
+						return nil
+					},
+				},
+				{
+					description: "trust anchor rotation is not in progress",
+					hintAnchor:  "l5d-identity-trust-anchor-rotation",
+					parallel:    true,
+					warning:     true,
+					check: func(context.Context) error {
+						if hc.issuerCrt == nil {
+							return nil
+						}
+						return checkTrustAnchorRotation(hc.issuerCrt, hc.trustAnchors)
+					},
+				},
+				{
+					description: "data plane proxies trust the issuer",
+					hintAnchor:  "l5d-identity-data-plane-trusts-issuer",
+					parallel:    true,
+					check: func(context.Context) error {
+						if hc.issuerCrt == nil {
+							return nil
+						}
+						proxies, err := hc.getProxyTrustAnchors()
+						if err != nil {
+							return err
+						}
+						return checkDataPlaneTrustsIssuer(proxies, issuerTrustAnchors(hc.issuerCrt, hc.trustAnchors))
+					},
+				},
+				{
+					description: "data plane proxies have the current trust anchors",
+					hintAnchor:  "l5d-identity-data-plane-trust-anchors",
+					parallel:    true,
+					warning:     true,
+					check: func(context.Context) error {
+						if hc.issuerCrt == nil {
+							return nil
+						}
+						proxies, err := hc.getProxyTrustAnchors()
+						if err != nil {
+							return err
+						}
+						return checkDataPlaneTrustAnchors(proxies, hc.trustAnchors)
+					},
+				},
+				{
+					description: "webhook certs are within their validity period",
+					hintAnchor:  "l5d-identity-webhook-certs-are-time-valid",
+					check: func(ctx context.Context) error {
+						crts, err := hc.fetchWebhookCrts()
+						if err != nil {
+							return err
+						}
+						if err := commitState(ctx, func() { hc.webhookCrts = crts }); err != nil {
+							return err
+						}
+						return checkCertsValidity("webhook", crts, time.Now())
+					},
+					remediation: &remediation{
+						description: "renew the invalid webhook certs",
+						fix: func() ([]string, error) {
+							return hc.renewWebhookCrts(0)
+						},
+					},
+				},
+				{
+					description: fmt.Sprintf("webhook certs are valid for at least %s", describeThreshold(CertExpiryWarningThreshold)),
+					hintAnchor:  "l5d-identity-webhook-certs-not-expiring-soon",
+					warning:     true,
+					check: func(context.Context) error {
+						return checkCertsExpiry("webhook", hc.webhookCrts, CertExpiryWarningThreshold, time.Now())
+					},
+					remediation: &remediation{
+						description: "renew the webhook certs that are close to expiry",
+						fix: func() ([]string, error) {
+							return hc.renewWebhookCrts(CertExpiryWarningThreshold)
+						},
+					},
+				},
+				{
+					description: "webhook configurations match the webhook certs",
+					hintAnchor:  "l5d-identity-webhook-configs",
+					check: func(context.Context) error {
+						return hc.checkWebhookConfigs()
+					},
+					remediation: &remediation{
+						description: "update the stale webhook configurations",
+						fix:         hc.updateWebhookConfigs,
+					},
+				},
+			},
+		},
 		{
 			id: LinkerdVersionChecks,
 			checkers: []checker{
 				{
 					description: "can determine the latest version",
 					hintAnchor:  "l5d-version-latest",
-					check: func(ctx context.Context) (err error) {
+					check: func(ctx context.Context) error {
+						var latestVersions version.Channels
+						var err error
 						if hc.VersionOverride != "" {
-							hc.latestVersions, err = version.NewChannels(hc.VersionOverride)
+							latestVersions, err = version.NewChannels(hc.VersionOverride)
 						} else {
 							// The UUID is only known to the web process. At some point we may want
 							// to consider providing it in the Public API.
@@ -544,9 +844,12 @@ func (hc *HealthChecker) allCategories() []category {
 									}
 								}
 							}
-							hc.latestVersions, err = version.GetLatestVersions(ctx, uuid, "cli")
+							latestVersions, err = version.GetLatestVersions(ctx, uuid, "cli")
 						}
-						return
+						if err != nil {
+							return err
+						}
+						return commitState(ctx, func() { hc.latestVersions = latestVersions })
 					},
 				},
 				{
@@ -565,6 +868,7 @@ func (hc *HealthChecker) allCategories() []category {
 				{
 					description: "control plane is up-to-date",
 					hintAnchor:  "l5d-version-control",
+					parallel:    true,
 					warning:     true,
 					check: func(context.Context) error {
 						return hc.latestVersions.Match(hc.serverVersion)
@@ -573,6 +877,7 @@ func (hc *HealthChecker) allCategories() []category {
 				{
 					description: "control plane and cli versions match",
 					hintAnchor:  "l5d-version-control",
+					parallel:    true,
 					warning:     true,
 					check: func(context.Context) error {
 						if hc.serverVersion != version.Version {
@@ -598,6 +903,39 @@ func (hc *HealthChecker) allCategories() []category {
 						return hc.CheckNamespace(hc.DataPlaneNamespace, true)
 					},
 				},
+				{
+					description: "data plane pods do not run other service mesh sidecars",
+					hintAnchor:  "l5d-data-plane-other-mesh",
+					check: func(ctx context.Context) error {
+						if err := hc.fetchMeshedWorkloads(ctx); err != nil {
+							return err
+						}
+						return checkOtherMeshSidecars(hc.meshedWorkloads.pods)
+					},
+				},
+				{
+					description: "data plane containers do not use proxy ports",
+					hintAnchor:  "l5d-data-plane-port-collision",
+					parallel:    true,
+					check: func(context.Context) error {
+						if hc.meshedWorkloads == nil {
+							return fmt.Errorf("unable to list the meshed workloads")
+						}
+						return checkProxyPortCollisions(hc.meshedWorkloads.pods, hc.proxyConfig)
+					},
+				},
+				{
+					description: "data plane services do not use ignored inbound ports",
+					hintAnchor:  "l5d-data-plane-ignored-inbound-ports",
+					parallel:    true,
+					warning:     true,
+					check: func(context.Context) error {
+						if hc.meshedWorkloads == nil {
+							return fmt.Errorf("unable to list the meshed workloads")
+						}
+						return checkIgnoredServicePorts(hc.meshedWorkloads.services, hc.proxyConfig)
+					},
+				},
 				{
 					description:   "data plane proxies are ready",
 					hintAnchor:    "l5d-data-plane-ready",
@@ -612,6 +950,19 @@ func (hc *HealthChecker) allCategories() []category {
 						return validateDataPlanePods(pods, hc.DataPlaneNamespace)
 					},
 				},
+				{
+					description: "data plane proxies have valid certificates",
+					hintAnchor:  "l5d-data-plane-certs-valid",
+					timeout:     proxyCertCheckTimeout,
+					check: func(ctx context.Context) error {
+						// the trust anchors are fetched by the identity checks,
+						// which don't run in the public API
+						if hc.issuerCrt == nil {
+							return nil
+						}
+						return hc.checkDataPlaneCertificates(ctx)
+					},
+				},
 				{
 					description:   "data plane proxy metrics are present in Prometheus",
 					hintAnchor:    "l5d-data-plane-prom",
@@ -625,6 +976,70 @@ func (hc *HealthChecker) allCategories() []category {
 						return validateDataPlanePodReporting(pods)
 					},
 				},
+				{
+					description: "can query Prometheus",
+					hintAnchor:  "l5d-data-plane-prom-api",
+					check: func(ctx context.Context) error {
+						return hc.fetchScrapeTargets(ctx)
+					},
+				},
+				{
+					description: "data plane proxies are Prometheus scrape targets",
+					hintAnchor:  "l5d-data-plane-prom-targets",
+					parallel:    true,
+					check: func(ctx context.Context) error {
+						if hc.promAPI == nil {
+							return fmt.Errorf("unable to query Prometheus")
+						}
+						pods, err := hc.getDataPlanePods(ctx)
+						if err != nil {
+							return err
+						}
+						return checkMissingScrapeTargets(pods, hc.scrapeTargets)
+					},
+				},
+				{
+					description: "data plane proxies were scraped recently",
+					hintAnchor:  "l5d-data-plane-prom-scrapes",
+					parallel:    true,
+					check: func(ctx context.Context) error {
+						if hc.promAPI == nil {
+							return fmt.Errorf("unable to query Prometheus")
+						}
+						pods, err := hc.getDataPlanePods(ctx)
+						if err != nil {
+							return err
+						}
+						return checkStaleScrapes(pods, hc.scrapeTargets, scrapeStalenessThreshold, time.Now())
+					},
+				},
+				{
+					description: "Prometheus is not dropping samples",
+					hintAnchor:  "l5d-prom-dropped-samples",
+					parallel:    true,
+					warning:     true,
+					check: func(ctx context.Context) error {
+						return hc.checkPrometheusCounters(ctx, droppedSampleCounters)
+					},
+				},
+				{
+					description: "Prometheus storage is healthy",
+					hintAnchor:  "l5d-prom-tsdb",
+					parallel:    true,
+					warning:     true,
+					check: func(ctx context.Context) error {
+						return hc.checkPrometheusCounters(ctx, tsdbFailureCounters)
+					},
+				},
+				{
+					description: "Prometheus rules are evaluated successfully",
+					hintAnchor:  "l5d-prom-rules",
+					parallel:    true,
+					warning:     true,
+					check: func(ctx context.Context) error {
+						return hc.checkPrometheusCounters(ctx, ruleFailureCounters)
+					},
+				},
 				{
 					description: "data plane is up-to-date",
 					hintAnchor:  "l5d-data-plane-version",
@@ -696,34 +1111,32 @@ func (hc *HealthChecker) addCategory(c category) {
 // remaining checks are skipped. If at least one check fails, RunChecks returns
 // false; if all checks passed, RunChecks returns true.  Checks which are
 // designated as warnings will not cause RunCheck to return false, however.
+// Adjacent checks of a category that are marked as parallel run concurrently,
+// but their results are passed to the observer in order. The connections
+// opened by the checks are closed once they complete.
 func (hc *HealthChecker) RunChecks(observer checkObserver) bool {
+	defer hc.stopPrometheusPortForward()
+
 	success := true
 
 	for _, c := range hc.categories {
 		if c.enabled {
-			for _, checker := range c.checkers {
-				checker := checker // pin
-				if checker.check != nil {
-					if !hc.runCheck(c.id, &checker, observer) {
-						if !checker.warning {
-							success = false
-						}
-						if checker.fatal {
-							return success
-						}
+			for i := 0; i < len(c.checkers); {
+				j := i + 1
+				if c.checkers[i].parallel {
+					for j < len(c.checkers) && c.checkers[j].parallel {
+						j++
 					}
 				}
 
-				if checker.checkRPC != nil {
-					if !hc.runCheckRPC(c.id, &checker, observer) {
-						if !checker.warning {
-							success = false
-						}
-						if checker.fatal {
-							return success
-						}
-					}
+				ok, fatal := hc.runCheckers(c.id, c.checkers[i:j], observer)
+				if !ok {
+					success = false
+				}
+				if fatal {
+					return success
 				}
+				i = j
 			}
 		}
 	}
@@ -731,17 +1144,75 @@ func (hc *HealthChecker) RunChecks(observer checkObserver) bool {
 	return success
 }
 
+// runCheckers runs a group of checkers, concurrently if there are more than
+// one, and passes their results to the observer in order. It returns false if
+// a check that isn't a warning failed, and true as its second value if a fatal
+// check failed, in which case the results of the checkers after it are
+// discarded.
+func (hc *HealthChecker) runCheckers(categoryID CategoryID, checkers []checker, observer checkObserver) (bool, bool) {
+	queues := make([]*resultQueue, len(checkers))
+	for i := range checkers {
+		queues[i] = newResultQueue()
+	}
+
+	if len(checkers) == 1 {
+		hc.runChecker(categoryID, &checkers[0], queues[0])
+	} else {
+		for i := range checkers {
+			go hc.runChecker(categoryID, &checkers[i], queues[i])
+		}
+	}
+
+	success := true
+	for i := range checkers {
+		var last *CheckResult
+		ok := queues[i].drain(func(result *CheckResult) {
+			last = result
+			observer(result)
+		})
+		if !ok && last != nil {
+			ok = hc.runRemediation(categoryID, &checkers[i], last, observer)
+		}
+		if !ok {
+			if !checkers[i].warning {
+				success = false
+			}
+			if checkers[i].fatal {
+				return success, true
+			}
+		}
+	}
+	return success, false
+}
+
+func (hc *HealthChecker) runChecker(categoryID CategoryID, c *checker, queue *resultQueue) {
+	ok := true
+	if c.check != nil {
+		ok = hc.runCheck(categoryID, c, queue.push)
+	}
+	if c.checkRPC != nil && !hc.runCheckRPC(categoryID, c, queue.push) {
+		ok = false
+	}
+	queue.close(ok)
+}
+
 func (hc *HealthChecker) runCheck(categoryID CategoryID, c *checker, observer checkObserver) bool {
+	start := time.Now()
 	for {
-		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
-		defer cancel()
-		err := c.check(ctx)
+		timeout := c.checkTimeout()
+		err := runWithTimeout(timeout, func(ctx context.Context) error {
+			return c.check(ctx)
+		})
 		checkResult := &CheckResult{
 			Category:    categoryID,
 			Description: c.description,
 			HintAnchor:  c.hintAnchor,
 			Warning:     c.warning,
 			Err:         err,
+			Duration:    time.Since(start),
+		}
+		if err != nil && c.remediation != nil {
+			checkResult.Fix = c.remediation.description
 		}
 
 		if err != nil && time.Now().Before(c.retryDeadline) {
@@ -760,21 +1231,32 @@ func (hc *HealthChecker) runCheck(categoryID CategoryID, c *checker, observer ch
 }
 
 func (hc *HealthChecker) runCheckRPC(categoryID CategoryID, c *checker, observer checkObserver) bool {
-	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
-	defer cancel()
-	checkRsp, err := c.checkRPC(ctx)
+	start := time.Now()
+	var checkRsp *healthcheckPb.SelfCheckResponse
+	err := runWithTimeout(c.checkTimeout(), func(ctx context.Context) (err error) {
+		checkRsp, err = c.checkRPC(ctx)
+		return
+	})
+	duration := time.Since(start)
 	observer(&CheckResult{
 		Category:    categoryID,
 		Description: c.description,
 		HintAnchor:  c.hintAnchor,
 		Warning:     c.warning,
 		Err:         err,
+		Duration:    duration,
 	})
 	if err != nil {
 		return false
 	}
 
 	for _, check := range checkRsp.Results {
+		if hc.isCategory(CategoryID(check.SubsystemName)) {
+			// the results of the control plane's periodic health checks duplicate
+			// the checks that are run here
+			continue
+		}
+
 		var err error
 		if check.Status != healthcheckPb.CheckStatus_OK {
 			err = fmt.Errorf(check.FriendlyMessageToUser)
@@ -785,6 +1267,7 @@ func (hc *HealthChecker) runCheckRPC(categoryID CategoryID, c *checker, observer
 			HintAnchor:  c.hintAnchor,
 			Warning:     c.warning,
 			Err:         err,
+			Duration:    duration,
 		})
 		if err != nil {
 			return false
@@ -794,6 +1277,105 @@ func (hc *HealthChecker) runCheckRPC(categoryID CategoryID, c *checker, observer
 	return true
 }
 
+func (hc *HealthChecker) isCategory(id CategoryID) bool {
+	for _, c := range hc.categories {
+		if c.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *checker) checkTimeout() time.Duration {
+	if c.timeout != 0 {
+		return c.timeout
+	}
+	return requestTimeout
+}
+
+// runWithTimeout calls check with a context that expires after timeout, and
+// reports a timeout if check fails once the context expired. Checks return
+// when their context expires, so that none is left running in the
+// background; client-go calls don't take a context, but each of them is
+// bounded by requestTimeout.
+func runWithTimeout(timeout time.Duration, check func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := check(ctx)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("check timed out after %s", timeout)
+	}
+	return err
+}
+
+// commitState calls set to save the results of a check on hc, unless ctx
+// expired: the check then timed out, and its results are dropped so that the
+// checks that follow don't use them.
+func commitState(ctx context.Context, set func()) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	set()
+	return nil
+}
+
+// resultQueue buffers the results of a checker that runs concurrently with
+// others, until they can be passed to the observer in order.
+type resultQueue struct {
+	sync.Mutex
+	results []*CheckResult
+	done    bool
+	ok      bool
+	ready   chan struct{}
+}
+
+func newResultQueue() *resultQueue {
+	return &resultQueue{ready: make(chan struct{}, 1)}
+}
+
+func (q *resultQueue) push(result *CheckResult) {
+	q.Lock()
+	q.results = append(q.results, result)
+	q.Unlock()
+	q.notify()
+}
+
+// close records the checker's outcome once it has pushed all of its results.
+func (q *resultQueue) close(ok bool) {
+	q.Lock()
+	q.done = true
+	q.ok = ok
+	q.Unlock()
+	q.notify()
+}
+
+func (q *resultQueue) notify() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// drain passes the queued results to the observer as they arrive, until the
+// queue is closed, and returns the checker's outcome.
+func (q *resultQueue) drain(observer checkObserver) bool {
+	for {
+		q.Lock()
+		results, done, ok := q.results, q.done, q.ok
+		q.results = nil
+		q.Unlock()
+
+		for _, result := range results {
+			observer(result)
+		}
+		if done {
+			return ok
+		}
+		<-q.ready
+	}
+}
+
 // PublicAPIClient returns a fully configured public API client. This client is
 // only configured if the KubernetesAPIChecks and LinkerdAPIChecks are
 // configured and run first.
@@ -957,6 +1539,164 @@ func (hc *HealthChecker) getDataPlanePods(ctx context.Context) ([]*pb.Pod, error
 	return pods, nil
 }
 
+// fetchLinkerdConfig reads the control plane's configuration from the
+// linkerd-config ConfigMap.
+func (hc *HealthChecker) fetchLinkerdConfig() (*configPb.All, error) {
+	cm, err := hc.kubeAPI.CoreV1().ConfigMaps(hc.ControlPlaneNamespace).Get(k8s.ConfigConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	configs, err := config.FromConfigMap(cm.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s ConfigMap: %s", k8s.ConfigConfigMapName, err)
+	}
+	return configs, nil
+}
+
+// fetchIdentityCredentials reads the issuer certificate and the trust anchors
+// from the control plane's configuration. Nothing is set if identity is
+// disabled.
+func (hc *HealthChecker) fetchIdentityCredentials(ctx context.Context) error {
+	configs, err := hc.fetchLinkerdConfig()
+	if err != nil {
+		return err
+	}
+	idctx := configs.GetGlobal().GetIdentityContext()
+	if idctx == nil {
+		return nil
+	}
+
+	anchors, err := tls.DecodePEMCertificates(idctx.GetTrustAnchorsPem())
+	if err != nil {
+		return fmt.Errorf("invalid trust anchors: %s", err)
+	}
+	if len(anchors) == 0 {
+		return errors.New("no trust anchors are configured")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	secret, err := hc.kubeAPI.CoreV1().Secrets(hc.ControlPlaneNamespace).Get(k8s.IdentityIssuerSecretName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	crt, err := tls.DecodePEMCrt(string(secret.Data[k8s.IdentityIssuerCrtName]))
+	if err != nil {
+		return fmt.Errorf("invalid issuer certificate: %s", err)
+	}
+
+	return commitState(ctx, func() {
+		hc.issuerCrt = crt
+		hc.issuerExpiryAnnotation = secret.GetAnnotations()[k8s.IdentityIssuerExpiryAnnotation]
+		hc.trustAnchors = anchors
+		hc.trustDomain = idctx.GetTrustDomain()
+	})
+}
+
+// getProxyTrustAnchors returns the trust anchors of each data plane proxy,
+// keyed by "namespace/pod". Proxies without identity are omitted.
+func (hc *HealthChecker) getProxyTrustAnchors() (map[string][]*x509.Certificate, error) {
+	pods, err := hc.kubeAPI.CoreV1().Pods(hc.DataPlaneNamespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", k8s.ControllerNSLabel, hc.ControlPlaneNamespace),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return proxyTrustAnchors(pods.Items)
+}
+
+func proxyTrustAnchors(pods []corev1.Pod) (map[string][]*x509.Certificate, error) {
+	proxies := make(map[string][]*x509.Certificate)
+	for _, pod := range pods {
+		for _, container := range pod.Spec.Containers {
+			if container.Name != k8s.ProxyContainerName {
+				continue
+			}
+			for _, env := range container.Env {
+				if env.Name != k8s.IdentityTrustAnchorsEnvVar {
+					continue
+				}
+				name := fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)
+				anchors, err := tls.DecodePEMCertificates(env.Value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid trust anchors for %s: %s", name, err)
+				}
+				proxies[name] = anchors
+			}
+		}
+	}
+	return proxies, nil
+}
+
+// issuerTrustAnchors returns the trust anchors that the issuer certificate
+// chains to.
+func issuerTrustAnchors(issuer *tls.Crt, anchors []*x509.Certificate) []*x509.Certificate {
+	var signers []*x509.Certificate
+	for _, anchor := range anchors {
+		roots := x509.NewCertPool()
+		roots.AddCert(anchor)
+		if err := issuer.Verify(roots, ""); err == nil {
+			signers = append(signers, anchor)
+		}
+	}
+	return signers
+}
+
+// checkTrustAnchorRotation fails when more than one trust anchor is
+// configured, describing how far the rotation has progressed.
+func checkTrustAnchorRotation(issuer *tls.Crt, anchors []*x509.Certificate) error {
+	if len(anchors) < 2 {
+		return nil
+	}
+
+	var others []string
+	for _, anchor := range anchors {
+		if !tls.ContainsCertificates(issuerTrustAnchors(issuer, anchors), anchor) {
+			others = append(others, anchor.Subject.CommonName)
+		}
+	}
+	return fmt.Errorf("%d trust anchors are configured; the issuer is signed by %s and is not signed by: %s",
+		len(anchors), issuer.Certificate.Issuer.CommonName, strings.Join(others, ", "))
+}
+
+func checkDataPlaneTrustsIssuer(proxies map[string][]*x509.Certificate, issuerAnchors []*x509.Certificate) error {
+	var untrusting []string
+	for name, anchors := range proxies {
+		trusted := false
+		for _, anchor := range issuerAnchors {
+			if tls.ContainsCertificates(anchors, anchor) {
+				trusted = true
+				break
+			}
+		}
+		if !trusted {
+			untrusting = append(untrusting, name)
+		}
+	}
+
+	if len(untrusting) > 0 {
+		sort.Strings(untrusting)
+		return fmt.Errorf("proxies do not trust the issuer and must be restarted: %s", strings.Join(untrusting, ", "))
+	}
+	return nil
+}
+
+func checkDataPlaneTrustAnchors(proxies map[string][]*x509.Certificate, anchors []*x509.Certificate) error {
+	var stale []string
+	for name, proxyAnchors := range proxies {
+		if !tls.ContainsCertificates(proxyAnchors, anchors...) || !tls.ContainsCertificates(anchors, proxyAnchors...) {
+			stale = append(stale, name)
+		}
+	}
+
+	if len(stale) > 0 {
+		sort.Strings(stale)
+		return fmt.Errorf("proxies have outdated trust anchors and should be restarted: %s", strings.Join(stale, ", "))
+	}
+	return nil
+}
+
 func (hc *HealthChecker) checkCanCreate(namespace, group, version, resource string) error {
 	if hc.kubeAPI == nil {
 		// we should never get here
@@ -1201,6 +1941,48 @@ func validateDataPlanePodReporting(pods []*pb.Pod) error {
 	return nil
 }
 
+// checkCNIPluginNodes fails if a node running one of the linkerd-cni pods
+// doesn't report that the plugin is in its CNI network configuration, through
+// the condition set by the linkerd-cni installer. The nodes the linkerd-cni
+// DaemonSet doesn't run on, e.g. because of its node selector or their
+// taints, are skipped.
+func checkCNIPluginNodes(nodes []corev1.Node, cniPods []corev1.Pod) error {
+	if len(cniPods) == 0 {
+		return errors.New("no linkerd-cni pods found, the linkerd-cni DaemonSet may not be installed")
+	}
+	cniNodes := make(map[string]bool)
+	for _, pod := range cniPods {
+		cniNodes[pod.Spec.NodeName] = true
+	}
+
+	var errs []string
+	for _, node := range nodes {
+		if !cniNodes[node.Name] {
+			continue
+		}
+
+		var condition *corev1.NodeCondition
+		for i := range node.Status.Conditions {
+			if node.Status.Conditions[i].Type == k8s.CNIPluginNodeCondition {
+				condition = &node.Status.Conditions[i]
+				break
+			}
+		}
+
+		switch {
+		case condition == nil:
+			errs = append(errs, fmt.Sprintf("\t* %s: no status reported by the linkerd-cni installer", node.Name))
+		case condition.Status != corev1.ConditionTrue:
+			errs = append(errs, fmt.Sprintf("\t* %s: %s", node.Name, condition.Message))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("linkerd-cni plugin is not configured on some nodes:\n%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
 func checkUnschedulablePods(pods []corev1.Pod) error {
 	var errors []string
 	for _, pod := range pods {
@@ -2,6 +2,7 @@ package healthcheck
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
 	"reflect"
 	"strings"
@@ -12,6 +13,7 @@ import (
 	healthcheckPb "github.com/linkerd/linkerd2/controller/gen/common/healthcheck"
 	pb "github.com/linkerd/linkerd2/controller/gen/public"
 	"github.com/linkerd/linkerd2/pkg/k8s"
+	"github.com/linkerd/linkerd2/pkg/tls"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -195,6 +197,60 @@ func TestHealthChecker(t *testing.T) {
 		}
 	})
 
+	t.Run("Skips the control plane's periodic health check results", func(t *testing.T) {
+		periodicRPCClient := public.MockAPIClient{
+			SelfCheckResponseToReturn: &healthcheckPb.SelfCheckResponse{
+				Results: []*healthcheckPb.CheckResult{
+					{
+						SubsystemName:    "rpc1",
+						CheckDescription: "rpc desc1",
+						Status:           healthcheckPb.CheckStatus_OK,
+					},
+					{
+						SubsystemName:         "cat1",
+						CheckDescription:      "desc1",
+						Status:                healthcheckPb.CheckStatus_FAIL,
+						FriendlyMessageToUser: "error",
+					},
+				},
+			},
+		}
+
+		hc := NewHealthChecker(
+			[]CategoryID{},
+			&Options{},
+		)
+		hc.addCategory(passingCheck1)
+		hc.addCategory(category{
+			id: "cat4",
+			checkers: []checker{
+				{
+					description: "desc4",
+					checkRPC: func(context.Context) (*healthcheckPb.SelfCheckResponse, error) {
+						return periodicRPCClient.SelfCheck(context.Background(),
+							&healthcheckPb.SelfCheckRequest{})
+					},
+				},
+			},
+		})
+
+		expectedResults := []string{
+			"cat1 desc1",
+			"cat4 desc4",
+			"cat4 [rpc1] rpc desc1",
+		}
+
+		obs := newObserver()
+		success := hc.RunChecks(obs.resultFn)
+
+		if !success {
+			t.Fatalf("Expecting checks to be successful, but got [%t]", success)
+		}
+		if !reflect.DeepEqual(obs.results, expectedResults) {
+			t.Fatalf("Expected results %v, but got %v", expectedResults, obs.results)
+		}
+	})
+
 	t.Run("Is successful if all checks were successful", func(t *testing.T) {
 		hc := NewHealthChecker(
 			[]CategoryID{},
@@ -314,6 +370,191 @@ func TestHealthChecker(t *testing.T) {
 			t.Fatalf("Expected results %v, but got %v", expectedResults, observedResults)
 		}
 	})
+
+	t.Run("Runs parallel checks concurrently and reports them in order", func(t *testing.T) {
+		// each check waits for the next one to start, so that the checks can
+		// only complete if they run concurrently
+		started := []chan struct{}{make(chan struct{}), make(chan struct{}), make(chan struct{})}
+		parallelCheck := func(i int) checker {
+			return checker{
+				description: fmt.Sprintf("desc%d", i),
+				parallel:    true,
+				check: func(context.Context) error {
+					close(started[i])
+					if i+1 < len(started) {
+						<-started[i+1]
+					}
+					if i == 1 {
+						return fmt.Errorf("error")
+					}
+					return nil
+				},
+			}
+		}
+
+		hc := NewHealthChecker(
+			[]CategoryID{},
+			&Options{},
+		)
+		hc.addCategory(category{
+			id:       "cat8",
+			checkers: []checker{parallelCheck(0), parallelCheck(1), parallelCheck(2)},
+		})
+		hc.addCategory(passingCheck1)
+
+		expectedResults := []string{
+			"cat8 desc0",
+			"cat8 desc1: error",
+			"cat8 desc2",
+			"cat1 desc1",
+		}
+
+		obs := newObserver()
+		success := hc.RunChecks(obs.resultFn)
+
+		if success {
+			t.Fatalf("Expecting checks to not be successful, but got [%t]", success)
+		}
+		if !reflect.DeepEqual(obs.results, expectedResults) {
+			t.Fatalf("Expected results %v, but got %v", expectedResults, obs.results)
+		}
+	})
+
+	t.Run("Does not report parallel checks after a fatal check fails", func(t *testing.T) {
+		hc := NewHealthChecker(
+			[]CategoryID{},
+			&Options{},
+		)
+		hc.addCategory(category{
+			id: "cat9",
+			checkers: []checker{
+				{
+					description: "desc9",
+					parallel:    true,
+					fatal:       true,
+					check: func(context.Context) error {
+						return fmt.Errorf("fatal")
+					},
+				},
+				{
+					description: "desc10",
+					parallel:    true,
+					check: func(context.Context) error {
+						return nil
+					},
+				},
+			},
+		})
+		hc.addCategory(passingCheck1)
+
+		expectedResults := []string{
+			"cat9 desc9: fatal",
+		}
+
+		obs := newObserver()
+		hc.RunChecks(obs.resultFn)
+
+		if !reflect.DeepEqual(obs.results, expectedResults) {
+			t.Fatalf("Expected results %v, but got %v", expectedResults, obs.results)
+		}
+	})
+
+	t.Run("Fails checks that time out", func(t *testing.T) {
+		hc := NewHealthChecker(
+			[]CategoryID{},
+			&Options{},
+		)
+		hc.addCategory(category{
+			id: "cat11",
+			checkers: []checker{
+				{
+					description: "desc11",
+					timeout:     10 * time.Millisecond,
+					check: func(ctx context.Context) error {
+						<-ctx.Done()
+						return ctx.Err()
+					},
+				},
+			},
+		})
+
+		var results []*CheckResult
+		success := hc.RunChecks(func(result *CheckResult) {
+			results = append(results, result)
+		})
+
+		if success {
+			t.Fatalf("Expecting checks to not be successful, but got [%t]", success)
+		}
+		if len(results) != 1 {
+			t.Fatalf("Expected 1 result, but got %d", len(results))
+		}
+		if results[0].Err == nil || results[0].Err.Error() != "check timed out after 10ms" {
+			t.Fatalf("Expected a timeout error, but got %v", results[0].Err)
+		}
+		if results[0].Duration < 10*time.Millisecond {
+			t.Fatalf("Expected the duration to include the timeout, but got %s", results[0].Duration)
+		}
+	})
+
+	t.Run("Doesn't keep the state of checks that time out", func(t *testing.T) {
+		hc := NewHealthChecker(
+			[]CategoryID{},
+			&Options{},
+		)
+		// returned is written by the check that times out and read by the
+		// checks after it, which the race detector flags if the check is left
+		// running past its timeout
+		returned := false
+		readState := func(context.Context) error {
+			if !returned {
+				return fmt.Errorf("the timed out check is still running")
+			}
+			if hc.serverVersion != "" {
+				return fmt.Errorf("unexpected server version %s", hc.serverVersion)
+			}
+			return nil
+		}
+		hc.addCategory(category{
+			id: "cat12",
+			checkers: []checker{
+				{
+					description: "desc12",
+					timeout:     10 * time.Millisecond,
+					check: func(ctx context.Context) error {
+						<-ctx.Done()
+						time.Sleep(10 * time.Millisecond)
+						err := commitState(ctx, func() { hc.serverVersion = "stable-2.6.0" })
+						returned = true
+						return err
+					},
+				},
+				{
+					description: "desc13",
+					parallel:    true,
+					check:       readState,
+				},
+				{
+					description: "desc14",
+					parallel:    true,
+					check:       readState,
+				},
+			},
+		})
+
+		expectedResults := []string{
+			"cat12 desc12: check timed out after 10ms",
+			"cat12 desc13",
+			"cat12 desc14",
+		}
+
+		obs := newObserver()
+		hc.RunChecks(obs.resultFn)
+
+		if !reflect.DeepEqual(obs.results, expectedResults) {
+			t.Fatalf("Expected results %v, but got %v", expectedResults, obs.results)
+		}
+	})
 }
 
 func TestCheckCanCreate(t *testing.T) {
@@ -1120,3 +1361,162 @@ func TestValidateDataPlanePodReporting(t *testing.T) {
 		}
 	})
 }
+
+func TestCheckCNIPluginNodes(t *testing.T) {
+	node := func(name string, conditions ...corev1.NodeCondition) corev1.Node {
+		return corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     corev1.NodeStatus{Conditions: conditions},
+		}
+	}
+	ready := corev1.NodeCondition{Type: corev1.NodeReady, Status: corev1.ConditionTrue}
+	configured := corev1.NodeCondition{Type: k8s.CNIPluginNodeCondition, Status: corev1.ConditionTrue}
+	notConfigured := corev1.NodeCondition{
+		Type:    k8s.CNIPluginNodeCondition,
+		Status:  corev1.ConditionFalse,
+		Message: "open /host/etc/cni/net.d: no such file or directory",
+	}
+
+	cniPods := func(nodeNames ...string) []corev1.Pod {
+		var pods []corev1.Pod
+		for _, nodeName := range nodeNames {
+			pods = append(pods, corev1.Pod{Spec: corev1.PodSpec{NodeName: nodeName}})
+		}
+		return pods
+	}
+
+	t.Run("Returns success if the plugin is configured on every node", func(t *testing.T) {
+		err := checkCNIPluginNodes(
+			[]corev1.Node{node("node1", ready, configured), node("node2", configured)},
+			cniPods("node1", "node2"),
+		)
+		if err != nil {
+			t.Fatalf("Unexpected error message: %s", err.Error())
+		}
+	})
+
+	t.Run("Returns an error if the plugin is not configured on some nodes", func(t *testing.T) {
+		err := checkCNIPluginNodes(
+			[]corev1.Node{node("node1", configured), node("node2", notConfigured), node("node3", ready)},
+			cniPods("node1", "node2", "node3"),
+		)
+		if err == nil {
+			t.Fatal("Expected error, got nothing")
+		}
+		expected := `linkerd-cni plugin is not configured on some nodes:
+	* node2: open /host/etc/cni/net.d: no such file or directory
+	* node3: no status reported by the linkerd-cni installer`
+		if err.Error() != expected {
+			t.Fatalf("Unexpected error message: %s", err.Error())
+		}
+	})
+
+	t.Run("Skips the nodes that don't run the linkerd-cni DaemonSet", func(t *testing.T) {
+		// e.g. a tainted or non-Linux node
+		err := checkCNIPluginNodes(
+			[]corev1.Node{node("node1", ready, configured), node("node2", ready)},
+			cniPods("node1"),
+		)
+		if err != nil {
+			t.Fatalf("Unexpected error message: %s", err.Error())
+		}
+	})
+
+	t.Run("Returns an error if no linkerd-cni pods are running", func(t *testing.T) {
+		err := checkCNIPluginNodes([]corev1.Node{node("node1", ready)}, nil)
+		if err == nil {
+			t.Fatal("Expected error, got nothing")
+		}
+		expected := "no linkerd-cni pods found, the linkerd-cni DaemonSet may not be installed"
+		if err.Error() != expected {
+			t.Fatalf("Unexpected error message: %s", err.Error())
+		}
+	})
+}
+
+func TestIdentityTrustAnchorChecks(t *testing.T) {
+	newRoot := func(name string) *tls.CA {
+		root, err := tls.GenerateRootCAWithDefaults(name)
+		if err != nil {
+			t.Fatalf("failed to create CA: %s", err)
+		}
+		return root
+	}
+	oldRoot := newRoot("old")
+	newRootCA := newRoot("new")
+	oldAnchor := oldRoot.Cred.Crt.Certificate
+	newAnchor := newRootCA.Cred.Crt.Certificate
+
+	issuer, err := newRootCA.GenerateCA("identity.linkerd.cluster.local", newRootCA.Validity, -1)
+	if err != nil {
+		t.Fatalf("failed to create issuer: %s", err)
+	}
+	issuerCrt := &issuer.Cred.Crt
+
+	proxyPod := func(name string, anchors ...*x509.Certificate) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "emojivoto"},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "web"},
+					{
+						Name: k8s.ProxyContainerName,
+						Env: []corev1.EnvVar{
+							{Name: k8s.IdentityTrustAnchorsEnvVar, Value: tls.EncodeCertificatesPEM(anchors...)},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	proxies, err := proxyTrustAnchors([]corev1.Pod{
+		proxyPod("old", oldAnchor),
+		proxyPod("bundled", oldAnchor, newAnchor),
+		proxyPod("new", newAnchor),
+		{ObjectMeta: metav1.ObjectMeta{Name: "unmeshed", Namespace: "emojivoto"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(proxies) != 3 {
+		t.Fatalf("expected trust anchors for 3 proxies, got %d", len(proxies))
+	}
+
+	t.Run("Finds the trust anchors that sign the issuer", func(t *testing.T) {
+		signers := issuerTrustAnchors(issuerCrt, []*x509.Certificate{oldAnchor, newAnchor})
+		if len(signers) != 1 || !signers[0].Equal(newAnchor) {
+			t.Fatalf("expected the issuer to be signed by the new anchor only, got %d anchors", len(signers))
+		}
+		if len(issuerTrustAnchors(issuerCrt, []*x509.Certificate{oldAnchor})) != 0 {
+			t.Fatal("expected the issuer not to be signed by the old anchor")
+		}
+	})
+
+	t.Run("Warns while trust anchors are bundled", func(t *testing.T) {
+		if err := checkTrustAnchorRotation(issuerCrt, []*x509.Certificate{newAnchor}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		err := checkTrustAnchorRotation(issuerCrt, []*x509.Certificate{oldAnchor, newAnchor})
+		if err == nil || !strings.Contains(err.Error(), "is not signed by: old") {
+			t.Fatalf("expected rotation to be reported, got: %v", err)
+		}
+	})
+
+	t.Run("Fails if proxies do not trust the issuer", func(t *testing.T) {
+		err := checkDataPlaneTrustsIssuer(proxies, []*x509.Certificate{newAnchor})
+		if err == nil || err.Error() != "proxies do not trust the issuer and must be restarted: emojivoto/old" {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := checkDataPlaneTrustsIssuer(proxies, []*x509.Certificate{oldAnchor, newAnchor}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	t.Run("Warns if proxies have outdated trust anchors", func(t *testing.T) {
+		err := checkDataPlaneTrustAnchors(proxies, []*x509.Certificate{oldAnchor, newAnchor})
+		if err == nil || err.Error() != "proxies have outdated trust anchors and should be restarted: emojivoto/new, emojivoto/old" {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package healthcheck
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	healthcheckPb "github.com/linkerd/linkerd2/controller/gen/common/healthcheck"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	checkStatus = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "health_check_status",
+			Help: "The status of each health check in its latest run: 1 if it passed, 0 if it failed.",
+		},
+		[]string{"category", "description", "severity"},
+	)
+
+	checkDuration = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "health_check_duration_seconds",
+			Help: "The time taken by each health check in its latest run.",
+		},
+		[]string{"category", "description", "severity"},
+	)
+
+	checkLastRun = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "health_check_last_run_timestamp_seconds",
+			Help: "The time at which the latest run of the health checks completed, in seconds since the Unix epoch.",
+		},
+	)
+)
+
+// registerMetrics registers the gauges the first time a Monitor is created,
+// so that they are only exported by the processes that run checks, and not by
+// every process that imports this package.
+var registerMetrics sync.Once
+
+// Monitor runs health checks periodically from within the control plane. It
+// exports the status of each check as Prometheus gauges, and keeps the results
+// of the latest run so that they can be served by the public API.
+type Monitor struct {
+	newHealthChecker func() *HealthChecker
+	interval         time.Duration
+
+	mu      sync.RWMutex
+	results []*CheckResult
+	// exported holds the label values of the gauges set by the latest run, so
+	// that the gauges of checks that didn't run again can be removed.
+	exported map[[3]string]struct{}
+}
+
+// NewMonitor creates a Monitor that runs the checks of categoryIDs every
+// interval. The categories must not need CLI-only context, such as a
+// kubeconfig that isn't available in the cluster or user interaction.
+func NewMonitor(categoryIDs []CategoryID, options *Options, interval time.Duration) *Monitor {
+	registerMetrics.Do(func() {
+		prometheus.MustRegister(checkStatus, checkDuration, checkLastRun)
+	})
+
+	return &Monitor{
+		newHealthChecker: func() *HealthChecker {
+			return NewHealthChecker(categoryIDs, options)
+		},
+		interval: interval,
+		exported: make(map[[3]string]struct{}),
+	}
+}
+
+// Run runs the checks immediately and then every interval, until stop is
+// closed.
+func (m *Monitor) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		m.runChecks()
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// runChecks runs the checks once, with a new HealthChecker so that no state
+// is carried over from the previous run.
+func (m *Monitor) runChecks() {
+	var results []*CheckResult
+	hc := m.newHealthChecker()
+	success := hc.RunChecks(func(result *CheckResult) {
+		if result.Retry {
+			return
+		}
+		results = append(results, result)
+	})
+	log.Debugf("Health checks completed, success: %t", success)
+
+	exported := make(map[[3]string]struct{})
+	for _, result := range results {
+		severity := "error"
+		if result.Warning {
+			severity = "warning"
+		}
+		labels := [3]string{string(result.Category), result.Description, severity}
+
+		status := 1.0
+		if result.Err != nil {
+			status = 0
+			log.Debugf("Health check failed: %s %s: %s", result.Category, result.Description, result.Err)
+		}
+		checkStatus.WithLabelValues(labels[:]...).Set(status)
+		checkDuration.WithLabelValues(labels[:]...).Set(result.Duration.Seconds())
+		exported[labels] = struct{}{}
+	}
+	checkLastRun.Set(float64(time.Now().Unix()))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Checks that were skipped because a fatal check failed no longer have a
+	// known status.
+	for labels := range m.exported {
+		if _, ok := exported[labels]; !ok {
+			checkStatus.DeleteLabelValues(labels[:]...)
+			checkDuration.DeleteLabelValues(labels[:]...)
+		}
+	}
+	m.exported = exported
+	m.results = results
+}
+
+// Results returns the results of the latest run, or nil if the checks haven't
+// completed yet.
+func (m *Monitor) Results() []*CheckResult {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.results
+}
+
+// SelfCheckResults returns the results of the latest run for the public API's
+// SelfCheck endpoint, using each check's category as its subsystem name.
+// Failed warnings are reported as OK, with the warning as the message, since
+// they don't affect the overall health of Linkerd.
+func (m *Monitor) SelfCheckResults() []*healthcheckPb.CheckResult {
+	var checkResults []*healthcheckPb.CheckResult
+	for _, result := range m.Results() {
+		checkResult := &healthcheckPb.CheckResult{
+			SubsystemName:    string(result.Category),
+			CheckDescription: result.Description,
+			Status:           healthcheckPb.CheckStatus_OK,
+		}
+		if result.Err != nil {
+			if result.Warning {
+				checkResult.FriendlyMessageToUser = fmt.Sprintf("warning: %s", result.Err)
+			} else {
+				checkResult.Status = healthcheckPb.CheckStatus_FAIL
+				checkResult.FriendlyMessageToUser = result.Err.Error()
+			}
+		}
+		checkResults = append(checkResults, checkResult)
+	}
+	return checkResults
+}
@@ -0,0 +1,115 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	healthcheckPb "github.com/linkerd/linkerd2/controller/gen/common/healthcheck"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestMonitor(t *testing.T) {
+	fatal := false
+	newHealthChecker := func() *HealthChecker {
+		hc := NewHealthChecker([]CategoryID{}, &Options{})
+		hc.addCategory(category{
+			id: "cat1",
+			checkers: []checker{
+				{
+					description: "desc1",
+					fatal:       true,
+					check: func(context.Context) error {
+						if fatal {
+							return fmt.Errorf("fatal")
+						}
+						return nil
+					},
+				},
+				{
+					description: "desc2",
+					warning:     true,
+					check: func(context.Context) error {
+						return fmt.Errorf("warning")
+					},
+				},
+			},
+		})
+		return hc
+	}
+	monitor := &Monitor{
+		newHealthChecker: newHealthChecker,
+		interval:         time.Minute,
+		exported:         make(map[[3]string]struct{}),
+	}
+
+	gauge := func(description, severity string) *float64 {
+		metric, err := checkStatus.GetMetricWithLabelValues("cat1", description, severity)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		m := &dto.Metric{}
+		if err := metric.Write(m); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		return m.GetGauge().Value
+	}
+
+	if results := monitor.SelfCheckResults(); len(results) != 0 {
+		t.Fatalf("Expected no results before the first run, got %v", results)
+	}
+
+	t.Run("Exports the status of each check", func(t *testing.T) {
+		monitor.runChecks()
+
+		if *gauge("desc1", "error") != 1 {
+			t.Fatal("Expected desc1 to be reported as passing")
+		}
+		if *gauge("desc2", "warning") != 0 {
+			t.Fatal("Expected desc2 to be reported as failing")
+		}
+
+		expected := []*healthcheckPb.CheckResult{
+			{
+				SubsystemName:    "cat1",
+				CheckDescription: "desc1",
+				Status:           healthcheckPb.CheckStatus_OK,
+			},
+			{
+				SubsystemName:         "cat1",
+				CheckDescription:      "desc2",
+				Status:                healthcheckPb.CheckStatus_OK,
+				FriendlyMessageToUser: "warning: warning",
+			},
+		}
+		if results := monitor.SelfCheckResults(); !reflect.DeepEqual(results, expected) {
+			t.Fatalf("Expected results %v, got %v", expected, results)
+		}
+	})
+
+	t.Run("Removes the status of skipped checks", func(t *testing.T) {
+		fatal = true
+		monitor.runChecks()
+
+		if *gauge("desc1", "error") != 0 {
+			t.Fatal("Expected desc1 to be reported as failing")
+		}
+		if _, ok := monitor.exported[[3]string{"cat1", "desc2", "warning"}]; ok {
+			t.Fatal("Expected desc2 to be removed")
+		}
+
+		expected := []*healthcheckPb.CheckResult{
+			{
+				SubsystemName:         "cat1",
+				CheckDescription:      "desc1",
+				Status:                healthcheckPb.CheckStatus_FAIL,
+				FriendlyMessageToUser: "fatal",
+			},
+		}
+		if results := monitor.SelfCheckResults(); !reflect.DeepEqual(results, expected) {
+			t.Fatalf("Expected results %v, got %v", expected, results)
+		}
+	})
+}
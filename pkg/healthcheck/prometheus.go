@@ -0,0 +1,235 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	pb "github.com/linkerd/linkerd2/controller/gen/public"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	promApi "github.com/prometheus/client_golang/api"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	prometheusDeployment = "linkerd-prometheus"
+	prometheusPort       = 9090
+	proxyScrapeJob       = "linkerd-proxy"
+
+	// scrapeStalenessThreshold is how long a proxy can go without being
+	// scraped; the linkerd-proxy job is scraped every 10 seconds.
+	scrapeStalenessThreshold = time.Minute
+
+	// prometheusCounterWindow is the range over which Prometheus' own counters
+	// are checked for increases.
+	prometheusCounterWindow = "5m"
+)
+
+// prometheusCounter is one of Prometheus' own metrics that counts a problem.
+// The version of Prometheus installed by Linkerd doesn't serve its TSDB
+// status over the API, so the health of its storage, scrapes and rules is
+// read from the metrics it reports about itself through its 'prometheus'
+// scrape job.
+type prometheusCounter struct {
+	metric      string
+	description string
+}
+
+var (
+	droppedSampleCounters = []prometheusCounter{
+		{"prometheus_target_scrapes_sample_out_of_order_total", "samples dropped for being out of order"},
+		{"prometheus_target_scrapes_sample_duplicate_timestamp_total", "samples dropped for duplicate timestamps"},
+		{"prometheus_target_scrapes_sample_out_of_bounds_total", "samples dropped for being out of bounds"},
+		{"prometheus_target_scrapes_exceeded_sample_limit_total", "scrapes dropped for exceeding the sample limit"},
+	}
+
+	tsdbFailureCounters = []prometheusCounter{
+		{"prometheus_tsdb_compactions_failed_total", "failed compactions"},
+		{"prometheus_tsdb_head_truncations_failed_total", "failed head truncations"},
+		{"prometheus_tsdb_reloads_failures_total", "failed block reloads"},
+		{"prometheus_tsdb_wal_corruptions_total", "WAL corruptions"},
+	}
+
+	ruleFailureCounters = []prometheusCounter{
+		{"prometheus_rule_evaluation_failures_total", "failed rule evaluations"},
+		{"prometheus_rule_group_iterations_missed_total", "missed rule group evaluations"},
+	}
+)
+
+// newPrometheusAPI returns a client for the control plane's Prometheus, using
+// the PrometheusURL option if set, or a port-forward to the Prometheus
+// deployment otherwise. The port-forward, if any, is returned so that it can
+// be stopped when the client is no longer used.
+func (hc *HealthChecker) newPrometheusAPI(ctx context.Context) (promv1.API, *k8s.PortForward, error) {
+	if hc.PrometheusURL != "" {
+		promAPI, err := newPrometheusClient(hc.PrometheusURL)
+		return promAPI, nil, err
+	}
+
+	portforward, err := k8s.NewPortForward(
+		hc.kubeAPI,
+		hc.ControlPlaneNamespace,
+		prometheusDeployment,
+		0,
+		prometheusPort,
+		false,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := portforward.Init(ctx); err != nil {
+		log.Debugf("Port forward failed: %v", err)
+		portforward.Stop()
+		return nil, nil, err
+	}
+
+	promAPI, err := newPrometheusClient(portforward.URLFor(""))
+	if err != nil {
+		portforward.Stop()
+		return nil, nil, err
+	}
+	return promAPI, portforward, nil
+}
+
+// newPrometheusClient returns a client for the Prometheus API at address.
+func newPrometheusClient(address string) (promv1.API, error) {
+	client, err := promApi.NewClient(promApi.Config{Address: address})
+	if err != nil {
+		return nil, err
+	}
+	return promv1.NewAPI(client), nil
+}
+
+// fetchScrapeTargets connects to Prometheus and fetches its active scrape
+// targets, which are kept for the checks that follow along with the
+// connection to Prometheus.
+func (hc *HealthChecker) fetchScrapeTargets(ctx context.Context) error {
+	promAPI, portforward, err := hc.newPrometheusAPI(ctx)
+	if err != nil {
+		return err
+	}
+	targets, err := promAPI.Targets(ctx)
+	if err == nil {
+		err = commitState(ctx, func() {
+			hc.promAPI = promAPI
+			hc.promPortForward = portforward
+			hc.scrapeTargets = targets.Active
+		})
+	}
+	if err != nil && portforward != nil {
+		portforward.Stop()
+	}
+	return err
+}
+
+// stopPrometheusPortForward stops the port-forward to Prometheus opened by
+// fetchScrapeTargets, if any.
+func (hc *HealthChecker) stopPrometheusPortForward() {
+	if hc.promPortForward != nil {
+		hc.promPortForward.Stop()
+		hc.promPortForward = nil
+	}
+}
+
+// checkPrometheusCounters fails if any of counters increased within
+// prometheusCounterWindow.
+func (hc *HealthChecker) checkPrometheusCounters(ctx context.Context, counters []prometheusCounter) error {
+	if hc.promAPI == nil {
+		return fmt.Errorf("unable to query Prometheus")
+	}
+
+	increases := make([]float64, len(counters))
+	for i, counter := range counters {
+		query := fmt.Sprintf("sum(increase(%s[%s]))", counter.metric, prometheusCounterWindow)
+		value, err := hc.promAPI.Query(ctx, query, time.Now())
+		if err != nil {
+			return fmt.Errorf("query failed: %s: %s", query, err)
+		}
+		if vector, ok := value.(model.Vector); ok && len(vector) > 0 {
+			increases[i] = float64(vector[0].Value)
+		}
+	}
+	return checkCounterIncreases(counters, increases)
+}
+
+func checkCounterIncreases(counters []prometheusCounter, increases []float64) error {
+	var problems []string
+	for i, counter := range counters {
+		// increase() extrapolates, so it can return fractions of a count
+		if increases[i] >= 0.5 {
+			problems = append(problems, fmt.Sprintf("\t* %.0f %s", increases[i], counter.description))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("Prometheus reported problems in the last %s:\n%s", prometheusCounterWindow, strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// proxyScrapeTargets indexes the targets of the linkerd-proxy scrape job by
+// "namespace/pod", the format of pb.Pod names.
+func proxyScrapeTargets(targets []promv1.ActiveTarget) map[string][]promv1.ActiveTarget {
+	proxyTargets := make(map[string][]promv1.ActiveTarget)
+	for _, target := range targets {
+		if target.Labels["job"] != proxyScrapeJob {
+			continue
+		}
+		name := fmt.Sprintf("%s/%s", target.Labels["namespace"], target.Labels["pod"])
+		proxyTargets[name] = append(proxyTargets[name], target)
+	}
+	return proxyTargets
+}
+
+// checkMissingScrapeTargets fails if a running meshed pod is not a target of
+// the linkerd-proxy scrape job.
+func checkMissingScrapeTargets(pods []*pb.Pod, targets []promv1.ActiveTarget) error {
+	proxyTargets := proxyScrapeTargets(targets)
+
+	var missing []string
+	for _, pod := range pods {
+		if pod.Status != "Running" {
+			continue
+		}
+		if _, ok := proxyTargets[pod.Name]; !ok {
+			missing = append(missing, pod.Name)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("pods are not Prometheus scrape targets: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkStaleScrapes fails if the latest scrape of a meshed pod failed, or if
+// it's older than threshold.
+func checkStaleScrapes(pods []*pb.Pod, targets []promv1.ActiveTarget, threshold time.Duration, now time.Time) error {
+	proxyTargets := proxyScrapeTargets(targets)
+
+	var stale []string
+	for _, pod := range pods {
+		for _, target := range proxyTargets[pod.Name] {
+			switch {
+			case target.Health == promv1.HealthBad:
+				stale = append(stale, fmt.Sprintf("\t* %s: scrape of %s failed: %s", pod.Name, target.ScrapeURL, target.LastError))
+			case target.LastScrape.IsZero():
+				stale = append(stale, fmt.Sprintf("\t* %s: %s has not been scraped", pod.Name, target.ScrapeURL))
+			case now.Sub(target.LastScrape) > threshold:
+				stale = append(stale, fmt.Sprintf("\t* %s: %s last scraped %s ago", pod.Name, target.ScrapeURL, now.Sub(target.LastScrape).Round(time.Second)))
+			}
+		}
+	}
+
+	if len(stale) > 0 {
+		sort.Strings(stale)
+		return fmt.Errorf("some proxies have not been scraped recently:\n%s", strings.Join(stale, "\n"))
+	}
+	return nil
+}
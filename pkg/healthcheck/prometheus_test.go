@@ -0,0 +1,116 @@
+package healthcheck
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/linkerd/linkerd2/controller/gen/public"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+func proxyTarget(namespace, pod string, health promv1.HealthStatus, lastScrape time.Time) promv1.ActiveTarget {
+	return promv1.ActiveTarget{
+		Labels: model.LabelSet{
+			"job":       proxyScrapeJob,
+			"namespace": model.LabelValue(namespace),
+			"pod":       model.LabelValue(pod),
+		},
+		ScrapeURL:  "http://" + pod + ":4191/metrics",
+		LastError:  "connection refused",
+		LastScrape: lastScrape,
+		Health:     health,
+	}
+}
+
+func TestCheckMissingScrapeTargets(t *testing.T) {
+	now := time.Now()
+	pods := []*pb.Pod{
+		{Name: "emojivoto/web", Status: "Running"},
+		{Name: "emojivoto/voting", Status: "Running"},
+		{Name: "emojivoto/emoji", Status: "Pending"},
+		{Name: "books/web", Status: "Running"},
+	}
+
+	testCases := []struct {
+		name     string
+		targets  []promv1.ActiveTarget
+		expected string
+	}{
+		{
+			name: "Passes when all running pods are targets",
+			targets: []promv1.ActiveTarget{
+				proxyTarget("emojivoto", "web", promv1.HealthGood, now),
+				proxyTarget("emojivoto", "voting", promv1.HealthGood, now),
+				proxyTarget("books", "web", promv1.HealthGood, now),
+			},
+		},
+		{
+			name: "Fails when running pods are not targets of the proxy job",
+			targets: []promv1.ActiveTarget{
+				proxyTarget("emojivoto", "web", promv1.HealthGood, now),
+				{Labels: model.LabelSet{"job": "grafana", "namespace": "books", "pod": "web"}},
+			},
+			expected: "pods are not Prometheus scrape targets: books/web, emojivoto/voting",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // pin
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkMissingScrapeTargets(pods, tc.targets)
+			if tc.expected == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.expected {
+				t.Fatalf("Expected error %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestCheckStaleScrapes(t *testing.T) {
+	now := time.Now()
+	pods := []*pb.Pod{
+		{Name: "emojivoto/web", Status: "Running"},
+		{Name: "emojivoto/voting", Status: "Running"},
+		{Name: "emojivoto/emoji", Status: "Running"},
+		{Name: "books/web", Status: "Running"},
+	}
+	targets := []promv1.ActiveTarget{
+		proxyTarget("emojivoto", "web", promv1.HealthGood, now.Add(-10*time.Second)),
+		proxyTarget("emojivoto", "voting", promv1.HealthGood, now.Add(-5*time.Minute)),
+		proxyTarget("emojivoto", "emoji", promv1.HealthBad, now),
+		proxyTarget("books", "web", promv1.HealthUnknown, time.Time{}),
+	}
+
+	err := checkStaleScrapes(pods, targets, time.Minute, now)
+	expected := `some proxies have not been scraped recently:
+	* books/web: http://web:4191/metrics has not been scraped
+	* emojivoto/emoji: scrape of http://emoji:4191/metrics failed: connection refused
+	* emojivoto/voting: http://voting:4191/metrics last scraped 5m0s ago`
+	if err == nil || err.Error() != expected {
+		t.Fatalf("Expected error %q, got %v", expected, err)
+	}
+
+	if err := checkStaleScrapes(pods[:1], targets, time.Minute, now); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+}
+
+func TestCheckCounterIncreases(t *testing.T) {
+	if err := checkCounterIncreases(tsdbFailureCounters, []float64{0, 0.2, 0, 0}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	err := checkCounterIncreases(tsdbFailureCounters, []float64{2, 0, 0, 1.1})
+	expected := `Prometheus reported problems in the last 5m:
+	* 2 failed compactions
+	* 1 WAL corruptions`
+	if err == nil || err.Error() != expected {
+		t.Fatalf("Expected error %q, got %v", expected, err)
+	}
+}
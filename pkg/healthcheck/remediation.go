@@ -0,0 +1,326 @@
+package healthcheck
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	"github.com/linkerd/linkerd2/pkg/tls"
+	arv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// remediation is an automated fix for the condition detected by a failed
+// check.
+type remediation struct {
+	// description is a short description of the fix, used to ask for
+	// confirmation before it's applied
+	description string
+
+	// fix is the function that's called to apply the fix; it returns a
+	// description of each change it made
+	fix func() ([]string, error)
+}
+
+// runRemediation applies the checker's fix, if it has one and the fix is
+// confirmed, and then runs the check again. It returns true if the check
+// passes after the fix.
+func (hc *HealthChecker) runRemediation(categoryID CategoryID, c *checker, failed *CheckResult, observer checkObserver) bool {
+	if c.remediation == nil || hc.ConfirmFix == nil || !hc.ConfirmFix(failed) {
+		return false
+	}
+
+	changes, err := c.remediation.fix()
+	observer(&CheckResult{
+		Category:    categoryID,
+		Description: c.remediation.description,
+		HintAnchor:  c.hintAnchor,
+		Warning:     c.warning,
+		Remediation: true,
+		Changes:     changes,
+		Err:         err,
+	})
+	if err != nil {
+		return false
+	}
+
+	queue := newResultQueue()
+	hc.runChecker(categoryID, c, queue)
+	return queue.drain(observer)
+}
+
+// createServiceAccounts creates the control plane ServiceAccounts that are
+// missing.
+func (hc *HealthChecker) createServiceAccounts() ([]string, error) {
+	var changes []string
+	for _, name := range expectedServiceAccountNames() {
+		sa := &corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: hc.ControlPlaneNamespace,
+				Labels:    hc.controlPlaneLabels(strings.TrimPrefix(name, "linkerd-")),
+			},
+		}
+		_, err := hc.kubeAPI.CoreV1().ServiceAccounts(hc.ControlPlaneNamespace).Create(sa)
+		if kerrors.IsAlreadyExists(err) {
+			continue
+		}
+		if err != nil {
+			return changes, err
+		}
+		changes = append(changes, fmt.Sprintf("created ServiceAccount %s/%s", hc.ControlPlaneNamespace, name))
+	}
+	return changes, nil
+}
+
+// createClusterRoleBindings creates the control plane ClusterRoleBindings
+// that are missing, binding each component's ClusterRole to its
+// ServiceAccount.
+func (hc *HealthChecker) createClusterRoleBindings() ([]string, error) {
+	prefix := fmt.Sprintf("linkerd-%s-", hc.ControlPlaneNamespace)
+
+	var changes []string
+	for _, name := range hc.expectedRBACNames() {
+		component := strings.TrimPrefix(name, prefix)
+		crb := &rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: hc.controlPlaneLabels(component),
+			},
+			Subjects: []rbacv1.Subject{
+				{
+					Kind:      rbacv1.ServiceAccountKind,
+					Name:      "linkerd-" + component,
+					Namespace: hc.ControlPlaneNamespace,
+				},
+			},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "ClusterRole",
+				Name:     name,
+			},
+		}
+		_, err := hc.kubeAPI.RbacV1().ClusterRoleBindings().Create(crb)
+		if kerrors.IsAlreadyExists(err) {
+			continue
+		}
+		if err != nil {
+			return changes, err
+		}
+		changes = append(changes, fmt.Sprintf("created ClusterRoleBinding %s", name))
+	}
+	return changes, nil
+}
+
+func (hc *HealthChecker) controlPlaneLabels(component string) map[string]string {
+	return map[string]string{
+		k8s.ControllerComponentLabel: component,
+		k8s.ControllerNSLabel:        hc.ControlPlaneNamespace,
+	}
+}
+
+// webhooks returns the names of the webhook services, in the same order as
+// the certificates returned by fetchWebhookCrts.
+func webhooks() []string {
+	return []string{k8s.ProxyInjectorWebhookServiceName, k8s.SPValidatorWebhookServiceName}
+}
+
+// webhookClientConfigs returns the client configs of a webhook's
+// configuration.
+func (hc *HealthChecker) webhookClientConfigs(webhook string) ([]arv1beta1.WebhookClientConfig, error) {
+	var configs []arv1beta1.WebhookClientConfig
+	switch webhook {
+	case k8s.ProxyInjectorWebhookServiceName:
+		mwc, err := hc.kubeAPI.AdmissionregistrationV1beta1().MutatingWebhookConfigurations().Get(k8s.ProxyInjectorWebhookConfigName, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, wh := range mwc.Webhooks {
+			configs = append(configs, wh.ClientConfig)
+		}
+	case k8s.SPValidatorWebhookServiceName:
+		vwc, err := hc.kubeAPI.AdmissionregistrationV1beta1().ValidatingWebhookConfigurations().Get(k8s.SPValidatorWebhookConfigName, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, wh := range vwc.Webhooks {
+			configs = append(configs, wh.ClientConfig)
+		}
+	}
+	return configs, nil
+}
+
+// checkWebhookConfigs fails if a webhook configuration doesn't point to its
+// webhook service, or doesn't trust the certificate the webhook serves.
+func (hc *HealthChecker) checkWebhookConfigs() error {
+	// hc.webhookCrts isn't set if the webhook secrets couldn't be read
+	if len(hc.webhookCrts) != len(webhooks()) {
+		return errors.New("the webhook certs could not be read")
+	}
+
+	var stale []string
+	for i, webhook := range webhooks() {
+		configs, err := hc.webhookClientConfigs(webhook)
+		if err != nil {
+			return err
+		}
+		for _, config := range configs {
+			if err := hc.checkWebhookClientConfig(webhook, config, hc.webhookCrts[i]); err != nil {
+				stale = append(stale, fmt.Sprintf("\t* %s: %s", webhook, err))
+			}
+		}
+	}
+
+	if len(stale) > 0 {
+		return fmt.Errorf("some webhook configurations are stale:\n%s", strings.Join(stale, "\n"))
+	}
+	return nil
+}
+
+func (hc *HealthChecker) checkWebhookClientConfig(webhook string, config arv1beta1.WebhookClientConfig, crt *x509.Certificate) error {
+	if config.Service == nil || config.Service.Name != webhook || config.Service.Namespace != hc.ControlPlaneNamespace {
+		return fmt.Errorf("does not point to the %s/%s service", hc.ControlPlaneNamespace, webhook)
+	}
+	caBundle, err := tls.DecodePEMCrt(string(config.CABundle))
+	if err != nil {
+		return fmt.Errorf("invalid caBundle: %s", err)
+	}
+	if !caBundle.Certificate.Equal(crt) {
+		return fmt.Errorf("caBundle does not match the %s secret", k8s.WebhookSecretName(webhook))
+	}
+	return nil
+}
+
+// updateWebhookConfigs points the webhook configurations to the webhook
+// services, and sets their caBundles to the certificates in the webhook
+// secrets.
+func (hc *HealthChecker) updateWebhookConfigs() ([]string, error) {
+	var changes []string
+	for _, webhook := range webhooks() {
+		secret, err := hc.kubeAPI.CoreV1().Secrets(hc.ControlPlaneNamespace).Get(k8s.WebhookSecretName(webhook), metav1.GetOptions{})
+		if err != nil {
+			return changes, err
+		}
+		changed, err := hc.updateWebhookConfig(webhook, secret.Data["crt.pem"])
+		if err != nil {
+			return changes, err
+		}
+		if changed {
+			changes = append(changes, fmt.Sprintf("updated the %s webhook configuration", webhook))
+		}
+	}
+	return changes, nil
+}
+
+// updateWebhookConfig updates a webhook's configuration to point to its
+// service with caBundle, and returns whether it was changed.
+func (hc *HealthChecker) updateWebhookConfig(webhook string, caBundle []byte) (bool, error) {
+	update := func(config *arv1beta1.WebhookClientConfig) bool {
+		if config.Service != nil && config.Service.Name == webhook &&
+			config.Service.Namespace == hc.ControlPlaneNamespace && bytes.Equal(config.CABundle, caBundle) {
+			return false
+		}
+		path := "/"
+		if config.Service != nil && config.Service.Path != nil {
+			path = *config.Service.Path
+		}
+		config.Service = &arv1beta1.ServiceReference{Name: webhook, Namespace: hc.ControlPlaneNamespace, Path: &path}
+		config.URL = nil
+		config.CABundle = caBundle
+		return true
+	}
+
+	changed := false
+	switch webhook {
+	case k8s.ProxyInjectorWebhookServiceName:
+		client := hc.kubeAPI.AdmissionregistrationV1beta1().MutatingWebhookConfigurations()
+		mwc, err := client.Get(k8s.ProxyInjectorWebhookConfigName, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for i := range mwc.Webhooks {
+			changed = update(&mwc.Webhooks[i].ClientConfig) || changed
+		}
+		if changed {
+			_, err = client.Update(mwc)
+		}
+		return changed, err
+	case k8s.SPValidatorWebhookServiceName:
+		client := hc.kubeAPI.AdmissionregistrationV1beta1().ValidatingWebhookConfigurations()
+		vwc, err := client.Get(k8s.SPValidatorWebhookConfigName, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for i := range vwc.Webhooks {
+			changed = update(&vwc.Webhooks[i].ClientConfig) || changed
+		}
+		if changed {
+			_, err = client.Update(vwc)
+		}
+		return changed, err
+	}
+	return false, nil
+}
+
+// renewWebhookCrts replaces the webhook certificates that expire within
+// threshold, updates the webhook configurations to trust the new
+// certificates, and restarts the webhook pods so that they serve them.
+func (hc *HealthChecker) renewWebhookCrts(threshold time.Duration) ([]string, error) {
+	var changes []string
+	for _, webhook := range webhooks() {
+		secrets := hc.kubeAPI.CoreV1().Secrets(hc.ControlPlaneNamespace)
+		secret, err := secrets.Get(k8s.WebhookSecretName(webhook), metav1.GetOptions{})
+		if err != nil {
+			return changes, err
+		}
+		crt, err := tls.DecodePEMCrt(string(secret.Data["crt.pem"]))
+		if err == nil && checkCertsValidity("webhook", []*x509.Certificate{crt.Certificate}, time.Now()) == nil &&
+			checkCertsExpiry("webhook", []*x509.Certificate{crt.Certificate}, threshold, time.Now()) == nil {
+			continue
+		}
+
+		// The webhook certificates are self-signed, as generated by `linkerd
+		// install`.
+		root, err := tls.GenerateRootCAWithDefaults(fmt.Sprintf("%s.%s.svc", webhook, hc.ControlPlaneNamespace))
+		if err != nil {
+			return changes, err
+		}
+		crtPEM := root.Cred.Crt.EncodeCertificatePEM()
+		secret.Data = map[string][]byte{
+			"crt.pem": []byte(crtPEM),
+			"key.pem": []byte(root.Cred.EncodePrivateKeyPEM()),
+		}
+		if _, err := secrets.Update(secret); err != nil {
+			return changes, err
+		}
+		changes = append(changes, fmt.Sprintf("renewed the %s certificate", webhook))
+
+		if _, err := hc.updateWebhookConfig(webhook, []byte(crtPEM)); err != nil {
+			return changes, err
+		}
+		changes = append(changes, fmt.Sprintf("updated the %s webhook configuration", webhook))
+
+		component := strings.TrimPrefix(webhook, "linkerd-")
+		err = hc.kubeAPI.CoreV1().Pods(hc.ControlPlaneNamespace).DeleteCollection(&metav1.DeleteOptions{}, metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("%s=%s", k8s.ControllerComponentLabel, component),
+		})
+		if err != nil {
+			return changes, err
+		}
+		changes = append(changes, fmt.Sprintf("restarted the %s pods", component))
+	}
+
+	crts, err := hc.fetchWebhookCrts()
+	if err != nil {
+		return changes, err
+	}
+	hc.webhookCrts = crts
+	return changes, nil
+}
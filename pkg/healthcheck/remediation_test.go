@@ -0,0 +1,279 @@
+package healthcheck
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	"github.com/linkerd/linkerd2/pkg/tls"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRunRemediation(t *testing.T) {
+	newCategory := func(fixed *bool) category {
+		return category{
+			id: "cat1",
+			checkers: []checker{
+				{
+					description: "desc1",
+					check: func(context.Context) error {
+						if !*fixed {
+							return fmt.Errorf("broken")
+						}
+						return nil
+					},
+					remediation: &remediation{
+						description: "fix desc1",
+						fix: func() ([]string, error) {
+							*fixed = true
+							return []string{"fixed desc1"}, nil
+						},
+					},
+				},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name            string
+		confirmFix      func(*CheckResult) bool
+		expectedSuccess bool
+		expectedResults []string
+	}{
+		{
+			name:            "Does not fix checks if fixes are disabled",
+			expectedSuccess: false,
+			expectedResults: []string{"cat1 desc1 (fix desc1): broken"},
+		},
+		{
+			name:            "Does not fix checks if the fix is declined",
+			confirmFix:      func(*CheckResult) bool { return false },
+			expectedSuccess: false,
+			expectedResults: []string{"cat1 desc1 (fix desc1): broken"},
+		},
+		{
+			name:            "Fixes checks and runs them again",
+			confirmFix:      func(*CheckResult) bool { return true },
+			expectedSuccess: true,
+			expectedResults: []string{
+				"cat1 desc1 (fix desc1): broken",
+				"cat1 fix desc1 [fixed desc1]",
+				"cat1 desc1",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // pin
+		t.Run(tc.name, func(t *testing.T) {
+			hc := NewHealthChecker(
+				[]CategoryID{},
+				&Options{ConfirmFix: tc.confirmFix},
+			)
+			fixed := false
+			hc.addCategory(newCategory(&fixed))
+
+			results := []string{}
+			success := hc.RunChecks(func(result *CheckResult) {
+				res := fmt.Sprintf("%s %s", result.Category, result.Description)
+				if result.Fix != "" {
+					res += fmt.Sprintf(" (%s)", result.Fix)
+				}
+				if result.Remediation {
+					res += fmt.Sprintf(" %v", result.Changes)
+				}
+				if result.Err != nil {
+					res += fmt.Sprintf(": %s", result.Err)
+				}
+				results = append(results, res)
+			})
+
+			if success != tc.expectedSuccess {
+				t.Fatalf("Expected success to be %t, but got %t", tc.expectedSuccess, success)
+			}
+			if !reflect.DeepEqual(results, tc.expectedResults) {
+				t.Fatalf("Expected results %v, but got %v", tc.expectedResults, results)
+			}
+		})
+	}
+}
+
+func TestCreateMissingResources(t *testing.T) {
+	hc := NewHealthChecker([]CategoryID{}, &Options{ControlPlaneNamespace: "test-ns"})
+	var err error
+	hc.kubeAPI, err = k8s.NewFakeAPI(`
+kind: ServiceAccount
+apiVersion: v1
+metadata:
+  name: linkerd-controller
+  namespace: test-ns
+`, `
+kind: ClusterRoleBinding
+apiVersion: rbac.authorization.k8s.io/v1
+metadata:
+  name: linkerd-test-ns-controller
+`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	changes, err := hc.createServiceAccounts()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(changes) != len(expectedServiceAccountNames())-1 {
+		t.Fatalf("Unexpected changes: %v", changes)
+	}
+	if err := hc.checkServiceAccounts(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	changes, err = hc.createClusterRoleBindings()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(changes) != len(hc.expectedRBACNames())-1 {
+		t.Fatalf("Unexpected changes: %v", changes)
+	}
+	if err := hc.checkClusterRoleBindings(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	crb, err := hc.kubeAPI.RbacV1().ClusterRoleBindings().Get("linkerd-test-ns-tap", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if crb.RoleRef.Name != "linkerd-test-ns-tap" || crb.Subjects[0].Name != "linkerd-tap" || crb.Subjects[0].Namespace != "test-ns" {
+		t.Fatalf("Unexpected ClusterRoleBinding: %+v", crb)
+	}
+}
+
+func TestWebhookRemediation(t *testing.T) {
+	crtPEM := func(webhook string) string {
+		root, err := tls.GenerateRootCAWithDefaults(webhook)
+		if err != nil {
+			t.Fatalf("Failed to create CA: %s", err)
+		}
+		return root.Cred.Crt.EncodeCertificatePEM()
+	}
+	secret := func(webhook, crt string) string {
+		return fmt.Sprintf(`
+apiVersion: v1
+kind: Secret
+metadata:
+  name: %s
+  namespace: linkerd
+data:
+  crt.pem: %s
+`, k8s.WebhookSecretName(webhook), base64.StdEncoding.EncodeToString([]byte(crt)))
+	}
+
+	injectorCrt := crtPEM(k8s.ProxyInjectorWebhookServiceName)
+	validatorCrt := crtPEM(k8s.SPValidatorWebhookServiceName)
+
+	hc := NewHealthChecker([]CategoryID{}, &Options{ControlPlaneNamespace: "linkerd"})
+	var err error
+	hc.kubeAPI, err = k8s.NewFakeAPI(
+		secret(k8s.ProxyInjectorWebhookServiceName, injectorCrt),
+		secret(k8s.SPValidatorWebhookServiceName, validatorCrt),
+		fmt.Sprintf(`
+apiVersion: admissionregistration.k8s.io/v1beta1
+kind: MutatingWebhookConfiguration
+metadata:
+  name: %s
+webhooks:
+- name: linkerd-proxy-injector.linkerd.io
+  clientConfig:
+    service:
+      name: linkerd-proxy-injector
+      namespace: linkerd
+    caBundle: %s
+`, k8s.ProxyInjectorWebhookConfigName, base64.StdEncoding.EncodeToString([]byte(injectorCrt))),
+		fmt.Sprintf(`
+apiVersion: admissionregistration.k8s.io/v1beta1
+kind: ValidatingWebhookConfiguration
+metadata:
+  name: %s
+webhooks:
+- name: linkerd-sp-validator.linkerd.io
+  clientConfig:
+    service:
+      name: linkerd-sp-validator
+      namespace: other
+    caBundle: %s
+`, k8s.SPValidatorWebhookConfigName, base64.StdEncoding.EncodeToString([]byte(injectorCrt))),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	hc.webhookCrts, err = hc.fetchWebhookCrts()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	t.Run("Fails when the webhook secrets are missing", func(t *testing.T) {
+		hc := NewHealthChecker([]CategoryID{}, &Options{ControlPlaneNamespace: "linkerd"})
+		var err error
+		hc.kubeAPI, err = k8s.NewFakeAPI()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if _, err := hc.fetchWebhookCrts(); err == nil {
+			t.Fatal("Expected an error fetching the webhook certs")
+		}
+
+		err = hc.checkWebhookConfigs()
+		expected := "the webhook certs could not be read"
+		if err == nil || err.Error() != expected {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Updates stale webhook configurations", func(t *testing.T) {
+		err := hc.checkWebhookConfigs()
+		expected := "some webhook configurations are stale:\n\t* linkerd-sp-validator: does not point to the linkerd/linkerd-sp-validator service"
+		if err == nil || err.Error() != expected {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		changes, err := hc.updateWebhookConfigs()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(changes, []string{"updated the linkerd-sp-validator webhook configuration"}) {
+			t.Fatalf("Unexpected changes: %v", changes)
+		}
+		if err := hc.checkWebhookConfigs(); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	})
+
+	t.Run("Renews webhook certificates", func(t *testing.T) {
+		changes, err := hc.renewWebhookCrts(0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(changes) != 0 {
+			t.Fatalf("Unexpected changes: %v", changes)
+		}
+
+		// every certificate expires within a century
+		changes, err = hc.renewWebhookCrts(100 * 365 * 24 * time.Hour)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(changes) != 6 {
+			t.Fatalf("Unexpected changes: %v", changes)
+		}
+		if hc.webhookCrts[0].Subject.CommonName != "linkerd-proxy-injector.linkerd.svc" {
+			t.Fatalf("Unexpected certificate: %s", hc.webhookCrts[0].Subject.CommonName)
+		}
+		if err := hc.checkWebhookConfigs(); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	})
+}
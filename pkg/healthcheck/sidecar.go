@@ -12,27 +12,46 @@ import (
 func HasExistingSidecars(podSpec *corev1.PodSpec) bool {
 	for _, container := range podSpec.Containers {
 		if strings.HasPrefix(container.Image, "gcr.io/linkerd-io/proxy:") ||
-			strings.HasPrefix(container.Image, "gcr.io/istio-release/proxyv2:") ||
+			container.Name == k8s.ProxyContainerName {
+			return true
+		}
+	}
+
+	for _, ic := range podSpec.InitContainers {
+		if strings.HasPrefix(ic.Image, "gcr.io/linkerd-io/proxy-init:") ||
+			ic.Name == "linkerd-init" {
+			return true
+		}
+	}
+
+	return len(OtherMeshSidecars(podSpec)) > 0
+}
+
+// OtherMeshSidecars returns the names of the containers and init containers in
+// the pod spec that belong to service meshes other than Linkerd, such as
+// Istio, Contour and Envoy.
+func OtherMeshSidecars(podSpec *corev1.PodSpec) []string {
+	var names []string
+
+	for _, container := range podSpec.Containers {
+		if strings.HasPrefix(container.Image, "gcr.io/istio-release/proxyv2:") ||
 			strings.HasPrefix(container.Image, "gcr.io/heptio-images/contour:") ||
 			strings.HasPrefix(container.Image, "docker.io/envoyproxy/envoy-alpine:") ||
-			container.Name == k8s.ProxyContainerName ||
 			container.Name == "istio-proxy" ||
 			container.Name == "contour" ||
 			container.Name == "envoy" {
-			return true
+			names = append(names, container.Name)
 		}
 	}
 
 	for _, ic := range podSpec.InitContainers {
-		if strings.HasPrefix(ic.Image, "gcr.io/linkerd-io/proxy-init:") ||
-			strings.HasPrefix(ic.Image, "gcr.io/istio-release/proxy_init:") ||
+		if strings.HasPrefix(ic.Image, "gcr.io/istio-release/proxy_init:") ||
 			strings.HasPrefix(ic.Image, "gcr.io/heptio-images/contour:") ||
-			ic.Name == "linkerd-init" ||
 			ic.Name == "istio-init" ||
 			ic.Name == "envoy-initconfig" {
-			return true
+			names = append(names, ic.Name)
 		}
 	}
 
-	return false
+	return names
 }
@@ -0,0 +1,219 @@
+package identity
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/linkerd/linkerd2/pkg/tls"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultIssuerReloadInterval is the default interval at which issuer
+	// credentials and trust anchors are checked for changes.
+	DefaultIssuerReloadInterval = 10 * time.Second
+)
+
+type (
+	// ReloadingIssuer implements tls.Issuer with a CA that is loaded from issuer
+	// credentials on disk. The CA is replaced whenever the issuer credentials or
+	// the trust anchors change and the new credentials can be verified against
+	// the trust anchors.
+	ReloadingIssuer struct {
+		keyPath, crtPath string
+		expectedName     string
+		validity         tls.Validity
+		trustAnchors     func() (string, error)
+
+		mu                   sync.RWMutex
+		ca                   *tls.CA
+		key, crt, anchorsPEM string
+	}
+)
+
+var (
+	issuerExpiry = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "identity_issuer_expiry_timestamp_seconds",
+			Help: "The time at which the current issuer certificate expires, in seconds since the Unix epoch.",
+		},
+	)
+
+	issuerExpiryRemaining = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "identity_issuer_expiry_remaining_seconds",
+			Help: "The number of seconds until the issuer certificate expires; NaN until the issuer is known.",
+		},
+		func() float64 {
+			expiry := atomic.LoadInt64(&issuerNotAfter)
+			if expiry == 0 {
+				return math.NaN()
+			}
+			return time.Until(time.Unix(expiry, 0)).Seconds()
+		},
+	)
+
+	issuerReloads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "identity_issuer_reloads_total",
+			Help: "A counter of attempts to load changed issuer credentials or trust anchors.",
+		},
+		[]string{"result"},
+	)
+
+	// issuerNotAfter holds the Unix time at which the most recently observed
+	// issuer certificate expires.
+	issuerNotAfter int64
+)
+
+func init() {
+	prometheus.MustRegister(issuerExpiry, issuerExpiryRemaining, issuerReloads)
+}
+
+// observeIssuerExpiry records the expiry of the current issuer certificate.
+func observeIssuerExpiry(notAfter time.Time) {
+	atomic.StoreInt64(&issuerNotAfter, notAfter.Unix())
+	issuerExpiry.Set(float64(notAfter.Unix()))
+}
+
+// NewReloadingIssuer loads issuer credentials from the given paths and
+// verifies them for expectedName against the trust anchors returned by
+// trustAnchors, which must produce PEM-encoded certificates.
+//
+// An error is returned if the initial credentials cannot be loaded.
+func NewReloadingIssuer(
+	keyPath, crtPath, expectedName string,
+	validity tls.Validity,
+	trustAnchors func() (string, error),
+) (*ReloadingIssuer, error) {
+	ri := &ReloadingIssuer{
+		keyPath:      keyPath,
+		crtPath:      crtPath,
+		expectedName: expectedName,
+		validity:     validity,
+		trustAnchors: trustAnchors,
+	}
+	if _, err := ri.Reload(); err != nil {
+		return nil, err
+	}
+	return ri, nil
+}
+
+// IssueEndEntityCrt signs the certificate request with the current CA.
+func (ri *ReloadingIssuer) IssueEndEntityCrt(csr *x509.CertificateRequest) (tls.Crt, error) {
+	ri.mu.RLock()
+	ca := ri.ca
+	ri.mu.RUnlock()
+
+	if ca == nil {
+		return tls.Crt{}, errors.New("no issuer credentials loaded")
+	}
+	return ca.IssueEndEntityCrt(csr)
+}
+
+// IssueEndEntityCrtWithLifetime signs the certificate request with the current
+// CA, so that the certificate is valid for no longer than lifetime.
+func (ri *ReloadingIssuer) IssueEndEntityCrtWithLifetime(csr *x509.CertificateRequest, lifetime time.Duration) (tls.Crt, error) {
+	ri.mu.RLock()
+	ca := ri.ca
+	ri.mu.RUnlock()
+
+	if ca == nil {
+		return tls.Crt{}, errors.New("no issuer credentials loaded")
+	}
+	return ca.IssueEndEntityCrtWithLifetime(csr, lifetime)
+}
+
+// Reload reads the issuer credentials and trust anchors and, if either has
+// changed, replaces the current CA. It returns true if the CA was replaced.
+//
+// If the new credentials cannot be decoded or verified, an error is returned
+// and the current CA continues to be used.
+func (ri *ReloadingIssuer) Reload() (bool, error) {
+	keyb, err := ioutil.ReadFile(ri.keyPath)
+	if err != nil {
+		return false, err
+	}
+	crtb, err := ioutil.ReadFile(ri.crtPath)
+	if err != nil {
+		return false, err
+	}
+	anchorsPEM, err := ri.trustAnchors()
+	if err != nil {
+		return false, err
+	}
+	key, crt := string(keyb), string(crtb)
+
+	ri.mu.RLock()
+	unchanged := ri.ca != nil && key == ri.key && crt == ri.crt && anchorsPEM == ri.anchorsPEM
+	ri.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	ca, err := ri.load(key, crt, anchorsPEM)
+	if err != nil {
+		issuerReloads.WithLabelValues("failure").Inc()
+		return false, err
+	}
+
+	ri.mu.Lock()
+	ri.ca = ca
+	ri.key, ri.crt, ri.anchorsPEM = key, crt, anchorsPEM
+	ri.mu.Unlock()
+
+	issuerReloads.WithLabelValues("success").Inc()
+	observeIssuerExpiry(ca.Cred.Crt.Certificate.NotAfter)
+	return true, nil
+}
+
+// Watch periodically reloads the issuer until stop is closed. Failures are
+// logged and the previously loaded CA remains in use.
+func (ri *ReloadingIssuer) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			updated, err := ri.Reload()
+			if err != nil {
+				log.Errorf("failed to reload issuer credentials: %s", err)
+				continue
+			}
+			if updated {
+				ri.mu.RLock()
+				crt := ri.ca.Cred.Crt.Certificate
+				ri.mu.RUnlock()
+				log.Infof("reloaded issuer credentials for %s; valid until %s", ri.expectedName, crt.NotAfter)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (ri *ReloadingIssuer) load(key, crt, anchorsPEM string) (*tls.CA, error) {
+	trustAnchors, err := tls.DecodePEMCertPool(anchorsPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trust anchors: %s", err)
+	}
+
+	creds, err := tls.DecodePEMCreds(key, crt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read issuer credentials: %s", err)
+	}
+
+	if err := creds.Crt.Verify(trustAnchors, ri.expectedName); err != nil {
+		return nil, fmt.Errorf("failed to verify issuer credentials for '%s' with trust anchors: %s", ri.expectedName, err)
+	}
+
+	return tls.NewCA(*creds, ri.validity), nil
+}
@@ -0,0 +1,165 @@
+package identity
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/linkerd/linkerd2/pkg/tls"
+)
+
+const testIssuerName = "identity.linkerd.cluster.local"
+
+func writeIssuer(t *testing.T, dir string, issuer *tls.Cred) {
+	key := issuer.EncodePrivateKeyPEM()
+	if err := ioutil.WriteFile(filepath.Join(dir, "key.pem"), []byte(key), 0600); err != nil {
+		t.Fatalf("failed to write key: %s", err)
+	}
+	crt := issuer.Crt.EncodeCertificatePEM()
+	if err := ioutil.WriteFile(filepath.Join(dir, "crt.pem"), []byte(crt), 0600); err != nil {
+		t.Fatalf("failed to write certificate: %s", err)
+	}
+}
+
+// newIssuer creates issuer credentials for testIssuerName signed by root.
+func newIssuer(t *testing.T, root *tls.CA) *tls.Cred {
+	key, err := tls.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	notBefore, notAfter := root.Validity.Window(time.Now())
+	crt, err := root.Cred.SignCrt(&x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: testIssuerName},
+		DNSNames:              []string{testIssuerName},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		PublicKey:             &key.PublicKey,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	})
+	if err != nil {
+		t.Fatalf("failed to create issuer: %s", err)
+	}
+	return &tls.Cred{PrivateKey: key, Crt: crt}
+}
+
+func TestReloadingIssuer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issuer")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	root, err := tls.GenerateRootCAWithDefaults("root")
+	if err != nil {
+		t.Fatalf("failed to create root: %s", err)
+	}
+	anchors := root.Cred.Crt.EncodeCertificatePEM()
+	trustAnchors := func() (string, error) { return anchors, nil }
+
+	first := newIssuer(t, root)
+	writeIssuer(t, dir, first)
+
+	ri, err := NewReloadingIssuer(
+		filepath.Join(dir, "key.pem"),
+		filepath.Join(dir, "crt.pem"),
+		testIssuerName,
+		tls.Validity{},
+		trustAnchors,
+	)
+	if err != nil {
+		t.Fatalf("failed to load issuer: %s", err)
+	}
+
+	issuedBy := func() *x509.Certificate {
+		key, err := tls.GenerateKey()
+		if err != nil {
+			t.Fatalf("failed to generate key: %s", err)
+		}
+		csr := x509.CertificateRequest{
+			Subject:   pkix.Name{CommonName: "foo.ns.serviceaccount.identity.linkerd.cluster.local"},
+			DNSNames:  []string{"foo.ns.serviceaccount.identity.linkerd.cluster.local"},
+			PublicKey: &key.PublicKey,
+		}
+		crt, err := ri.IssueEndEntityCrt(&csr)
+		if err != nil {
+			t.Fatalf("failed to issue certificate: %s", err)
+		}
+		return crt.TrustChain[len(crt.TrustChain)-1]
+	}
+
+	t.Run("Does not reload unchanged credentials", func(t *testing.T) {
+		updated, err := ri.Reload()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if updated {
+			t.Fatal("expected unchanged credentials not to be reloaded")
+		}
+		if !issuedBy().Equal(first.Crt.Certificate) {
+			t.Fatal("expected certificate to be issued by the initial issuer")
+		}
+	})
+
+	t.Run("Reloads changed credentials", func(t *testing.T) {
+		second := newIssuer(t, root)
+		writeIssuer(t, dir, second)
+
+		updated, err := ri.Reload()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !updated {
+			t.Fatal("expected changed credentials to be reloaded")
+		}
+		if !issuedBy().Equal(second.Crt.Certificate) {
+			t.Fatal("expected certificate to be issued by the new issuer")
+		}
+	})
+
+	t.Run("Keeps the current issuer if new credentials do not verify", func(t *testing.T) {
+		current := issuedBy()
+
+		other, err := tls.GenerateRootCAWithDefaults("other")
+		if err != nil {
+			t.Fatalf("failed to create root: %s", err)
+		}
+		writeIssuer(t, dir, newIssuer(t, other))
+
+		if _, err := ri.Reload(); err == nil {
+			t.Fatal("expected untrusted credentials to be rejected")
+		}
+		if !issuedBy().Equal(current) {
+			t.Fatal("expected certificate to be issued by the previous issuer")
+		}
+	})
+
+	t.Run("Reloads credentials when trust anchors change", func(t *testing.T) {
+		other, err := tls.GenerateRootCAWithDefaults("other")
+		if err != nil {
+			t.Fatalf("failed to create root: %s", err)
+		}
+		third := newIssuer(t, other)
+		writeIssuer(t, dir, third)
+		anchors = other.Cred.Crt.EncodeCertificatePEM()
+
+		updated, err := ri.Reload()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !updated {
+			t.Fatal("expected credentials to be reloaded")
+		}
+		if !issuedBy().Equal(third.Crt.Certificate) {
+			t.Fatal("expected certificate to be issued by the new issuer")
+		}
+	})
+}
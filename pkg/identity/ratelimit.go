@@ -0,0 +1,100 @@
+package identity
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// Rate limits, used to label metrics.
+	rateLimitIdentity = "identity"
+	rateLimitIP       = "ip"
+
+	// rateLimiterSweepInterval is the minimum interval at which idle buckets
+	// are discarded.
+	rateLimiterSweepInterval = time.Minute
+)
+
+type (
+	// RateLimit configures a token bucket: requests are allowed at Rate per
+	// second on average, with bursts of up to Burst requests. A zero Rate
+	// disables the limit.
+	RateLimit struct {
+		Rate  float64
+		Burst int
+	}
+
+	// rateLimiter maintains a token bucket per key, e.g. per identity.
+	rateLimiter struct {
+		limit RateLimit
+
+		sync.Mutex
+		buckets   map[string]*bucket
+		lastSweep time.Time
+	}
+
+	bucket struct {
+		*rate.Limiter
+		lastSeen time.Time
+	}
+)
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	return &rateLimiter{
+		limit:   limit,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// reserve takes a token from key's bucket. If the bucket is empty, it returns
+// false and the time after which a token will be available.
+func (l *rateLimiter) reserve(key string, now time.Time) (bool, time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{Limiter: rate.NewLimiter(rate.Limit(l.limit.Rate), l.limit.Burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	r := b.ReserveN(now, 1)
+	if !r.OK() {
+		// The burst is too small to ever allow a request.
+		return false, rateLimiterSweepInterval
+	}
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// sweep discards the buckets that have been idle long enough to refill, since
+// they are indistinguishable from new buckets. It must be called with the lock
+// held.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimiterSweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	refill := time.Duration(float64(l.limit.Burst) / l.limit.Rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) > refill {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// len returns the number of buckets being tracked.
+func (l *rateLimiter) len() int {
+	l.Lock()
+	defer l.Unlock()
+	return len(l.buckets)
+}
@@ -0,0 +1,40 @@
+package identity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(RateLimit{Rate: 1, Burst: 2})
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.reserve("a", now); !ok {
+			t.Fatalf("expected request %d to be allowed within the burst", i)
+		}
+	}
+	ok, retryAfter := l.reserve("a", now)
+	if ok {
+		t.Fatal("expected request to be limited after the burst")
+	}
+	if retryAfter <= 0 || retryAfter > time.Second {
+		t.Fatalf("expected to retry within a second, got %s", retryAfter)
+	}
+	if ok, _ := l.reserve("b", now); !ok {
+		t.Fatal("expected other keys to be unaffected")
+	}
+
+	// Limited requests don't consume tokens.
+	if ok, _ := l.reserve("a", now.Add(retryAfter)); !ok {
+		t.Fatal("expected request to be allowed after the retry delay")
+	}
+
+	if n := l.len(); n != 2 {
+		t.Fatalf("expected 2 buckets, got %d", n)
+	}
+	l.reserve("c", now.Add(rateLimiterSweepInterval+time.Second))
+	if n := l.len(); n != 1 {
+		t.Fatalf("expected idle buckets to be discarded, got %d buckets", n)
+	}
+}
@@ -5,14 +5,21 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/golang/protobuf/ptypes"
 	pb "github.com/linkerd/linkerd2-proxy-api/go/identity"
 	"github.com/linkerd/linkerd2/pkg/tls"
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
@@ -20,6 +27,18 @@ const (
 	// DefaultIssuanceLifetime is the default lifetime of certificates issued by
 	// the identity service.
 	DefaultIssuanceLifetime = 24 * time.Hour
+
+	// Outcomes of Certify requests, used to label metrics.
+	certifyInvalidRequest    = "invalid_request"
+	certifyInvalidCSR        = "invalid_csr"
+	certifyInvalidToken      = "invalid_token"
+	certifyNotAuthenticated  = "not_authenticated"
+	certifyIdentityMismatch  = "identity_mismatch"
+	certifyDenied            = "denied"
+	certifyRateLimited       = "rate_limited"
+	certifyValidationFailure = "validation_failure"
+	certifyIssuanceFailure   = "issuance_failure"
+	certifySuccess           = "success"
 )
 
 type (
@@ -27,6 +46,21 @@ type (
 	Service struct {
 		Validator
 		tls.Issuer
+
+		// audit, if set, records every certificate issued by the service.
+		audit *log.Logger
+
+		// denylist, if set, restricts the issuance of certificates to
+		// compromised identities.
+		denylist Denylist
+
+		// uriSANs, if set, adds the SPIFFE ID of each identity to the
+		// certificates issued for it.
+		uriSANs bool
+
+		// identityLimiter and ipLimiter, if set, limit the rate of requests
+		// for each identity and from each source IP.
+		identityLimiter, ipLimiter *rateLimiter
 	}
 
 	// Validator implementors accept a bearer token, validates it, and returns a
@@ -43,6 +77,41 @@ type (
 		Validate(context.Context, []byte) (string, error)
 	}
 
+	// CSRValidator is a Validator that also considers the certificate signing
+	// request in which a token is presented, e.g. to bind tokens to the
+	// requester's key.
+	CSRValidator interface {
+		Validator
+
+		// ValidateCSR is like Validate, but is also provided the certificate
+		// signing request, which has already been checked against the requested
+		// identity.
+		ValidateCSR(context.Context, []byte, *x509.CertificateRequest) (string, error)
+	}
+
+	// URIValidator is a Validator that can also produce a URI-form (SPIFFE)
+	// identifier for the identities it validates.
+	URIValidator interface {
+		Validator
+
+		// URI returns the URI-form identifier for a DNS-like identifier
+		// returned by Validate.
+		URI(identity string) (*url.URL, error)
+	}
+
+	// Denylist implementors restrict the issuance of certificates to
+	// identities that may have been compromised.
+	Denylist interface {
+		// Check returns a Denied error if no certificates may be issued for the
+		// DNS-like identity. Otherwise, it returns the maximum lifetime of
+		// certificates issued for the identity, or zero if it is unrestricted.
+		Check(identity string) (time.Duration, error)
+	}
+
+	// Denied is an error type returned by Denylists to indicate that no
+	// certificates may be issued for an identity.
+	Denied struct{ Reason string }
+
 	// InvalidToken is an error type returned by Validators to indicate that the
 	// provided authentication token was not valid.
 	InvalidToken struct{ Reason string }
@@ -52,9 +121,98 @@ type (
 	NotAuthenticated struct{}
 )
 
+var (
+	certifyRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "identity_certify_requests_total",
+			Help: "A counter of certification requests, by outcome.",
+		},
+		[]string{"result"},
+	)
+
+	certifyLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "identity_certify_duration_seconds",
+			Help:    "A histogram of the time taken to handle certification requests, by outcome.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"result"},
+	)
+
+	rateLimitedRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "identity_certify_rate_limited_total",
+			Help: "A counter of certification requests rejected by a rate limit, by limit and by the namespace of the authenticated identity.",
+		},
+		// The namespace is only set for the identity limit: the IP limit is
+		// checked before the requester is authenticated, and an unauthenticated
+		// identity would let requesters create arbitrary series.
+		[]string{"limit", "namespace"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(certifyRequests, certifyLatency, rateLimitedRequests)
+}
+
 // NewService creates a new identity service.
 func NewService(v Validator, i tls.Issuer) *Service {
-	return &Service{v, i}
+	return &Service{Validator: v, Issuer: i}
+}
+
+// EnableAuditLog configures the service to write a JSON-formatted record of
+// every certificate it issues to w.
+//
+// It must be called before the service handles any requests.
+func (svc *Service) EnableAuditLog(w io.Writer) {
+	audit := log.New()
+	audit.Out = w
+	audit.Formatter = &log.JSONFormatter{}
+	audit.Level = log.InfoLevel
+	svc.audit = audit
+}
+
+// EnableURISANs configures the service to add a URI SAN with the SPIFFE ID
+// of the validated identity to every certificate it issues. The service's
+// Validator must implement URIValidator.
+//
+// It must be called before the service handles any requests.
+func (svc *Service) EnableURISANs() error {
+	if _, ok := svc.Validator.(URIValidator); !ok {
+		return errors.New("the validator does not support URI identities")
+	}
+	svc.uriSANs = true
+	return nil
+}
+
+// EnableDenylist configures the service to consult d before issuing a
+// certificate. Identities with a restricted lifetime can only be issued
+// certificates if the service's Issuer implements tls.LifetimeIssuer.
+//
+// It must be called before the service handles any requests.
+func (svc *Service) EnableDenylist(d Denylist) {
+	svc.denylist = d
+}
+
+// EnableRateLimits configures the service to limit the rate of requests for
+// each authenticated identity and from each source IP. A zero Rate disables
+// the corresponding limit.
+//
+// It must be called before the service handles any requests.
+func (svc *Service) EnableRateLimits(perIdentity, perIP RateLimit) error {
+	for name, l := range map[string]RateLimit{rateLimitIdentity: perIdentity, rateLimitIP: perIP} {
+		if l.Rate < 0 || (l.Rate > 0 && l.Burst < 1) {
+			return fmt.Errorf("invalid %s rate limit: %v requests/s with a burst of %d", name, l.Rate, l.Burst)
+		}
+	}
+
+	if perIdentity.Rate > 0 {
+		svc.identityLimiter = newRateLimiter(perIdentity)
+	}
+	if perIP.Rate > 0 {
+		svc.ipLimiter = newRateLimiter(perIP)
+	}
+	return nil
 }
 
 // Register registers an identity service implementation in the provided gRPC
@@ -65,30 +223,48 @@ func Register(g *grpc.Server, s *Service) {
 
 // Certify validates identity and signs certificates.
 func (svc *Service) Certify(ctx context.Context, req *pb.CertifyRequest) (*pb.CertifyResponse, error) {
+	result := certifyInvalidRequest
+	defer func(start time.Time) {
+		certifyRequests.WithLabelValues(result).Inc()
+		certifyLatency.WithLabelValues(result).Observe(time.Since(start).Seconds())
+	}(time.Now())
+
 	// Extract the relevant info from the request.
 	reqIdentity, tok, csr, err := checkRequest(req)
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
+
+	// Shed requests from crash-looping workloads before doing any work for
+	// them.
+	if err = svc.checkRateLimit(ctx, svc.ipLimiter, rateLimitIP, requester(ctx), ""); err != nil {
+		result = certifyRateLimited
+		return nil, err
+	}
+
 	if err = checkCSR(csr, reqIdentity); err != nil {
 		log.Debugf("requester sent invalid CSR: %s", err)
+		result = certifyInvalidCSR
 		return nil, status.Error(codes.FailedPrecondition, err.Error())
 	}
 
-	// Authenticate the provided token against the Kubernetes API.
+	// Authenticate the provided token.
 	log.Debugf("Validating token for %s", reqIdentity)
-	tokIdentity, err := svc.Validate(ctx, tok)
+	tokIdentity, err := svc.validate(ctx, tok, csr)
 	if err != nil {
 		switch e := err.(type) {
 		case NotAuthenticated:
 			log.Infof("authentication failed for %s: %s", reqIdentity, e)
+			result = certifyNotAuthenticated
 			return nil, status.Error(codes.FailedPrecondition, e.Error())
 		case InvalidToken:
 			log.Debugf("invalid token provided for %s: %s", reqIdentity, e)
+			result = certifyInvalidToken
 			return nil, status.Error(codes.InvalidArgument, e.Error())
 		default:
 			msg := fmt.Sprintf("error validating token for %s: %s", reqIdentity, e)
 			log.Error(msg)
+			result = certifyValidationFailure
 			return nil, status.Error(codes.Internal, msg)
 		}
 	}
@@ -98,12 +274,55 @@ func (svc *Service) Certify(ctx context.Context, req *pb.CertifyRequest) (*pb.Ce
 		msg := fmt.Sprintf("requested identity did not match provided token: requested=%s; found=%s",
 			reqIdentity, tokIdentity)
 		log.Debug(msg)
+		result = certifyIdentityMismatch
 		return nil, status.Error(codes.FailedPrecondition, msg)
 	}
 
+	// The identity is only limited once it is authenticated, so that other
+	// requesters can't exhaust its bucket.
+	if err = svc.checkRateLimit(ctx, svc.identityLimiter, rateLimitIdentity, tokIdentity, identityNamespace(tokIdentity)); err != nil {
+		result = certifyRateLimited
+		return nil, err
+	}
+
+	// Refuse or restrict certificates for denylisted identities.
+	var maxLifetime time.Duration
+	if svc.denylist != nil {
+		maxLifetime, err = svc.denylist.Check(tokIdentity)
+		if err != nil {
+			switch e := err.(type) {
+			case Denied:
+				log.Warnf("denied certificate for %s: %s", tokIdentity, e)
+				svc.auditDenied(ctx, tokIdentity, e)
+				result = certifyDenied
+				return nil, status.Error(codes.PermissionDenied, e.Error())
+			default:
+				msg := fmt.Sprintf("error checking denylist for %s: %s", tokIdentity, e)
+				log.Error(msg)
+				result = certifyValidationFailure
+				return nil, status.Error(codes.Internal, msg)
+			}
+		}
+	}
+
+	// Add the SPIFFE ID, if enabled. checkCSR ensures that the requester
+	// didn't provide any URIs of its own.
+	if svc.uriSANs {
+		uri, err := svc.Validator.(URIValidator).URI(tokIdentity)
+		if err != nil {
+			msg := fmt.Sprintf("error building URI for %s: %s", tokIdentity, err)
+			log.Error(msg)
+			result = certifyIssuanceFailure
+			return nil, status.Error(codes.Internal, msg)
+		}
+		csr.URIs = []*url.URL{uri}
+	}
+
 	// Create a certificate
-	crt, err := svc.IssueEndEntityCrt(csr)
+	crt, err := svc.issue(csr, maxLifetime)
 	if err != nil {
+		log.Errorf("failed to issue certificate for %s: %s", tokIdentity, err)
+		result = certifyIssuanceFailure
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 	crts := crt.ExtractRaw()
@@ -116,9 +335,17 @@ func (svc *Service) Certify(ctx context.Context, req *pb.CertifyRequest) (*pb.Ce
 	validUntil, err := ptypes.TimestampProto(crt.Certificate.NotAfter)
 	if err != nil {
 		log.Errorf("invalid expiry time: %s", err)
+		result = certifyIssuanceFailure
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
+	// The issuer's certificate is at the tail of the trust chain.
+	if n := len(crt.TrustChain); n > 0 {
+		observeIssuerExpiry(crt.TrustChain[n-1].NotAfter)
+	}
+	svc.auditIssued(ctx, tokIdentity, crt.Certificate)
+	result = certifySuccess
+
 	rsp := &pb.CertifyResponse{
 		LeafCertificate:          crts[0],
 		IntermediateCertificates: crts[1:],
@@ -128,6 +355,95 @@ func (svc *Service) Certify(ctx context.Context, req *pb.CertifyRequest) (*pb.Ce
 	return rsp, nil
 }
 
+// checkRateLimit takes a token from the bucket of key in l, if the limit is
+// enabled. If the bucket is empty, it returns a ResourceExhausted status that
+// tells the requester when to retry, and counts the request against namespace.
+func (svc *Service) checkRateLimit(ctx context.Context, l *rateLimiter, limit, key, namespace string) error {
+	if l == nil || key == "" {
+		return nil
+	}
+	ok, retryAfter := l.reserve(key, time.Now())
+	if ok {
+		return nil
+	}
+
+	log.Debugf("rate limited request from %s: %s limit exceeded for %s; retry after %s", requester(ctx), limit, key, retryAfter)
+	rateLimitedRequests.WithLabelValues(limit, namespace).Inc()
+	st := status.Newf(codes.ResourceExhausted, "%s rate limit exceeded for %s; retry after %s",
+		limit, key, retryAfter)
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{
+		RetryDelay: ptypes.DurationProto(retryAfter),
+	}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// validate validates tok with the service's Validator, along with csr if the
+// Validator is a CSRValidator.
+func (svc *Service) validate(ctx context.Context, tok []byte, csr *x509.CertificateRequest) (string, error) {
+	if v, ok := svc.Validator.(CSRValidator); ok {
+		return v.ValidateCSR(ctx, tok, csr)
+	}
+	return svc.Validate(ctx, tok)
+}
+
+// issue signs csr with the service's Issuer. If maxLifetime is set, the
+// certificate is valid for no longer than maxLifetime.
+func (svc *Service) issue(csr *x509.CertificateRequest, maxLifetime time.Duration) (tls.Crt, error) {
+	if maxLifetime == 0 {
+		return svc.IssueEndEntityCrt(csr)
+	}
+	li, ok := svc.Issuer.(tls.LifetimeIssuer)
+	if !ok {
+		return tls.Crt{}, errors.New("the issuer cannot restrict certificate lifetimes")
+	}
+	return li.IssueEndEntityCrtWithLifetime(csr, maxLifetime)
+}
+
+// auditIssued records an issued certificate in the audit log, if one is
+// configured.
+func (svc *Service) auditIssued(ctx context.Context, identity string, crt *x509.Certificate) {
+	if svc.audit == nil {
+		return
+	}
+
+	svc.audit.WithFields(log.Fields{
+		"identity":   identity,
+		"serial":     crt.SerialNumber.Text(16),
+		"not_before": crt.NotBefore.UTC().Format(time.RFC3339),
+		"not_after":  crt.NotAfter.UTC().Format(time.RFC3339),
+		"requester":  requester(ctx),
+	}).Info("certificate issued")
+}
+
+// auditDenied records a denied certificate request in the audit log, if one
+// is configured.
+func (svc *Service) auditDenied(ctx context.Context, identity string, denied Denied) {
+	if svc.audit == nil {
+		return
+	}
+
+	svc.audit.WithFields(log.Fields{
+		"identity":  identity,
+		"reason":    denied.Reason,
+		"requester": requester(ctx),
+	}).Warn("certificate denied")
+}
+
+// requester returns the IP address of the gRPC peer, if it is known.
+func requester(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	addr := p.Addr.String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
 func checkRequest(req *pb.CertifyRequest) (string, []byte, *x509.CertificateRequest, error) {
 	reqIdentity := req.GetIdentity()
 	if reqIdentity == "" {
@@ -186,3 +502,17 @@ func (NotAuthenticated) Error() string {
 func (e InvalidToken) Error() string {
 	return e.Reason
 }
+
+func (e Denied) Error() string {
+	return e.Reason
+}
+
+// identityNamespace returns the namespace of an identity of the form
+// <name>.<namespace>.<type>.identity.<control-namespace>.<trust-domain>.
+func identityNamespace(id string) string {
+	parts := strings.SplitN(id, ".", 3)
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[1]
+}
@@ -0,0 +1,382 @@
+package identity
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	pb "github.com/linkerd/linkerd2-proxy-api/go/identity"
+	"github.com/linkerd/linkerd2/pkg/tls"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const testIdentity = "foo.ns.serviceaccount.identity.linkerd.cluster.local"
+
+// fakeValidator authenticates tokens that name an identity.
+type fakeValidator struct{}
+
+func (fakeValidator) Validate(_ context.Context, tok []byte) (string, error) {
+	switch string(tok) {
+	case "invalid":
+		return "", InvalidToken{Reason: "invalid"}
+	case "unauthenticated":
+		return "", NotAuthenticated{}
+	default:
+		return string(tok), nil
+	}
+}
+
+// fakeURIValidator is a fakeValidator that maps every identity to the same URI.
+type fakeURIValidator struct{ fakeValidator }
+
+func (fakeURIValidator) URI(string) (*url.URL, error) {
+	return url.Parse("spiffe://cluster.local/ns/ns/sa/foo")
+}
+
+// fakeCSRValidator is a fakeValidator that only accepts tokens with the key
+// of the CSR it first saw.
+type fakeCSRValidator struct {
+	fakeValidator
+	key *[]byte
+}
+
+func (v fakeCSRValidator) ValidateCSR(ctx context.Context, tok []byte, csr *x509.CertificateRequest) (string, error) {
+	if *v.key == nil {
+		*v.key = csr.RawSubjectPublicKeyInfo
+	} else if !bytes.Equal(*v.key, csr.RawSubjectPublicKeyInfo) {
+		return "", NotAuthenticated{}
+	}
+	return v.Validate(ctx, tok)
+}
+
+// fakeDenylist denies one identity and restricts all others.
+type fakeDenylist struct{ denied string }
+
+func (d fakeDenylist) Check(identity string) (time.Duration, error) {
+	if identity == d.denied {
+		return 0, Denied{Reason: "compromised"}
+	}
+	return time.Minute, nil
+}
+
+func newCertifyRequest(t *testing.T, identity, token string) *pb.CertifyRequest {
+	key, err := tls.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: identity},
+		DNSNames: []string{identity},
+	}, key)
+	if err != nil {
+		t.Fatalf("failed to create CSR: %s", err)
+	}
+	return &pb.CertifyRequest{
+		Identity:                  identity,
+		Token:                     []byte(token),
+		CertificateSigningRequest: csr,
+	}
+}
+
+func certifyCount(t *testing.T, result string) float64 {
+	var m dto.Metric
+	if err := certifyRequests.WithLabelValues(result).Write(&m); err != nil {
+		t.Fatalf("failed to read metric: %s", err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestServiceCertify(t *testing.T) {
+	ca, err := tls.GenerateRootCAWithDefaults("root")
+	if err != nil {
+		t.Fatalf("failed to create CA: %s", err)
+	}
+	svc := NewService(fakeValidator{}, ca)
+
+	testCases := []struct {
+		name   string
+		req    *pb.CertifyRequest
+		result string
+	}{
+		{"missing token", newCertifyRequest(t, testIdentity, ""), certifyInvalidRequest},
+		{"invalid token", newCertifyRequest(t, testIdentity, "invalid"), certifyInvalidToken},
+		{"unauthenticated token", newCertifyRequest(t, testIdentity, "unauthenticated"), certifyNotAuthenticated},
+		{"identity mismatch", newCertifyRequest(t, testIdentity, "bar.ns.serviceaccount.identity.linkerd.cluster.local"), certifyIdentityMismatch},
+		{"success", newCertifyRequest(t, testIdentity, testIdentity), certifySuccess},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // pin
+		t.Run(tc.name, func(t *testing.T) {
+			before := certifyCount(t, tc.result)
+			_, err := svc.Certify(context.Background(), tc.req)
+			if (err == nil) != (tc.result == certifySuccess) {
+				t.Fatalf("unexpected result: %v", err)
+			}
+			if after := certifyCount(t, tc.result); after != before+1 {
+				t.Fatalf("expected %s count to increase from %v, got %v", tc.result, before, after)
+			}
+		})
+	}
+
+	t.Run("invalid CSR", func(t *testing.T) {
+		req := newCertifyRequest(t, testIdentity, testIdentity)
+		req.Identity = "bar.ns.serviceaccount.identity.linkerd.cluster.local"
+		before := certifyCount(t, certifyInvalidCSR)
+		if _, err := svc.Certify(context.Background(), req); err == nil {
+			t.Fatal("expected an error")
+		}
+		if after := certifyCount(t, certifyInvalidCSR); after != before+1 {
+			t.Fatalf("expected %s count to increase from %v, got %v", certifyInvalidCSR, before, after)
+		}
+	})
+}
+
+func TestServiceCSRValidator(t *testing.T) {
+	ca, err := tls.GenerateRootCAWithDefaults("root")
+	if err != nil {
+		t.Fatalf("failed to create CA: %s", err)
+	}
+	svc := NewService(fakeCSRValidator{key: new([]byte)}, ca)
+
+	req := newCertifyRequest(t, testIdentity, testIdentity)
+	if _, err := svc.Certify(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := svc.Certify(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = svc.Certify(context.Background(), newCertifyRequest(t, testIdentity, testIdentity))
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition for another key, got: %v", err)
+	}
+}
+
+func rateLimitedCount(t *testing.T, limit, namespace string) float64 {
+	var m dto.Metric
+	if err := rateLimitedRequests.WithLabelValues(limit, namespace).Write(&m); err != nil {
+		t.Fatalf("failed to read metric: %s", err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestServiceRateLimits(t *testing.T) {
+	ca, err := tls.GenerateRootCAWithDefaults("root")
+	if err != nil {
+		t.Fatalf("failed to create CA: %s", err)
+	}
+
+	expectLimited := func(t *testing.T, err error) {
+		st, _ := status.FromError(err)
+		if st.Code() != codes.ResourceExhausted {
+			t.Fatalf("expected ResourceExhausted, got: %v", err)
+		}
+		for _, d := range st.Details() {
+			if info, ok := d.(*errdetails.RetryInfo); ok && info.GetRetryDelay() != nil {
+				return
+			}
+		}
+		t.Fatalf("expected a retry hint, got: %v", st.Details())
+	}
+	peerCtx := func(ip string) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{
+			Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 4143},
+		})
+	}
+	other := "bar.ns.serviceaccount.identity.linkerd.cluster.local"
+
+	t.Run("limits each identity", func(t *testing.T) {
+		svc := NewService(fakeValidator{}, ca)
+		if err := svc.EnableRateLimits(RateLimit{Rate: 0.001, Burst: 1}, RateLimit{}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if _, err := svc.Certify(peerCtx("10.0.0.1"), newCertifyRequest(t, testIdentity, testIdentity)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		before := rateLimitedCount(t, rateLimitIdentity, "ns")
+		_, err := svc.Certify(peerCtx("10.0.0.2"), newCertifyRequest(t, testIdentity, testIdentity))
+		expectLimited(t, err)
+		if after := rateLimitedCount(t, rateLimitIdentity, "ns"); after != before+1 {
+			t.Fatalf("expected rate limited count to increase from %v, got %v", before, after)
+		}
+		if _, err := svc.Certify(peerCtx("10.0.0.1"), newCertifyRequest(t, other, other)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	t.Run("only limits authenticated identities", func(t *testing.T) {
+		svc := NewService(fakeValidator{}, ca)
+		if err := svc.EnableRateLimits(RateLimit{Rate: 0.001, Burst: 1}, RateLimit{}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		// requests for testIdentity with another identity's token must not
+		// exhaust testIdentity's bucket
+		for i := 0; i < 3; i++ {
+			_, err := svc.Certify(peerCtx("10.0.0.3"), newCertifyRequest(t, testIdentity, other))
+			if status.Code(err) != codes.FailedPrecondition {
+				t.Fatalf("expected FailedPrecondition for a mismatched token, got: %v", err)
+			}
+		}
+		if _, err := svc.Certify(peerCtx("10.0.0.1"), newCertifyRequest(t, testIdentity, testIdentity)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	t.Run("limits each source IP", func(t *testing.T) {
+		svc := NewService(fakeValidator{}, ca)
+		if err := svc.EnableRateLimits(RateLimit{}, RateLimit{Rate: 0.001, Burst: 1}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if _, err := svc.Certify(peerCtx("10.0.0.1"), newCertifyRequest(t, testIdentity, testIdentity)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		before := rateLimitedCount(t, rateLimitIP, "")
+		_, err := svc.Certify(peerCtx("10.0.0.1"), newCertifyRequest(t, other, other))
+		expectLimited(t, err)
+		if after := rateLimitedCount(t, rateLimitIP, ""); after != before+1 {
+			t.Fatalf("expected rate limited count to increase from %v, got %v", before, after)
+		}
+		if _, err := svc.Certify(peerCtx("10.0.0.2"), newCertifyRequest(t, testIdentity, testIdentity)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	t.Run("rejects invalid limits", func(t *testing.T) {
+		svc := NewService(fakeValidator{}, ca)
+		if err := svc.EnableRateLimits(RateLimit{Rate: 1}, RateLimit{}); err == nil {
+			t.Fatal("expected an error for a zero burst")
+		}
+	})
+}
+
+func TestServiceAuditLog(t *testing.T) {
+	ca, err := tls.GenerateRootCAWithDefaults("root")
+	if err != nil {
+		t.Fatalf("failed to create CA: %s", err)
+	}
+	svc := NewService(fakeValidator{}, ca)
+
+	var buf bytes.Buffer
+	svc.EnableAuditLog(&buf)
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 45678},
+	})
+	if _, err := svc.Certify(ctx, newCertifyRequest(t, testIdentity, "invalid")); err == nil {
+		t.Fatal("expected an error")
+	}
+	rsp, err := svc.Certify(ctx, newCertifyRequest(t, testIdentity, testIdentity))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	crt, err := x509.ParseCertificate(rsp.GetLeafCertificate())
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %s", err)
+	}
+
+	var entry map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a single audit record, got %q: %s", buf.String(), err)
+	}
+	expected := map[string]string{
+		"identity":  testIdentity,
+		"serial":    crt.SerialNumber.Text(16),
+		"requester": "10.1.2.3",
+		"not_after": crt.NotAfter.UTC().Format(time.RFC3339),
+	}
+	for k, v := range expected {
+		if entry[k] != v {
+			t.Errorf("expected audit record %s=%s, got %s", k, v, entry[k])
+		}
+	}
+}
+
+func TestServiceURISANs(t *testing.T) {
+	ca, err := tls.GenerateRootCAWithDefaults("root")
+	if err != nil {
+		t.Fatalf("failed to create CA: %s", err)
+	}
+
+	if err := NewService(fakeValidator{}, ca).EnableURISANs(); err == nil {
+		t.Fatal("expected an error for a validator without URI support")
+	}
+
+	svc := NewService(fakeURIValidator{}, ca)
+	if err := svc.EnableURISANs(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	rsp, err := svc.Certify(context.Background(), newCertifyRequest(t, testIdentity, testIdentity))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	crt, err := x509.ParseCertificate(rsp.GetLeafCertificate())
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %s", err)
+	}
+	if len(crt.URIs) != 1 || crt.URIs[0].String() != "spiffe://cluster.local/ns/ns/sa/foo" {
+		t.Fatalf("expected a single SPIFFE URI SAN, got %v", crt.URIs)
+	}
+	if len(crt.DNSNames) != 1 || crt.DNSNames[0] != testIdentity {
+		t.Fatalf("expected the DNS SAN to be kept, got %v", crt.DNSNames)
+	}
+}
+
+func TestServiceDenylist(t *testing.T) {
+	ca, err := tls.GenerateRootCAWithDefaults("root")
+	if err != nil {
+		t.Fatalf("failed to create CA: %s", err)
+	}
+	svc := NewService(fakeValidator{}, ca)
+	svc.EnableDenylist(fakeDenylist{denied: testIdentity})
+
+	var buf bytes.Buffer
+	svc.EnableAuditLog(&buf)
+
+	t.Run("denies listed identities", func(t *testing.T) {
+		before := certifyCount(t, certifyDenied)
+		_, err := svc.Certify(context.Background(), newCertifyRequest(t, testIdentity, testIdentity))
+		if status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("expected PermissionDenied, got: %v", err)
+		}
+		if after := certifyCount(t, certifyDenied); after != before+1 {
+			t.Fatalf("expected %s count to increase from %v, got %v", certifyDenied, before, after)
+		}
+
+		var entry map[string]string
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("expected a single audit record, got %q: %s", buf.String(), err)
+		}
+		if entry["identity"] != testIdentity || entry["reason"] != "compromised" {
+			t.Fatalf("unexpected audit record: %v", entry)
+		}
+	})
+
+	t.Run("restricts the lifetime of other identities", func(t *testing.T) {
+		other := "bar.ns.serviceaccount.identity.linkerd.cluster.local"
+		rsp, err := svc.Certify(context.Background(), newCertifyRequest(t, other, other))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		crt, err := x509.ParseCertificate(rsp.GetLeafCertificate())
+		if err != nil {
+			t.Fatalf("failed to parse certificate: %s", err)
+		}
+		if lifetime := crt.NotAfter.Sub(crt.NotBefore); lifetime > time.Minute+2*tls.DefaultClockSkewAllowance {
+			t.Fatalf("expected a restricted lifetime, got %s", lifetime)
+		}
+	})
+}
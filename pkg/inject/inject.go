@@ -3,6 +3,7 @@ package inject
 import (
 	"encoding/json"
 	"fmt"
+	"path"
 	"sort"
 	"strconv"
 	"strings"
@@ -59,10 +60,14 @@ const (
 	envIdentitySvcAddr      = "LINKERD2_PROXY_IDENTITY_SVC_ADDR"
 	envIdentitySvcName      = "LINKERD2_PROXY_IDENTITY_SVC_NAME"
 	envIdentityTokenFile    = "LINKERD2_PROXY_IDENTITY_TOKEN_FILE"
-	envIdentityTrustAnchors = "LINKERD2_PROXY_IDENTITY_TRUST_ANCHORS"
+	envIdentityTrustAnchors = k8s.IdentityTrustAnchorsEnvVar
 
 	identityAPIPort = 8080
 
+	// identityTokenExpirationSeconds is the requested lifetime of projected
+	// service account tokens. The kubelet refreshes the token before it expires.
+	identityTokenExpirationSeconds = 3600
+
 	envTapDisabled = "LINKERD2_PROXY_TAP_DISABLED"
 
 	proxyInitResourceRequestCPU    = "10m"
@@ -567,7 +572,7 @@ func (conf *ResourceConfig) injectPodSpec(patch *Patch) {
 		},
 		{
 			Name:  envIdentityTokenFile,
-			Value: k8s.IdentityServiceAccountTokenPath,
+			Value: conf.proxyIdentityTokenPath(),
 		},
 		{
 			Name:  envIdentitySvcAddr,
@@ -615,6 +620,31 @@ func (conf *ResourceConfig) injectPodSpec(patch *Patch) {
 		MountPath: k8s.MountPathEndEntity,
 		ReadOnly:  false,
 	})
+
+	if conf.identityProjectedToken() {
+		expirationSeconds := int64(identityTokenExpirationSeconds)
+		patch.addVolume(&corev1.Volume{
+			Name: k8s.IdentityTokenVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources: []corev1.VolumeProjection{
+						{
+							ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+								Audience:          k8s.IdentityTokenAudience,
+								ExpirationSeconds: &expirationSeconds,
+								Path:              path.Base(k8s.IdentityProjectedTokenPath),
+							},
+						},
+					},
+				},
+			},
+		})
+		sidecar.VolumeMounts = append(sidecar.VolumeMounts, corev1.VolumeMount{
+			Name:      k8s.IdentityTokenVolumeName,
+			MountPath: k8s.MountPathIdentityToken,
+			ReadOnly:  true,
+		})
+	}
 	patch.addContainer(&sidecar)
 }
 
@@ -832,6 +862,23 @@ func (conf *ResourceConfig) identityContext() *config.IdentityContext {
 	return conf.configs.GetGlobal().GetIdentityContext()
 }
 
+func (conf *ResourceConfig) identityProjectedToken() bool {
+	if override := conf.getOverride(k8s.ProxyIdentityProjectedTokenAnnotation); override != "" {
+		value, err := strconv.ParseBool(override)
+		if err == nil && value {
+			return true
+		}
+	}
+	return false
+}
+
+func (conf *ResourceConfig) proxyIdentityTokenPath() string {
+	if conf.identityProjectedToken() {
+		return k8s.IdentityProjectedTokenPath
+	}
+	return k8s.IdentityServiceAccountTokenPath
+}
+
 func (conf *ResourceConfig) tapDisabled() bool {
 	if override := conf.getOverride(k8s.ProxyDisableTapAnnotation); override != "" {
 		value, err := strconv.ParseBool(override)
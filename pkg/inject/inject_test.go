@@ -561,4 +561,59 @@ func TestInjectPodSpec(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("projected identity token", func(t *testing.T) {
+		conf.configs = &config.All{Global: &config.Global{IdentityContext: &config.IdentityContext{}}}
+		conf.pod.spec = &corev1.PodSpec{}
+
+		for _, projected := range []bool{false, true} {
+			conf.pod.meta.Annotations = map[string]string{
+				k8s.ProxyIdentityProjectedTokenAnnotation: fmt.Sprintf("%t", projected),
+			}
+			patch := NewPatch("Deployment")
+			conf.injectPodSpec(patch)
+
+			var proxy *corev1.Container
+			var tokenVolume *corev1.Volume
+			for _, op := range patch.patchOps {
+				switch v := op.Value.(type) {
+				case *corev1.Container:
+					if v.Name == k8s.ProxyContainerName {
+						proxy = v
+					}
+				case *corev1.Volume:
+					if v.Name == k8s.IdentityTokenVolumeName {
+						tokenVolume = v
+					}
+				}
+			}
+			if proxy == nil {
+				t.Fatalf("Expected proxy container to be added to patch. Actual patch: %v", patch.patchOps)
+			}
+
+			expectedPath := k8s.IdentityServiceAccountTokenPath
+			if projected {
+				expectedPath = k8s.IdentityProjectedTokenPath
+			}
+			for _, env := range proxy.Env {
+				if env.Name == envIdentityTokenFile && env.Value != expectedPath {
+					t.Errorf("Expected token file %s, got %s", expectedPath, env.Value)
+				}
+			}
+
+			if !projected {
+				if tokenVolume != nil {
+					t.Errorf("Expected no projected token volume, got %v", tokenVolume)
+				}
+				continue
+			}
+			if tokenVolume == nil || tokenVolume.Projected == nil {
+				t.Fatalf("Expected projected token volume to be added to patch. Actual patch: %v", patch.patchOps)
+			}
+			sat := tokenVolume.Projected.Sources[0].ServiceAccountToken
+			if sat == nil || sat.Audience != k8s.IdentityTokenAudience || *sat.ExpirationSeconds != identityTokenExpirationSeconds {
+				t.Errorf("Unexpected service account token projection: %+v", sat)
+			}
+		}
+	})
 }
@@ -50,6 +50,51 @@ func ResourceAuthz(
 	return fmt.Errorf("not authorized to access %s", gk)
 }
 
+// UserResourceAuthz checks whether a given user, with the given groups, is
+// authorized to perform a given action, on behalf of which the Kubernetes
+// client makes requests.
+func UserResourceAuthz(
+	k8sClient kubernetes.Interface,
+	user string, groups []string,
+	namespace, verb, group, version, resource, name string,
+) error {
+	sar := &authV1.SubjectAccessReview{
+		Spec: authV1.SubjectAccessReviewSpec{
+			User:   user,
+			Groups: groups,
+			ResourceAttributes: &authV1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      verb,
+				Group:     group,
+				Version:   version,
+				Resource:  resource,
+				Name:      name,
+			},
+		},
+	}
+
+	result, err := k8sClient.
+		AuthorizationV1().
+		SubjectAccessReviews().
+		Create(sar)
+	if err != nil {
+		return err
+	}
+
+	if result.Status.Allowed {
+		return nil
+	}
+
+	gk := schema.GroupKind{
+		Group: group,
+		Kind:  resource,
+	}
+	if len(result.Status.Reason) > 0 {
+		return fmt.Errorf("%s is not authorized to %s %s: %s", user, verb, gk, result.Status.Reason)
+	}
+	return fmt.Errorf("%s is not authorized to %s %s", user, verb, gk)
+}
+
 // ServiceProfilesAccess checks whether the ServiceProfile CRD is installed
 // on the cluster and the client is authorized to access ServiceProfiles.
 func ServiceProfilesAccess(k8sClient kubernetes.Interface) error {
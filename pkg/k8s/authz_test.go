@@ -60,6 +60,20 @@ subjects:
 	}
 }
 
+func TestUserResourceAuthz(t *testing.T) {
+	k8sClient, err := NewFakeAPI()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// the fake client doesn't evaluate RBAC, so the review is never allowed
+	err = UserResourceAuthz(k8sClient, "alice", []string{"devs"}, "emojivoto", "list", "", "", "pods", "")
+	expected := errors.New("alice is not authorized to list pods")
+	if !reflect.DeepEqual(err, expected) {
+		t.Fatalf("Unexpected error (Expected: %s, Got: %s)", expected, err)
+	}
+}
+
 func TestServiceProfilesAccess(t *testing.T) {
 	fakeResources := []string{`
 kind: APIResourceList
@@ -162,6 +162,11 @@ const (
 	// ProxyDisableIdentityAnnotation can be used to disable identity on the injected proxy.
 	ProxyDisableIdentityAnnotation = ProxyConfigAnnotationsPrefix + "/disable-identity"
 
+	// ProxyIdentityProjectedTokenAnnotation can be set to true to provision the
+	// proxy's identity with a projected, audience-scoped service account token
+	// instead of the pod's default service account token.
+	ProxyIdentityProjectedTokenAnnotation = ProxyConfigAnnotationsPrefix + "/identity-projected-token"
+
 	// ProxyDisableTapAnnotation can be used to disable tap on the injected proxy.
 	ProxyDisableTapAnnotation = ProxyConfigAnnotationsPrefix + "/disable-tap"
 
@@ -184,6 +189,10 @@ const (
 	// ConfigConfigMapName is the name of the ConfigMap containing the linkerd controller configuration.
 	ConfigConfigMapName = "linkerd-config"
 
+	// IdentityDenylistConfigMapName is the name of the ConfigMap listing the
+	// identities that the identity controller may not certify.
+	IdentityDenylistConfigMapName = "linkerd-identity-denylist"
+
 	// DebugSidecarName is the name of the default linkerd debug container
 	DebugSidecarName = "linkerd-debug"
 
@@ -196,10 +205,26 @@ const (
 	// ProxyContainerName is the name assigned to the injected proxy container.
 	ProxyContainerName = "linkerd-proxy"
 
+	// CNIPluginNodeCondition is the type of the node condition set by the
+	// linkerd-cni installer, reporting whether the plugin is in the node's CNI
+	// network configuration.
+	CNIPluginNodeCondition = "LinkerdCNIPluginConfigured"
+
+	// CNIPluginPodSelector selects the pods of the linkerd-cni DaemonSet.
+	CNIPluginPodSelector = "k8s-app=linkerd-cni"
+
 	// IdentityEndEntityVolumeName is the name assigned the temporary end-entity
 	// volume mounted into each proxy to store identity credentials.
 	IdentityEndEntityVolumeName = "linkerd-identity-end-entity"
 
+	// IdentityTokenVolumeName is the name assigned the projected service
+	// account token volume mounted into each proxy to provision identity.
+	IdentityTokenVolumeName = "linkerd-identity-token"
+
+	// IdentityTokenAudience is the audience of projected service account tokens
+	// presented to the identity service.
+	IdentityTokenAudience = "identity.l5d.io"
+
 	// IdentityIssuerSecretName is the name of the Secret that stores issuer credentials.
 	IdentityIssuerSecretName = "linkerd-identity-issuer"
 
@@ -209,6 +234,10 @@ const (
 	// IdentityIssuerCrtName is the issuer's certificate file.
 	IdentityIssuerCrtName = "crt.pem"
 
+	// IdentityTrustAnchorsEnvVar is the proxy environment variable containing
+	// the PEM-encoded trust anchors.
+	IdentityTrustAnchorsEnvVar = "LINKERD2_PROXY_IDENTITY_TRUST_ANCHORS"
+
 	// ProxyPortName is the name of the Linkerd Proxy's proxy port.
 	ProxyPortName = "linkerd-proxy"
 
@@ -260,8 +289,17 @@ const (
 	// IdentityServiceAccountTokenPath is the path to the kubernetes service
 	// account token used by proxies to provision identity.
 	//
-	// In the future, this should be changed to a time- and audience-scoped secret.
+	// Proxies that use a projected token read it from MountPathIdentityToken
+	// instead.
 	IdentityServiceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+	// MountPathIdentityToken is the path at which the projected service account
+	// token volume is mounted.
+	MountPathIdentityToken = MountPathBase + "/identity/token"
+
+	// IdentityProjectedTokenPath is the path to the projected, audience-scoped
+	// service account token used by proxies to provision identity.
+	IdentityProjectedTokenPath = MountPathIdentityToken + "/token"
 )
 
 // CreatedByAnnotationValue returns the value associated with
@@ -270,6 +308,12 @@ func CreatedByAnnotationValue() string {
 	return fmt.Sprintf("linkerd/cli %s", version.Version)
 }
 
+// WebhookSecretName returns the name of the secret holding the TLS
+// credentials of a webhook service.
+func WebhookSecretName(webhook string) string {
+	return fmt.Sprintf("%s-tls", webhook)
+}
+
 // GetServiceAccountAndNS returns the pod's serviceaccount and namespace.
 func GetServiceAccountAndNS(pod *corev1.Pod) (sa string, ns string) {
 	sa = pod.Spec.ServiceAccountName
@@ -1,6 +1,7 @@
 package k8s
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -8,6 +9,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -27,6 +29,7 @@ type PortForward struct {
 	remotePort int
 	emitLogs   bool
 	stopCh     chan struct{}
+	stopOnce   sync.Once
 	readyCh    chan struct{}
 	config     *rest.Config
 }
@@ -38,6 +41,26 @@ func NewProxyMetricsForward(
 	k8sAPI *KubernetesAPI,
 	pod corev1.Pod,
 	emitLogs bool,
+) (*PortForward, error) {
+	return newProxyPortForward(k8sAPI, pod, ProxyAdminPortName, emitLogs)
+}
+
+// NewProxyInboundForward returns an instance of the PortForward struct that can
+// be used to establish a port-forward connection to a linkerd-proxy's inbound
+// port, specified by namespace and proxyPod.
+func NewProxyInboundForward(
+	k8sAPI *KubernetesAPI,
+	pod corev1.Pod,
+	emitLogs bool,
+) (*PortForward, error) {
+	return newProxyPortForward(k8sAPI, pod, ProxyPortName, emitLogs)
+}
+
+func newProxyPortForward(
+	k8sAPI *KubernetesAPI,
+	pod corev1.Pod,
+	portName string,
+	emitLogs bool,
 ) (*PortForward, error) {
 	if pod.Status.Phase != corev1.PodRunning {
 		return nil, fmt.Errorf("pod not running: %s", pod.GetName())
@@ -56,13 +79,13 @@ func NewProxyMetricsForward(
 
 	var port corev1.ContainerPort
 	for _, p := range container.Ports {
-		if p.Name == ProxyAdminPortName {
+		if p.Name == portName {
 			port = p
 			break
 		}
 	}
-	if port.Name != ProxyAdminPortName {
-		return nil, fmt.Errorf("no %s port found for container %s/%s", ProxyAdminPortName, pod.GetName(), container.Name)
+	if port.Name != portName {
+		return nil, fmt.Errorf("no %s port found for container %s/%s", portName, pod.GetName(), container.Name)
 	}
 
 	return newPortForward(k8sAPI, pod.GetNamespace(), pod.GetName(), 0, int(port.ContainerPort), emitLogs)
@@ -162,6 +185,28 @@ func (pf *PortForward) Run() error {
 	return fw.ForwardPorts()
 }
 
+// Init runs the port-forward connection in the background and waits until it
+// is ready. It fails if the connection can't be established, or if ctx
+// expires first. The port-forward must be stopped either way.
+func (pf *PortForward) Init(ctx context.Context) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- pf.Run()
+	}()
+
+	select {
+	case <-pf.Ready():
+		return nil
+	case err := <-errCh:
+		if err == nil {
+			err = fmt.Errorf("port-forward to %s stopped before it was ready", pf.url)
+		}
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Ready returns a channel that will receive a message when the port-forward
 // connection is ready. Clients should block and wait for the message before
 // using the port-forward connection.
@@ -169,14 +214,22 @@ func (pf *PortForward) Ready() <-chan struct{} {
 	return pf.readyCh
 }
 
-// Stop terminates the port-forward connection.
+// Stop terminates the port-forward connection. It can be called more than
+// once.
 func (pf *PortForward) Stop() {
-	close(pf.stopCh)
+	pf.stopOnce.Do(func() {
+		close(pf.stopCh)
+	})
 }
 
 // URLFor returns the URL for the port-forward connection.
 func (pf *PortForward) URLFor(path string) string {
-	return fmt.Sprintf("http://127.0.0.1:%d%s", pf.localPort, path)
+	return fmt.Sprintf("http://%s%s", pf.Address(), path)
+}
+
+// Address returns the local host and port of the port-forward connection.
+func (pf *PortForward) Address() string {
+	return fmt.Sprintf("127.0.0.1:%d", pf.localPort)
 }
 
 // getEphemeralPort selects a port for the port-forwarding. It binds to a free
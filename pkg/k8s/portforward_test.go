@@ -98,6 +98,35 @@ spec:
 	}
 }
 
+func TestNewProxyInboundForward(t *testing.T) {
+	k8sClient, err := NewFakeAPI(`apiVersion: v1
+kind: Pod
+metadata:
+  name: pod-name
+  namespace: pod-ns
+status:
+  phase: Running
+spec:
+  containers:
+  - name: linkerd-proxy
+    ports:
+    - name: linkerd-admin
+      port: 4191`)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	pod, err := k8sClient.CoreV1().Pods("pod-ns").Get("pod-name", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+
+	expected := "no linkerd-proxy port found for container pod-name/linkerd-proxy"
+	_, err = NewProxyInboundForward(&KubernetesAPI{Interface: k8sClient}, *pod, false)
+	if err == nil || err.Error() != expected {
+		t.Fatalf("Unexpected error (Expected: %s, Got: %s)", expected, err)
+	}
+}
+
 func TestNewPortForward(t *testing.T) {
 	// TODO: test successful cases by mocking out `clientset.CoreV1().RESTClient()`
 	tests := []struct {
@@ -165,3 +194,16 @@ status:
 		})
 	}
 }
+
+func TestPortForwardStop(t *testing.T) {
+	pf := &PortForward{stopCh: make(chan struct{}, 1)}
+
+	pf.Stop()
+	pf.Stop()
+
+	select {
+	case <-pf.stopCh:
+	default:
+		t.Fatalf("Expected the port-forward to be stopped")
+	}
+}
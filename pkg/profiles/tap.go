@@ -23,18 +23,7 @@ import (
 // a service profile with routes pre-populated from the tap data
 // Only inbound tap traffic is considered.
 func RenderTapOutputProfile(client pb.ApiClient, tapResource, namespace, name string, tapDuration time.Duration, routeLimit int, w io.Writer) error {
-	requestParams := util.TapRequestParams{
-		Resource:  tapResource,
-		Namespace: namespace,
-	}
-	log.Debugf("Running `linkerd tap %s --namespace %s`", tapResource, namespace)
-
-	req, err := util.BuildTapByResourceRequest(requestParams)
-	if err != nil {
-		return err
-	}
-
-	profile, err := tapToServiceProfile(client, req, namespace, name, tapDuration, routeLimit)
+	profile, err := TapServiceProfile(context.Background(), client, tapResource, namespace, name, tapDuration, routeLimit)
 	if err != nil {
 		return err
 	}
@@ -47,7 +36,26 @@ func RenderTapOutputProfile(client pb.ApiClient, tapResource, namespace, name st
 	return nil
 }
 
-func tapToServiceProfile(client pb.ApiClient, tapReq *pb.TapByResourceRequest, namespace, name string, tapDuration time.Duration, routeLimit int) (sp.ServiceProfile, error) {
+// TapServiceProfile performs a tap on the desired resource, for at most
+// tapDuration, and returns a service profile with routes pre-populated from
+// the tap data. The tap is made with ctx, which may carry the identity of the
+// user requesting it.
+func TapServiceProfile(ctx context.Context, client pb.ApiClient, tapResource, namespace, name string, tapDuration time.Duration, routeLimit int) (sp.ServiceProfile, error) {
+	requestParams := util.TapRequestParams{
+		Resource:  tapResource,
+		Namespace: namespace,
+	}
+	log.Debugf("Running `linkerd tap %s --namespace %s`", tapResource, namespace)
+
+	req, err := util.BuildTapByResourceRequest(requestParams)
+	if err != nil {
+		return sp.ServiceProfile{}, err
+	}
+
+	return tapToServiceProfile(ctx, client, req, namespace, name, tapDuration, routeLimit)
+}
+
+func tapToServiceProfile(ctx context.Context, client pb.ApiClient, tapReq *pb.TapByResourceRequest, namespace, name string, tapDuration time.Duration, routeLimit int) (sp.ServiceProfile, error) {
 	profile := sp.ServiceProfile{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      fmt.Sprintf("%s.%s.svc.cluster.local", name, namespace),
@@ -56,7 +64,7 @@ func tapToServiceProfile(client pb.ApiClient, tapReq *pb.TapByResourceRequest, n
 		TypeMeta: serviceProfileMeta,
 	}
 
-	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(tapDuration))
+	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(tapDuration))
 	defer cancel()
 
 	tapClient, err := client.TapByResource(ctx, tapReq)
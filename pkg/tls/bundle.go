@@ -0,0 +1,47 @@
+package tls
+
+import (
+	"crypto/x509"
+	"errors"
+)
+
+// BundleCertificatesPEM combines PEM-encoded certificate bundles into a single
+// PEM-encoded bundle. Certificates are kept in the order in which they appear
+// and duplicates are dropped.
+func BundleCertificatesPEM(bundles ...string) (string, error) {
+	var crts []*x509.Certificate
+	for _, b := range bundles {
+		decoded, err := DecodePEMCertificates(b)
+		if err != nil {
+			return "", err
+		}
+		for _, c := range decoded {
+			if !ContainsCertificates(crts, c) {
+				crts = append(crts, c)
+			}
+		}
+	}
+	if len(crts) == 0 {
+		return "", errors.New("No certificates found")
+	}
+
+	return EncodeCertificatesPEM(crts...), nil
+}
+
+// ContainsCertificates returns true if every certificate in want is also in
+// have.
+func ContainsCertificates(have []*x509.Certificate, want ...*x509.Certificate) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if h.Equal(w) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
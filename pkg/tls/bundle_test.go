@@ -0,0 +1,38 @@
+package tls
+
+import (
+	"testing"
+)
+
+func TestBundleCertificatesPEM(t *testing.T) {
+	old := newRoot(t)
+	oldPEM := old.Cred.Crt.EncodeCertificatePEM()
+	next, err := GenerateRootCAWithDefaults("new")
+	if err != nil {
+		t.Fatalf("failed to create CA: %s", err)
+	}
+	nextPEM := next.Cred.Crt.EncodeCertificatePEM()
+
+	bundle, err := BundleCertificatesPEM(oldPEM, nextPEM, oldPEM)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	crts, err := DecodePEMCertificates(bundle)
+	if err != nil {
+		t.Fatalf("failed to decode bundle: %s", err)
+	}
+	if len(crts) != 2 || !crts[0].Equal(old.Cred.Crt.Certificate) || !crts[1].Equal(next.Cred.Crt.Certificate) {
+		t.Fatalf("expected the old and new certificates in order, got %d certificates", len(crts))
+	}
+
+	if !ContainsCertificates(crts, next.Cred.Crt.Certificate) {
+		t.Fatal("expected the bundle to contain the new certificate")
+	}
+	if ContainsCertificates(crts[:1], old.Cred.Crt.Certificate, next.Cred.Crt.Certificate) {
+		t.Fatal("expected the old certificate alone not to contain the new certificate")
+	}
+
+	if _, err := BundleCertificatesPEM(""); err == nil {
+		t.Fatal("expected an error for an empty bundle")
+	}
+}
@@ -1,9 +1,12 @@
 package tls
 
 import (
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"fmt"
@@ -13,7 +16,10 @@ import (
 
 type (
 	// CA provides a certificate authority for TLS-enabled installs.
-	// Issuing certificates concurrently is not supported.
+	//
+	// A CA is safe for concurrent use: it is not modified after it is created,
+	// and each issued certificate is given a random serial number, so
+	// certificates may be issued in parallel without coordination.
 	CA struct {
 		// Cred contains the CA's credentials.
 		Cred Cred
@@ -25,16 +31,6 @@ type (
 		// assume that the CA's validity period is the same as issued certificates'
 		// validity.
 		Validity Validity
-
-		// nextSerialNumber is the serial number of the next certificate to issue.
-		// Serial numbers must not be reused.
-		//
-		// It is assumed there is only one instance of CA and it is assumed that a
-		// given CA object isn't requested to issue certificates concurrently.
-		//
-		// For now we do not attempt to meet CABForum requirements (e.g. regarding
-		// randomness).
-		nextSerialNumber uint64
 	}
 
 	// Validity configures the expiry times of issued certificates.
@@ -58,6 +54,16 @@ type (
 	Issuer interface {
 		IssueEndEntityCrt(*x509.CertificateRequest) (Crt, error)
 	}
+
+	// LifetimeIssuer implementors can also sign certificate requests with a
+	// shorter lifetime than they otherwise would.
+	LifetimeIssuer interface {
+		Issuer
+
+		// IssueEndEntityCrtWithLifetime signs a certificate request so that the
+		// certificate is valid for no longer than the given lifetime.
+		IssueEndEntityCrtWithLifetime(*x509.CertificateRequest, time.Duration) (Crt, error)
+	}
 )
 
 const (
@@ -77,11 +83,19 @@ const (
 	// verifier; since both are trying to account for clock skew, there is
 	// somewhat of an over-correction.
 	DefaultClockSkewAllowance = 10 * time.Second
+
+	// serialNumberBits is the number of random bits in issued certificates'
+	// serial numbers. This is large enough that serial numbers are not
+	// expected to collide, so they needn't be tracked to avoid reuse.
+	serialNumberBits = 128
 )
 
+// serialNumberLimit is the exclusive upper bound of issued serial numbers.
+var serialNumberLimit = new(big.Int).Lsh(big.NewInt(1), serialNumberBits)
+
 // NewCA initializes a new CA with default settings.
 func NewCA(cred Cred, validity Validity) *CA {
-	return &CA{cred, validity, uint64(1)}
+	return &CA{cred, validity}
 }
 
 func init() {
@@ -89,14 +103,18 @@ func init() {
 	var _ Issuer = &CA{}
 }
 
-// CreateRootCA configures a new root CA with the given settings
+// CreateRootCA configures a new root CA with the given settings. The key may
+// be an RSA, ECDSA or Ed25519 key.
 func CreateRootCA(
 	name string,
-	key *ecdsa.PrivateKey,
+	key crypto.Signer,
 	validity Validity,
 ) (*CA, error) {
 	// Configure the root certificate.
-	t := createTemplate(1, &key.PublicKey, validity)
+	t, err := createTemplate(key.Public(), validity)
+	if err != nil {
+		return nil, err
+	}
 	t.Subject = pkix.Name{CommonName: name}
 	t.IsCA = true
 	t.MaxPathLen = -1
@@ -115,9 +133,7 @@ func CreateRootCA(
 
 	// The Crt has an empty TrustChain because it's at the root.
 	cred := validCredOrPanic(key, Crt{Certificate: c})
-	ca := NewCA(cred, validity)
-	ca.nextSerialNumber++ // Because we've already created the root cert.
-	return ca, nil
+	return NewCA(cred, validity), nil
 }
 
 // GenerateKey creates a new P-256 ECDSA private key from the default random
@@ -144,7 +160,10 @@ func (ca *CA) GenerateCA(name string, validity Validity, maxPathLen int) (*CA, e
 		return nil, err
 	}
 
-	t := ca.createTemplate(&key.PublicKey)
+	t, err := createTemplate(key.Public(), ca.Validity)
+	if err != nil {
+		return nil, err
+	}
 	t.Subject = pkix.Name{CommonName: name}
 	t.IsCA = true
 	t.MaxPathLen = maxPathLen
@@ -183,13 +202,39 @@ func (ca *CA) GenerateEndEntityCred(dnsName string) (*Cred, error) {
 
 // IssueEndEntityCrt creates a new certificate that is valid for the
 // given DNS name, generating a new keypair for it.
+//
+// It may be called concurrently.
 func (ca *CA) IssueEndEntityCrt(csr *x509.CertificateRequest) (Crt, error) {
-	pubkey, ok := csr.PublicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return Crt{}, fmt.Errorf("CSR must contain an ECDSA public key: %+v", csr.PublicKey)
+	return ca.issueEndEntityCrt(csr, ca.Validity)
+}
+
+// IssueEndEntityCrtWithLifetime is like IssueEndEntityCrt, but the certificate
+// is valid for no longer than lifetime.
+//
+// It may be called concurrently.
+func (ca *CA) IssueEndEntityCrtWithLifetime(csr *x509.CertificateRequest, lifetime time.Duration) (Crt, error) {
+	v := ca.Validity
+	current := v.Lifetime
+	if current == 0 {
+		current = DefaultLifetime
+	}
+	if lifetime > 0 && lifetime < current {
+		v.Lifetime = lifetime
+	}
+	return ca.issueEndEntityCrt(csr, v)
+}
+
+func (ca *CA) issueEndEntityCrt(csr *x509.CertificateRequest, v Validity) (Crt, error) {
+	switch csr.PublicKey.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+	default:
+		return Crt{}, fmt.Errorf("CSR must contain an ECDSA, RSA or Ed25519 public key: %+v", csr.PublicKey)
 	}
 
-	t := ca.createTemplate(pubkey)
+	t, err := createTemplate(csr.PublicKey, v)
+	if err != nil {
+		return Crt{}, err
+	}
 	t.Issuer = ca.Cred.Crt.Certificate.Subject
 	t.Subject = csr.Subject
 	t.Extensions = csr.Extensions
@@ -202,49 +247,53 @@ func (ca *CA) IssueEndEntityCrt(csr *x509.CertificateRequest) (Crt, error) {
 	return ca.Cred.SignCrt(t)
 }
 
-// createTemplate returns a certificate t for a non-CA certificate with
-// no subject name, no subjectAltNames. The t can then be modified into
-// a (root) CA t or an end-entity t by the caller.
-func (ca *CA) createTemplate(pubkey *ecdsa.PublicKey) *x509.Certificate {
-	c := createTemplate(ca.nextSerialNumber, pubkey, ca.Validity)
-	ca.nextSerialNumber++
-	return c
-}
-
 // createTemplate returns a certificate t for a non-CA certificate with
 // no subject name, no subjectAltNames. The t can then be modified into
 // a (root) CA t or an end-entity t by the caller.
 func createTemplate(
-	serialNumber uint64,
-	k *ecdsa.PublicKey,
+	k crypto.PublicKey,
 	v Validity,
-) *x509.Certificate {
-	// ECDSA is used instead of RSA because ECDSA key generation is
-	// straightforward and fast whereas RSA key generation is extremely slow
-	// and error-prone.
-	//
-	// CA certificates are signed with the same algorithm as end-entity
-	// certificates because they are relatively short-lived, because using one
-	// algorithm minimizes exposure to implementation flaws, and to speed up
-	// signature verification time.
+) (*x509.Certificate, error) {
+	// The signature algorithm is left unset so that it's chosen to match the
+	// issuer's key: SHA-256 for RSA and P-256 ECDSA keys, and a digest that
+	// matches the curve size for larger ECDSA keys.
 	//
-	// SHA-256 is used because any larger digest would be truncated to 256 bits
-	// anyway since a P-256 scalar is only 256 bits long.
-	const SignatureAlgorithm = x509.ECDSAWithSHA256
+	// Generated keys are ECDSA P-256 keys, since ECDSA key generation is
+	// straightforward and fast whereas RSA key generation is extremely slow
+	// and error-prone. Issuers may nevertheless be configured with RSA or
+	// Ed25519 keys, as some organizations require them.
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
 
 	notBefore, notAfter := v.Window(time.Now())
 
 	return &x509.Certificate{
-		SerialNumber:       big.NewInt(int64(serialNumber)),
-		SignatureAlgorithm: SignatureAlgorithm,
-		NotBefore:          notBefore,
-		NotAfter:           notAfter,
-		PublicKey:          k,
-		KeyUsage:           x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		SerialNumber: serialNumber,
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		PublicKey:    k,
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
 		ExtKeyUsage: []x509.ExtKeyUsage{
 			x509.ExtKeyUsageServerAuth,
 			x509.ExtKeyUsageClientAuth,
 		},
+	}, nil
+}
+
+// newSerialNumber returns a random, positive serial number of up to
+// serialNumberBits bits.
+func newSerialNumber() (*big.Int, error) {
+	for {
+		n, err := rand.Int(rand.Reader, serialNumberLimit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate serial number: %s", err)
+		}
+		// Serial numbers must be positive.
+		if n.Sign() > 0 {
+			return n, nil
+		}
 	}
 }
 
@@ -0,0 +1,157 @@
+package tls
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newCSR(t testing.TB, name string) *x509.CertificateRequest {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	return &x509.CertificateRequest{
+		Subject:   pkix.Name{CommonName: name},
+		DNSNames:  []string{name},
+		PublicKey: &key.PublicKey,
+	}
+}
+
+func TestIssueEndEntityCrtConcurrently(t *testing.T) {
+	root := newRoot(t)
+	issuer, err := root.GenerateCA("issuer", root.Validity, -1)
+	if err != nil {
+		t.Fatalf("failed to create issuer: %s", err)
+	}
+	roots := root.Cred.Crt.CertPool()
+
+	const workers, perWorker = 8, 16
+	csrs := make([][]*x509.CertificateRequest, workers)
+	for w := range csrs {
+		for i := 0; i < perWorker; i++ {
+			csrs[w] = append(csrs[w], newCSR(t, fmt.Sprintf("w%d-%d.test", w, i)))
+		}
+	}
+
+	crts := make(chan Crt, workers*perWorker)
+	errs := make(chan error, workers*perWorker)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for _, csr := range csrs[w] {
+				name := csr.DNSNames[0]
+				crt, err := issuer.IssueEndEntityCrt(csr)
+				if err != nil {
+					errs <- err
+					continue
+				}
+				if err := crt.Verify(roots, name); err != nil {
+					errs <- fmt.Errorf("%s: %s", name, err)
+					continue
+				}
+				crts <- crt
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(crts)
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("failed to issue certificate: %s", err)
+	}
+
+	serials := make(map[string]struct{})
+	for crt := range crts {
+		if len(crt.TrustChain) != 2 {
+			t.Fatalf("expected a trust chain of 2 certificates, got %d", len(crt.TrustChain))
+		}
+		if !crt.TrustChain[1].Equal(issuer.Cred.Crt.Certificate) {
+			t.Fatal("expected the certificate to be issued by the issuer")
+		}
+
+		sn := crt.Certificate.SerialNumber
+		if sn.Sign() <= 0 || sn.BitLen() > serialNumberBits {
+			t.Fatalf("invalid serial number: %s", sn)
+		}
+		if _, ok := serials[sn.String()]; ok {
+			t.Fatalf("serial number reused: %s", sn)
+		}
+		serials[sn.String()] = struct{}{}
+	}
+
+	if len(issuer.Cred.Crt.TrustChain) != 1 {
+		t.Fatalf("issuer's trust chain was modified: %v", issuer.Cred.Crt.TrustChain)
+	}
+}
+
+func TestIssueEndEntityCrtWithLifetime(t *testing.T) {
+	ca, err := GenerateRootCAWithDefaults("root")
+	if err != nil {
+		t.Fatalf("failed to create CA: %s", err)
+	}
+	ca.Validity = Validity{Lifetime: time.Hour, ClockSkewAllowance: time.Second}
+
+	testCases := []struct {
+		lifetime time.Duration
+		expected time.Duration
+	}{
+		{0, time.Hour},
+		{time.Minute, time.Minute},
+		{2 * time.Hour, time.Hour},
+	}
+	for _, tc := range testCases {
+		tc := tc // pin
+		t.Run(tc.lifetime.String(), func(t *testing.T) {
+			crt, err := ca.IssueEndEntityCrtWithLifetime(newCSR(t, "foo"), tc.lifetime)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			// The window includes the clock skew allowance on both sides.
+			lifetime := crt.Certificate.NotAfter.Sub(crt.Certificate.NotBefore) - 2*time.Second
+			if lifetime != tc.expected {
+				t.Fatalf("expected lifetime %s, got %s", tc.expected, lifetime)
+			}
+		})
+	}
+}
+
+func BenchmarkIssueEndEntityCrt(b *testing.B) {
+	root, err := GenerateRootCAWithDefaults(b.Name())
+	if err != nil {
+		b.Fatalf("failed to create CA: %s", err)
+	}
+	csr := newCSR(b, "endentity.test")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := root.IssueEndEntityCrt(csr); err != nil {
+			b.Fatalf("failed to issue certificate: %s", err)
+		}
+	}
+}
+
+func BenchmarkIssueEndEntityCrtParallel(b *testing.B) {
+	root, err := GenerateRootCAWithDefaults(b.Name())
+	if err != nil {
+		b.Fatalf("failed to create CA: %s", err)
+	}
+	csr := newCSR(b, "endentity.test")
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := root.IssueEndEntityCrt(csr); err != nil {
+				b.Errorf("failed to issue certificate: %s", err)
+				return
+			}
+		}
+	})
+}
@@ -2,7 +2,10 @@ package tls
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
@@ -21,21 +24,41 @@ func EncodeCertificatesPEM(crts ...*x509.Certificate) string {
 	return buf.String()
 }
 
-// EncodePrivateKeyPEM encodes the provided key as PEM-encoded text
-func EncodePrivateKeyPEM(k *ecdsa.PrivateKey) ([]byte, error) {
-	der, err := x509.MarshalECPrivateKey(k)
-	if err != nil {
-		return nil, err
+// EncodePrivateKeyPEM encodes the provided key as PEM-encoded text. ECDSA keys
+// are encoded in SEC1 form, RSA keys in PKCS#1 form, and Ed25519 keys, which
+// have neither, in PKCS#8 form.
+func EncodePrivateKeyPEM(k crypto.Signer) ([]byte, error) {
+	var blk *pem.Block
+	switch key := k.(type) {
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		blk = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+
+	case *rsa.PrivateKey:
+		blk = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+
+	case ed25519.PrivateKey:
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		blk = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+
+	default:
+		return nil, fmt.Errorf("Unsupported private key type: %T", k)
 	}
 
-	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+	return pem.EncodeToMemory(blk), nil
 }
 
-// EncodePrivateKeyP8 encodes the provided key as PEM-encoded text
-func EncodePrivateKeyP8(k *ecdsa.PrivateKey) []byte {
+// EncodePrivateKeyP8 encodes the provided key to the PKCS#8 binary form.
+func EncodePrivateKeyP8(k crypto.Signer) []byte {
 	p8, err := x509.MarshalPKCS8PrivateKey(k)
 	if err != nil {
-		panic("ECDSA keys must be encodeable as PKCS8")
+		panic(fmt.Sprintf("Private keys must be encodeable as PKCS8: %s", err))
 	}
 	return p8
 }
@@ -48,16 +71,53 @@ func encode(buf *bytes.Buffer, blk *pem.Block) {
 
 // === DECODE ===
 
-// DecodePEMKey parses a PEM-encoded ECDSA private key from the named path.
-func DecodePEMKey(txt string) (*ecdsa.PrivateKey, error) {
+// DecodePEMKey parses a PEM-encoded private key. RSA keys may be in PKCS#1 or
+// PKCS#8 form, ECDSA keys in SEC1 or PKCS#8 form, and Ed25519 keys in PKCS#8
+// form.
+func DecodePEMKey(txt string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(txt))
+	if block == nil {
+		return nil, errors.New("Not PEM-encoded")
+	}
+
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch k := key.(type) {
+		case *ecdsa.PrivateKey:
+			return k, nil
+		case *rsa.PrivateKey:
+			return k, nil
+		case ed25519.PrivateKey:
+			return k, nil
+		default:
+			return nil, fmt.Errorf("Unsupported private key type: %T", key)
+		}
+
+	default:
+		return nil, fmt.Errorf("Expected 'EC PRIVATE KEY', 'RSA PRIVATE KEY' or 'PRIVATE KEY'; found: '%s'", block.Type)
+	}
+}
+
+// DecodePEMPublicKey parses a PEM-encoded PKIX public key.
+func DecodePEMPublicKey(txt string) (crypto.PublicKey, error) {
 	block, _ := pem.Decode([]byte(txt))
 	if block == nil {
 		return nil, errors.New("Not PEM-encoded")
 	}
-	if block.Type != "EC PRIVATE KEY" {
-		return nil, fmt.Errorf("Expected 'EC PRIVATE KEY'; found: '%s'", block.Type)
+	if block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("Expected 'PUBLIC KEY'; found: '%s'", block.Type)
 	}
-	return x509.ParseECPrivateKey(block.Bytes)
+	return x509.ParsePKIXPublicKey(block.Bytes)
 }
 
 // DecodePEMCertificates parses a string containing PEM-encoded certificates.
@@ -2,7 +2,7 @@ package tls
 
 import (
 	"bytes"
-	"crypto/ecdsa"
+	"crypto"
 	"crypto/rand"
 	"crypto/x509"
 	"encoding/pem"
@@ -13,8 +13,10 @@ import (
 
 type (
 	// Cred is a container for a certificate, trust chain, and private key.
+	//
+	// The private key may be an RSA, ECDSA or Ed25519 key.
 	Cred struct {
-		PrivateKey *ecdsa.PrivateKey
+		PrivateKey crypto.Signer
 		Crt
 	}
 
@@ -29,7 +31,7 @@ type (
 )
 
 // validCredOrPanic creates a  Cred, panicking if the key does not match the certificate.
-func validCredOrPanic(k *ecdsa.PrivateKey, crt Crt) Cred {
+func validCredOrPanic(k crypto.Signer, crt Crt) Cred {
 	if !certificateMatchesKey(crt.Certificate, k) {
 		panic("Cert's public key does not match private key")
 	}
@@ -89,12 +91,12 @@ func (crt *Crt) EncodeCertificatePEM() string {
 
 // EncodePrivateKeyPEM emits the private key as PEM-encoded text.
 func (cred *Cred) EncodePrivateKeyPEM() string {
-	b, err := x509.MarshalECPrivateKey(cred.PrivateKey)
+	b, err := EncodePrivateKeyPEM(cred.PrivateKey)
 	if err != nil {
-		panic(fmt.Sprintf("Invalid elliptic curve: %s", err))
+		panic(fmt.Sprintf("Invalid private key: %s", err))
 	}
 
-	return string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b}))
+	return string(b)
 }
 
 // EncodePrivateKeyP8 encodes the provided key to the PKCS#8 binary form.
@@ -103,9 +105,16 @@ func (cred *Cred) EncodePrivateKeyP8() ([]byte, error) {
 }
 
 // certificateMatchesKey returns whether the key and certificate match.
-func certificateMatchesKey(c *x509.Certificate, k *ecdsa.PrivateKey) bool {
-	pub, ok := c.PublicKey.(*ecdsa.PublicKey)
-	return ok && pub.X.Cmp(k.X) == 0 && pub.Y.Cmp(k.Y) == 0
+func certificateMatchesKey(c *x509.Certificate, k crypto.Signer) bool {
+	crtPub, err := x509.MarshalPKIXPublicKey(c.PublicKey)
+	if err != nil {
+		return false
+	}
+	keyPub, err := x509.MarshalPKIXPublicKey(k.Public())
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(crtPub, keyPub)
 }
 
 // SignCrt uses this Cred to sign a new certificate.
@@ -128,9 +137,14 @@ func (cred *Cred) SignCrt(template *x509.Certificate) (Crt, error) {
 		return Crt{}, err
 	}
 
+	// Copy the trust chain so that certificates signed concurrently don't
+	// share (and race on) the issuer's backing array.
+	chain := make([]*x509.Certificate, len(cred.Crt.TrustChain), len(cred.Crt.TrustChain)+1)
+	copy(chain, cred.Crt.TrustChain)
+
 	crt := Crt{
 		Certificate: c,
-		TrustChain:  append(cred.Crt.TrustChain, cred.Crt.Certificate),
+		TrustChain:  append(chain, cred.Crt.Certificate),
 	}
 	return crt, nil
 }
@@ -141,16 +155,21 @@ func ReadPEMCreds(keyPath, crtPath string) (*Cred, error) {
 	if err != nil {
 		return nil, err
 	}
-	key, err := DecodePEMKey(string(keyb))
+	crtb, err := ioutil.ReadFile(crtPath)
 	if err != nil {
 		return nil, err
 	}
+	return DecodePEMCreds(string(keyb), string(crtb))
+}
 
-	crtb, err := ioutil.ReadFile(crtPath)
+// DecodePEMCreds decodes PEM-encoded credentials from a private key and a
+// series of certificates from leaf to root.
+func DecodePEMCreds(keyPEM, crtPEM string) (*Cred, error) {
+	key, err := DecodePEMKey(keyPEM)
 	if err != nil {
 		return nil, err
 	}
-	crt, err := DecodePEMCrt(string(crtb))
+	crt, err := DecodePEMCrt(crtPEM)
 	if err != nil {
 		return nil, err
 	}
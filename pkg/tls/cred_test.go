@@ -1,9 +1,35 @@
 package tls
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"reflect"
 	"testing"
 )
 
+// testKeys returns a key of each supported type.
+func testKeys(t *testing.T) map[string]crypto.Signer {
+	ec, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %s", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %s", err)
+	}
+	_, ed, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %s", err)
+	}
+	return map[string]crypto.Signer{"ecdsa": ec, "rsa": rsaKey, "ed25519": ed}
+}
+
 func newRoot(t *testing.T) CA {
 	root, err := GenerateRootCAWithDefaults(t.Name())
 	if err != nil {
@@ -13,22 +39,94 @@ func newRoot(t *testing.T) CA {
 }
 
 func TestCrtRoundtrip(t *testing.T) {
-	root := newRoot(t)
-	rootTrust := root.Cred.Crt.CertPool()
-
-	cred, err := root.GenerateEndEntityCred("endentity.test")
-	if err != nil {
-		t.Fatalf("failed to create end entity cred: %s", err)
+	roots := map[string]CA{"default": newRoot(t)}
+	for name, key := range testKeys(t) {
+		root, err := CreateRootCA(name, key, Validity{})
+		if err != nil {
+			t.Fatalf("failed to create %s CA: %s", name, err)
+		}
+		roots[name] = *root
 	}
 
-	crt, err := DecodePEMCrt(cred.Crt.EncodePEM())
-	if err != nil {
-		t.Fatalf("Failed to decode PEM Crt: %s", err)
+	for name, root := range roots {
+		root := root // pin
+		t.Run(name, func(t *testing.T) {
+			rootTrust := root.Cred.Crt.CertPool()
+
+			cred, err := root.GenerateEndEntityCred("endentity.test")
+			if err != nil {
+				t.Fatalf("failed to create end entity cred: %s", err)
+			}
+
+			crt, err := DecodePEMCrt(cred.Crt.EncodePEM())
+			if err != nil {
+				t.Fatalf("Failed to decode PEM Crt: %s", err)
+			}
+
+			if err := crt.Verify(rootTrust, "endentity.test"); err != nil {
+				t.Fatalf("Failed to verify round-tripped certificate: %s", err)
+			}
+
+			decoded, err := DecodePEMCreds(root.Cred.EncodePrivateKeyPEM(), root.Cred.EncodeCertificatePEM())
+			if err != nil {
+				t.Fatalf("Failed to decode PEM creds: %s", err)
+			}
+			if !reflect.DeepEqual(decoded.PrivateKey, root.Cred.PrivateKey) {
+				t.Fatal("Round-tripped private key does not match")
+			}
+		})
 	}
+}
+
+func TestDecodePEMKey(t *testing.T) {
+	for name, key := range testKeys(t) {
+		key := key // pin
+		p8, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatalf("failed to marshal %s key: %s", name, err)
+		}
+		blocks := map[string]*pem.Block{
+			"pkcs8": {Type: "PRIVATE KEY", Bytes: p8},
+		}
+		switch k := key.(type) {
+		case *ecdsa.PrivateKey:
+			sec1, err := x509.MarshalECPrivateKey(k)
+			if err != nil {
+				t.Fatalf("failed to marshal %s key: %s", name, err)
+			}
+			blocks["sec1"] = &pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1}
+		case *rsa.PrivateKey:
+			blocks["pkcs1"] = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}
+		}
 
-	if err := crt.Verify(rootTrust, "endentity.test"); err != nil {
-		t.Fatal("Failed to verify round-tripped certificate")
+		for format, blk := range blocks {
+			blk := blk // pin
+			t.Run(name+"/"+format, func(t *testing.T) {
+				decoded, err := DecodePEMKey(string(pem.EncodeToMemory(blk)))
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if !reflect.DeepEqual(decoded, key) {
+					t.Fatal("decoded key does not match")
+				}
+			})
+		}
 	}
+
+	t.Run("rejects mismatched keys", func(t *testing.T) {
+		root := newRoot(t)
+		other := newRoot(t)
+		if _, err := DecodePEMCreds(other.Cred.EncodePrivateKeyPEM(), root.Cred.EncodeCertificatePEM()); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("rejects unknown block types", func(t *testing.T) {
+		blk := &pem.Block{Type: "DSA PRIVATE KEY", Bytes: []byte("key")}
+		if _, err := DecodePEMKey(string(pem.EncodeToMemory(blk))); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
 }
 
 func TestCredEncodeCertificateAndTrustChain(t *testing.T) {
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"path"
 	"strings"
 
 	httpPb "github.com/linkerd/linkerd2-proxy-api/go/http_types"
@@ -41,3 +42,14 @@ func ParseMethod(method string) *httpPb.HttpMethod {
 		},
 	}
 }
+
+// NormalizeBasePath returns the base path of the dashboard with a leading
+// slash and no trailing slash, e.g. "/linkerd", or an empty string to serve
+// the dashboard at the root.
+func NormalizeBasePath(basePath string) string {
+	basePath = strings.Trim(basePath, "/")
+	if basePath == "" {
+		return ""
+	}
+	return path.Clean("/" + basePath)
+}
@@ -0,0 +1,19 @@
+package util
+
+import "testing"
+
+func TestNormalizeBasePath(t *testing.T) {
+	expectations := map[string]string{
+		"":           "",
+		"/":          "",
+		"linkerd":    "/linkerd",
+		"/linkerd":   "/linkerd",
+		"/linkerd/":  "/linkerd",
+		"/a/b/../c/": "/a/c",
+	}
+	for basePath, expected := range expectations {
+		if actual := NormalizeBasePath(basePath); actual != expected {
+			t.Errorf("Expected base path %q to be normalized to %q, got %q", basePath, expected, actual)
+		}
+	}
+}
@@ -31,6 +31,10 @@ let proxyPathMatch = window.location.pathname.match(/\/api\/v1\/namespaces\/.*\/
 if (proxyPathMatch) {
   pathPrefix = proxyPathMatch[0];
 }
+// the dashboard may be served under a base path, e.g. behind an ingress
+if (appData.basePath) {
+  pathPrefix += appData.basePath;
+}
 
 const context = {
   ...appData,
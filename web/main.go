@@ -2,14 +2,18 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"flag"
+	"io/ioutil"
 	"net"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/linkerd/linkerd2/controller/api/public"
+	spclient "github.com/linkerd/linkerd2/controller/gen/client/clientset/versioned"
 	"github.com/linkerd/linkerd2/pkg/admin"
 	"github.com/linkerd/linkerd2/pkg/config"
 	"github.com/linkerd/linkerd2/pkg/flags"
@@ -27,6 +31,23 @@ func main() {
 	staticDir := flag.String("static-dir", "app/dist", "directory to search for static files")
 	reload := flag.Bool("reload", true, "reloading set to true or false")
 	controllerNamespace := flag.String("controller-namespace", "linkerd", "namespace in which Linkerd is installed")
+	basePath := flag.String("base-path", "", "path prefix under which the dashboard is served, e.g. \"/linkerd\" behind an ingress; empty to serve it at the root")
+	kubeConfigPath := flag.String("kubeconfig", "", "path to kube config")
+	enableProfileWrites := flag.Bool("enable-profile-writes", false, "allow ServiceProfiles to be created and updated when users aren't authenticated, with the dashboard's own credentials")
+	namespaces := flag.String("namespaces", "", "comma-separated namespaces the dashboard shows; empty to show all namespaces. Grafana isn't proxied if set, since it shows all namespaces")
+	authMode := flag.String("auth-mode", "", "how users are authenticated: \"proxy\" to trust an authenticating proxy's headers, \"oidc\" to log in with an OpenID Connect provider, or empty to not authenticate users. Grafana isn't proxied if set, since it doesn't apply the users' permissions")
+	proxyUserHeader := flag.String("auth-proxy-user-header", "X-Remote-User", "header set by the authenticating proxy to the name of the user")
+	proxyGroupsHeader := flag.String("auth-proxy-groups-header", "X-Remote-Group", "header set by the authenticating proxy to the groups of the user")
+	proxyTrustedCIDRs := flag.String("auth-proxy-trusted-cidrs", "", "comma-separated networks of the authenticating proxies whose headers are trusted; required by the proxy auth mode")
+	oidcIssuerURL := flag.String("oidc-issuer-url", "", "URL of the OpenID Connect provider")
+	oidcClientID := flag.String("oidc-client-id", "", "client ID of the dashboard at the OpenID Connect provider")
+	oidcClientSecretFile := flag.String("oidc-client-secret-file", "", "path to the client secret of the dashboard at the OpenID Connect provider")
+	oidcRedirectURL := flag.String("oidc-redirect-url", "", "URL of the dashboard's /auth/callback route, as reached by users")
+	oidcUsernameClaim := flag.String("oidc-username-claim", "sub", "ID token claim used as the name of the user, as configured in the Kubernetes API server")
+	oidcGroupsClaim := flag.String("oidc-groups-claim", "groups", "ID token claim used as the groups of the user, as configured in the Kubernetes API server")
+	oidcScopes := flag.String("oidc-scopes", "email,profile,groups", "comma-separated scopes requested in addition to openid")
+	sessionKeyFile := flag.String("session-key-file", "", "path to the key signing the session cookies of logged in users; empty to generate one, which logs users out when the dashboard restarts")
+	sessionLifetime := flag.Duration("session-lifetime", 12*time.Hour, "time after which logged in users must log in again")
 	flags.ConfigureAndParse()
 
 	_, _, err := net.SplitHostPort(*apiAddr) // Verify apiAddr is of the form host:port.
@@ -38,16 +59,58 @@ func main() {
 		log.Fatalf("failed to construct client for API server URL %s", *apiAddr)
 	}
 
+	k8sAPI, err := pkgK8s.NewAPI(*kubeConfigPath, "", 0)
+	if err != nil {
+		log.Fatalf("failed to initialize K8s API: %s", err)
+	}
+	spClient, err := spclient.NewForConfig(k8sAPI.Config)
+	if err != nil {
+		log.Fatalf("failed to initialize ServiceProfile client: %s", err)
+	}
+
 	installConfig, err := config.Install(pkgK8s.MountPathInstallConfig)
 	if err != nil {
 		log.Warnf("failed to load uuid from install config: [%s] (disregard warning if running in development mode)", err)
 	}
 	uuid := installConfig.GetUuid()
 
+	var auth srv.Authenticator
+	switch *authMode {
+	case "":
+	case "proxy":
+		trustedNetworks, err := srv.ParseNetworks(*proxyTrustedCIDRs)
+		if err != nil {
+			log.Fatalf("failed to parse trusted networks: %s", err)
+		}
+		if len(trustedNetworks) == 0 {
+			log.Fatal("the proxy auth mode requires -auth-proxy-trusted-cidrs, the networks of the authenticating proxies")
+		}
+		auth = &srv.ProxyAuthenticator{
+			UserHeader:      *proxyUserHeader,
+			GroupsHeader:    *proxyGroupsHeader,
+			TrustedNetworks: trustedNetworks,
+		}
+	case "oidc":
+		auth, err = newOIDCAuthenticator(srv.OIDCConfig{
+			IssuerURL:       *oidcIssuerURL,
+			ClientID:        *oidcClientID,
+			RedirectURL:     *oidcRedirectURL,
+			UsernameClaim:   *oidcUsernameClaim,
+			GroupsClaim:     *oidcGroupsClaim,
+			Scopes:          splitList(*oidcScopes),
+			SessionLifetime: *sessionLifetime,
+		}, *oidcClientSecretFile, *sessionKeyFile)
+		if err != nil {
+			log.Fatalf("failed to configure OIDC authentication: %s", err)
+		}
+	default:
+		log.Fatalf("unsupported auth mode: %s", *authMode)
+	}
+
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
-	server := srv.NewServer(*addr, *grafanaAddr, *templateDir, *staticDir, uuid, *controllerNamespace, *reload, client)
+	server := srv.NewServer(*addr, *grafanaAddr, *templateDir, *staticDir, uuid, *controllerNamespace, *reload, client, auth, splitList(*namespaces), k8sAPI, spClient, *enableProfileWrites, *basePath)
 
 	go func() {
 		log.Infof("starting HTTP server on %+v", *addr)
@@ -63,3 +126,38 @@ func main() {
 	defer cancel()
 	server.Shutdown(ctx)
 }
+
+func newOIDCAuthenticator(config srv.OIDCConfig, clientSecretFile, sessionKeyFile string) (srv.Authenticator, error) {
+	if clientSecretFile != "" {
+		secret, err := ioutil.ReadFile(clientSecretFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientSecret = strings.TrimSpace(string(secret))
+	}
+
+	if sessionKeyFile != "" {
+		key, err := ioutil.ReadFile(sessionKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.SessionKey = key
+	} else {
+		config.SessionKey = make([]byte, 32)
+		if _, err := rand.Read(config.SessionKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return srv.NewOIDCAuthenticator(config)
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -71,10 +71,16 @@ func (h *handler) handleAPIVersion(w http.ResponseWriter, req *http.Request, p h
 }
 
 func (h *handler) handleAPIPods(w http.ResponseWriter, req *http.Request, p httprouter.Params) {
+	namespace := req.FormValue("namespace")
+	if err := h.namespaces.check(namespace); err != nil {
+		renderJSONError(w, err, http.StatusForbidden)
+		return
+	}
+
 	pods, err := h.apiClient.ListPods(req.Context(), &pb.ListPodsRequest{
 		Selector: &pb.ResourceSelection{
 			Resource: &pb.Resource{
-				Namespace: req.FormValue("namespace"),
+				Namespace: namespace,
 			},
 		},
 	})
@@ -84,12 +90,19 @@ func (h *handler) handleAPIPods(w http.ResponseWriter, req *http.Request, p http
 		return
 	}
 
+	h.namespaces.filterPods(pods)
 	renderJSONPb(w, pods)
 }
 
 func (h *handler) handleAPIServices(w http.ResponseWriter, req *http.Request, p httprouter.Params) {
+	namespace := req.FormValue("namespace")
+	if err := h.namespaces.check(namespace); err != nil {
+		renderJSONError(w, err, http.StatusForbidden)
+		return
+	}
+
 	services, err := h.apiClient.ListServices(req.Context(), &pb.ListServicesRequest{
-		Namespace: req.FormValue("namespace"),
+		Namespace: namespace,
 	})
 
 	if err != nil {
@@ -97,6 +110,7 @@ func (h *handler) handleAPIServices(w http.ResponseWriter, req *http.Request, p
 		return
 	}
 
+	h.namespaces.filterServices(services)
 	renderJSONPb(w, services)
 }
 
@@ -131,12 +145,18 @@ func (h *handler) handleAPIStat(w http.ResponseWriter, req *http.Request, p http
 		renderJSONError(w, err, http.StatusInternalServerError)
 		return
 	}
+	err = h.namespaces.checkResources(statRequest.GetSelector().GetResource(), statRequest.GetToResource(), statRequest.GetFromResource())
+	if err != nil {
+		renderJSONError(w, err, http.StatusForbidden)
+		return
+	}
 
 	result, err := h.apiClient.StatSummary(req.Context(), statRequest)
 	if err != nil {
 		renderJSONError(w, err, http.StatusInternalServerError)
 		return
 	}
+	h.namespaces.filterStats(result)
 	renderJSONPb(w, result)
 }
 
@@ -158,6 +178,11 @@ func (h *handler) handleAPITopRoutes(w http.ResponseWriter, req *http.Request, p
 		renderJSONError(w, err, http.StatusBadRequest)
 		return
 	}
+	err = h.namespaces.checkResources(topReq.GetSelector().GetResource(), topReq.GetToResource())
+	if err != nil {
+		renderJSONError(w, err, http.StatusForbidden)
+		return
+	}
 
 	result, err := h.apiClient.TopRoutes(req.Context(), topReq)
 	if err != nil {
@@ -205,6 +230,14 @@ func (h *handler) handleAPITap(w http.ResponseWriter, req *http.Request, p httpr
 		websocketError(ws, websocket.CloseInternalServerErr, err.Error())
 		return
 	}
+	err = h.namespaces.checkResources(tapReq.GetTarget().GetResource())
+	if err == nil {
+		err = h.namespaces.check(requestParams.ToNamespace)
+	}
+	if err != nil {
+		websocketError(ws, websocket.ClosePolicyViolation, err.Error())
+		return
+	}
 
 	go func() {
 		tapClient, err := h.apiClient.TapByResource(req.Context(), tapReq)
@@ -263,11 +296,16 @@ func (h *handler) handleAPIEdges(w http.ResponseWriter, req *http.Request, p htt
 		renderJSONError(w, err, http.StatusInternalServerError)
 		return
 	}
+	if err := h.namespaces.checkResources(edgesRequest.GetSelector().GetResource()); err != nil {
+		renderJSONError(w, err, http.StatusForbidden)
+		return
+	}
 
 	result, err := h.apiClient.Edges(req.Context(), edgesRequest)
 	if err != nil {
 		renderJSONError(w, err, http.StatusInternalServerError)
 		return
 	}
+	h.namespaces.filterEdges(result)
 	renderJSONPb(w, result)
 }
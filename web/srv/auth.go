@@ -0,0 +1,209 @@
+package srv
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/linkerd/linkerd2/controller/api/public"
+	log "github.com/sirupsen/logrus"
+)
+
+// Authenticator identifies the users of the dashboard. The identity of the
+// user is forwarded to the Public API, so that Kubernetes RBAC decides what
+// each user can see and tap.
+type Authenticator interface {
+	// authenticate returns the user making the request, or false if the
+	// request isn't authenticated.
+	authenticate(req *http.Request) (public.User, bool)
+
+	// unauthenticated responds to a request that isn't authenticated.
+	unauthenticated(w http.ResponseWriter, req *http.Request)
+
+	// register registers the routes used to log in and out, if any, under
+	// the base path of the dashboard.
+	register(router *httprouter.Router, basePath string)
+}
+
+// ProxyAuthenticator trusts the identity set in request headers by an
+// authenticating proxy in front of the dashboard.
+type ProxyAuthenticator struct {
+	// UserHeader is the header carrying the name of the user
+	UserHeader string
+
+	// GroupsHeader, if set, is the header carrying the groups of the user,
+	// either as separate headers or comma-separated
+	GroupsHeader string
+
+	// TrustedNetworks are the networks of the proxies whose headers are
+	// trusted; no client is trusted if it's empty, since anyone reaching the
+	// dashboard could otherwise claim any identity
+	TrustedNetworks []*net.IPNet
+
+	basePath string
+}
+
+func (a *ProxyAuthenticator) authenticate(req *http.Request) (public.User, bool) {
+	if !a.trusted(req) {
+		return public.User{}, false
+	}
+
+	name := req.Header.Get(a.UserHeader)
+	if name == "" {
+		return public.User{}, false
+	}
+
+	user := public.User{Name: name}
+	if a.GroupsHeader != "" {
+		for _, value := range req.Header[http.CanonicalHeaderKey(a.GroupsHeader)] {
+			for _, group := range strings.Split(value, ",") {
+				if group = strings.TrimSpace(group); group != "" {
+					user.Groups = append(user.Groups, group)
+				}
+			}
+		}
+	}
+	return user, true
+}
+
+func (a *ProxyAuthenticator) trusted(req *http.Request) bool {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	for _, network := range a.TrustedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	log.Warnf("ignoring the identity headers of untrusted client %s", req.RemoteAddr)
+	return false
+}
+
+func (a *ProxyAuthenticator) unauthenticated(w http.ResponseWriter, req *http.Request) {
+	renderUnauthenticated(w, req, a.basePath)
+}
+
+func (a *ProxyAuthenticator) register(_ *httprouter.Router, basePath string) {
+	a.basePath = basePath
+}
+
+func renderUnauthenticated(w http.ResponseWriter, req *http.Request, basePath string) {
+	err := errors.New("authentication required")
+	if isAPIPath(basePath, req.URL.Path) {
+		renderJSONError(w, err, http.StatusUnauthorized)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusUnauthorized)
+}
+
+func isAPIPath(basePath, path string) bool {
+	return strings.HasPrefix(path, basePath+"/api/")
+}
+
+// isPublicPath returns true for the paths served without authentication:
+// static assets, and the routes used to log in and out.
+func isPublicPath(basePath, path string) bool {
+	return strings.HasPrefix(path, basePath+"/dist/") || strings.HasPrefix(path, basePath+authPathPrefix)
+}
+
+const authPathPrefix = "/auth/"
+
+// ParseNetworks parses a comma-separated list of CIDRs.
+func ParseNetworks(cidrs string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, cidr := range strings.Split(cidrs, ",") {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// sessionCodec encodes the identity of logged in users into signed cookies.
+type sessionCodec struct {
+	key      []byte
+	lifetime time.Duration
+}
+
+type session struct {
+	User    string   `json:"user"`
+	Groups  []string `json:"groups,omitempty"`
+	Expires int64    `json:"exp"`
+}
+
+// encode returns a cookie value carrying the user, valid for the lifetime of
+// the session.
+func (c *sessionCodec) encode(user public.User) (string, error) {
+	payload, err := json.Marshal(session{
+		User:    user.Name,
+		Groups:  user.Groups,
+		Expires: time.Now().Add(c.lifetime).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return c.sign(payload), nil
+}
+
+// decode returns the user carried by a cookie value, if its signature is
+// valid and it hasn't expired.
+func (c *sessionCodec) decode(value string) (public.User, error) {
+	payload, err := c.verify(value)
+	if err != nil {
+		return public.User{}, err
+	}
+
+	var s session
+	if err := json.Unmarshal(payload, &s); err != nil {
+		return public.User{}, err
+	}
+	if time.Now().Unix() > s.Expires {
+		return public.User{}, errors.New("session expired")
+	}
+	return public.User{Name: s.User, Groups: s.Groups}, nil
+}
+
+func (c *sessionCodec) sign(payload []byte) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(payload)
+	return fmt.Sprintf("%s.%s",
+		base64.RawURLEncoding.EncodeToString(payload),
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+func (c *sessionCodec) verify(value string) ([]byte, error) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 2 {
+		return nil, errors.New("malformed cookie")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, errors.New("invalid cookie signature")
+	}
+	return payload, nil
+}
@@ -0,0 +1,266 @@
+package srv
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/linkerd/linkerd2/controller/api/public"
+)
+
+func TestProxyAuthenticator(t *testing.T) {
+	trustedNetworks, err := ParseNetworks("10.0.0.0/8, 192.168.1.0/24")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	auth := &ProxyAuthenticator{
+		UserHeader:      "X-Remote-User",
+		GroupsHeader:    "X-Remote-Group",
+		TrustedNetworks: trustedNetworks,
+	}
+
+	t.Run("Returns the user set by a trusted proxy", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.1.2.3:34567"
+		req.Header.Set("X-Remote-User", "alice")
+		req.Header.Add("X-Remote-Group", "devs, ops")
+		req.Header.Add("X-Remote-Group", "admins")
+
+		user, ok := auth.authenticate(req)
+		if !ok {
+			t.Fatal("Expected request to be authenticated")
+		}
+		expected := public.User{Name: "alice", Groups: []string{"devs", "ops", "admins"}}
+		if !reflect.DeepEqual(user, expected) {
+			t.Fatalf("Expected user %+v, got %+v", expected, user)
+		}
+	})
+
+	t.Run("Ignores the headers of untrusted clients", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "172.16.0.1:34567"
+		req.Header.Set("X-Remote-User", "alice")
+
+		if user, ok := auth.authenticate(req); ok {
+			t.Fatalf("Expected request not to be authenticated, got %+v", user)
+		}
+	})
+
+	t.Run("Trusts no client if no networks are configured", func(t *testing.T) {
+		auth := &ProxyAuthenticator{UserHeader: "X-Remote-User"}
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.1.2.3:34567"
+		req.Header.Set("X-Remote-User", "alice")
+
+		if user, ok := auth.authenticate(req); ok {
+			t.Fatalf("Expected request not to be authenticated, got %+v", user)
+		}
+	})
+}
+
+func TestServerAuthentication(t *testing.T) {
+	// httptest requests come from 192.0.2.1
+	trustedNetworks, err := ParseNetworks("192.0.2.0/24")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	server := &Server{
+		router: &httprouter.Router{},
+		auth:   &ProxyAuthenticator{UserHeader: "X-Remote-User", TrustedNetworks: trustedNetworks},
+	}
+	var user public.User
+	server.router.GET("/api/version", func(w http.ResponseWriter, req *http.Request, p httprouter.Params) {
+		user, _ = public.UserFromContext(req.Context())
+	})
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/version", nil))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status %d, got %d", http.StatusUnauthorized, recorder.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/api/version", nil)
+	req.Header.Set("X-Remote-User", "alice")
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	if user.Name != "alice" {
+		t.Fatalf("Expected the user to be forwarded, got %+v", user)
+	}
+}
+
+func TestSessionCodec(t *testing.T) {
+	codec := &sessionCodec{key: []byte("secret"), lifetime: time.Hour}
+	user := public.User{Name: "alice", Groups: []string{"devs"}}
+
+	value, err := codec.encode(user)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	decoded, err := codec.decode(value)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(decoded, user) {
+		t.Fatalf("Expected user %+v, got %+v", user, decoded)
+	}
+
+	other := &sessionCodec{key: []byte("other"), lifetime: time.Hour}
+	if _, err := other.decode(value); err == nil {
+		t.Fatal("Expected a cookie signed with another key to be rejected")
+	}
+
+	expired := &sessionCodec{key: []byte("secret"), lifetime: -time.Minute}
+	value, err = expired.encode(user)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, err := codec.decode(value); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Fatalf("Expected an expired session error, got %v", err)
+	}
+}
+
+func TestOIDCUserFromClaims(t *testing.T) {
+	auth := newOIDCAuthenticator(OIDCConfig{
+		IssuerURL:     "https://accounts.example.com",
+		ClientID:      "linkerd-web",
+		UsernameClaim: "email",
+		GroupsClaim:   "groups",
+		SessionKey:    []byte("secret"),
+	}, providerMetadata{})
+	exp := float64(time.Now().Add(time.Hour).Unix())
+
+	user, err := auth.userFromClaims(map[string]interface{}{
+		"iss":    "https://accounts.example.com",
+		"aud":    []interface{}{"other", "linkerd-web"},
+		"exp":    exp,
+		"email":  "alice@example.com",
+		"groups": []interface{}{"devs"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected := public.User{Name: "alice@example.com", Groups: []string{"devs"}}
+	if !reflect.DeepEqual(user, expected) {
+		t.Fatalf("Expected user %+v, got %+v", expected, user)
+	}
+
+	_, err = auth.userFromClaims(map[string]interface{}{
+		"iss":   "https://accounts.example.com",
+		"aud":   "other",
+		"exp":   exp,
+		"email": "alice@example.com",
+	})
+	if err == nil {
+		t.Fatal("Expected an ID token issued for another client to be rejected")
+	}
+}
+
+func TestOIDCAuthenticatorBasePath(t *testing.T) {
+	auth := newOIDCAuthenticator(OIDCConfig{
+		IssuerURL:   "https://accounts.example.com",
+		ClientID:    "linkerd-web",
+		RedirectURL: "https://example.com/linkerd/auth/callback",
+		SessionKey:  []byte("secret"),
+	}, providerMetadata{AuthorizationEndpoint: "https://accounts.example.com/authorize"})
+	router := &httprouter.Router{}
+	auth.register(router, "/linkerd")
+
+	t.Run("Redirects pages to the provider", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		auth.unauthenticated(recorder, httptest.NewRequest("GET", "/linkerd/overview", nil))
+		if recorder.Code != http.StatusFound {
+			t.Fatalf("Expected status %d, got %d", http.StatusFound, recorder.Code)
+		}
+		if location := recorder.Header().Get("Location"); !strings.HasPrefix(location, "https://accounts.example.com/authorize?") {
+			t.Fatalf("Expected a redirect to the provider, got %s", location)
+		}
+		cookies := recorder.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != stateCookie || cookies[0].Path != "/linkerd/auth/" {
+			t.Fatalf("Expected a state cookie under the base path, got %v", cookies)
+		}
+		if cookies[0].SameSite != http.SameSiteLaxMode {
+			t.Fatalf("Expected the state cookie to be SameSite=Lax, got %v", cookies[0])
+		}
+
+		recorder = httptest.NewRecorder()
+		auth.unauthenticated(recorder, httptest.NewRequest("GET", "/linkerd/api/pods", nil))
+		if recorder.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected status %d, got %d", http.StatusUnauthorized, recorder.Code)
+		}
+	})
+
+	t.Run("Redirects to the base path on logout", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", "/linkerd/auth/logout", nil))
+		if recorder.Code != http.StatusFound {
+			t.Fatalf("Expected status %d, got %d", http.StatusFound, recorder.Code)
+		}
+		if location := recorder.Header().Get("Location"); location != "/linkerd/" {
+			t.Fatalf("Expected a redirect to /linkerd/, got %s", location)
+		}
+		cookies := recorder.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != sessionCookie || cookies[0].Path != "/linkerd/" {
+			t.Fatalf("Expected the session cookie under the base path to be cleared, got %v", cookies)
+		}
+	})
+
+	t.Run("Only redirects within the base path after logging in", func(t *testing.T) {
+		root := *auth
+		root.basePath = ""
+
+		for _, tc := range []struct {
+			auth     *OIDCAuthenticator
+			redirect string
+			expected string
+		}{
+			{auth, "/linkerd/namespaces/emojivoto", "/linkerd/namespaces/emojivoto"},
+			{auth, "/grafana/", "/linkerd/"},
+			{auth, "//evil.example.com/", "/linkerd/"},
+			{auth, "/linkerd/\\evil.example.com/", "/linkerd/"},
+			{&root, "/namespaces/emojivoto", "/namespaces/emojivoto"},
+			{&root, "//evil.example.com/", "/"},
+			{&root, "/\\evil.example.com/", "/"},
+			{&root, "/\t/evil.example.com/", "/"},
+		} {
+			state := tc.auth.session.sign([]byte(fmt.Sprintf("nonce|%d|%s", time.Now().Add(time.Minute).Unix(), tc.redirect)))
+			req := httptest.NewRequest("GET", "/linkerd/auth/callback?state=nonce", nil)
+			req.AddCookie(&http.Cookie{Name: stateCookie, Value: state})
+
+			actual, err := tc.auth.verifyState(req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if actual != tc.expected {
+				t.Fatalf("Expected %q to redirect to %s, got %s", tc.redirect, tc.expected, actual)
+			}
+		}
+	})
+}
+
+func TestNewCookie(t *testing.T) {
+	cookie := newCookie(httptest.NewRequest("GET", "/auth/callback", nil), sessionCookie, "value", "/", 3600)
+	expected := &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "value",
+		Path:     "/",
+		MaxAge:   3600,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if !reflect.DeepEqual(cookie, expected) {
+		t.Fatalf("Expected cookie %+v, got %+v", expected, cookie)
+	}
+
+	req := httptest.NewRequest("GET", "https://example.com/auth/callback", nil)
+	if cookie := newCookie(req, sessionCookie, "value", "/", 3600); !cookie.Secure {
+		t.Fatalf("Expected cookies set over TLS to be secure, got %+v", cookie)
+	}
+}
@@ -8,17 +8,22 @@ import (
 
 // grafanaProxy is an HTTP reverse proxy that forwards all web requests
 // containing paths prefixed with "/grafana" to the grafana service. The proxy
-// strips the "/grafana" prefix and rewrites the Host header before sending.
+// strips the "/grafana" prefix, under the dashboard's base path, and rewrites
+// the Host header before sending. Grafana's root_url, set by the install's
+// --base-path flag, must include the base path for the links it generates to
+// go through the proxy.
 type grafanaProxy struct {
 	*httputil.ReverseProxy
 }
 
-func newGrafanaProxy(addr string) *grafanaProxy {
+func newGrafanaProxy(addr, basePath string) *grafanaProxy {
+	prefix := basePath + "/grafana"
+
 	director := func(req *http.Request) {
 		req.Host = addr
 		req.URL.Host = addr
 		req.URL.Scheme = "http"
-		req.URL.Path = strings.TrimPrefix(req.URL.Path, "/grafana")
+		req.URL.Path = strings.TrimPrefix(req.URL.Path, prefix)
 
 		// the default director implementation does this, so we will too
 		if _, ok := req.Header["User-Agent"]; !ok {
@@ -5,12 +5,15 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/linkerd/linkerd2/controller/api/public"
+	spclient "github.com/linkerd/linkerd2/controller/gen/client/clientset/versioned"
 	pb "github.com/linkerd/linkerd2/controller/gen/public"
 	profiles "github.com/linkerd/linkerd2/pkg/profiles"
 	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
 )
 
 var proxyPathRegexp = regexp.MustCompile("/api/v1/namespaces/.*/proxy/")
@@ -24,6 +27,11 @@ type (
 		uuid                string
 		controllerNamespace string
 		grafanaProxy        *grafanaProxy
+		namespaces          namespaceSet
+		k8sClient           kubernetes.Interface
+		spClient            spclient.Interface
+		profileWrites       bool
+		basePath            string
 	}
 )
 
@@ -33,11 +41,15 @@ func (h *handler) handleIndex(w http.ResponseWriter, req *http.Request, p httpro
 	if pathPfx == "" {
 		pathPfx = "/"
 	}
+	if h.basePath != "" {
+		pathPfx += strings.TrimPrefix(h.basePath, "/") + "/"
+	}
 
 	params := appParams{
 		UUID:                h.uuid,
 		ControllerNamespace: h.controllerNamespace,
 		PathPrefix:          pathPfx,
+		BasePath:            h.basePath,
 	}
 
 	version, err := h.apiClient.Version(req.Context(), &pb.Empty{}) // TODO: remove and call /api/version from web app
@@ -56,6 +68,12 @@ func (h *handler) handleIndex(w http.ResponseWriter, req *http.Request, p httpro
 	}
 }
 
+// handleBasePathRedirect redirects requests for the root path to the dashboard,
+// when it is served under a base path.
+func (h *handler) handleBasePathRedirect(w http.ResponseWriter, req *http.Request, p httprouter.Params) {
+	http.Redirect(w, req, h.basePath+"/", http.StatusFound)
+}
+
 func (h *handler) handleProfileDownload(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
 	service := req.FormValue("service")
 	namespace := req.FormValue("namespace")
@@ -66,6 +84,10 @@ func (h *handler) handleProfileDownload(w http.ResponseWriter, req *http.Request
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if err := h.namespaces.check(namespace); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 
 	profileYaml := &bytes.Buffer{}
 	err := profiles.RenderProfileTemplate(namespace, service, profileYaml)
@@ -85,5 +107,9 @@ func (h *handler) handleProfileDownload(w http.ResponseWriter, req *http.Request
 }
 
 func (h *handler) handleGrafana(w http.ResponseWriter, req *http.Request, p httprouter.Params) {
+	if h.grafanaProxy == nil {
+		http.Error(w, "Grafana is not available when the dashboard authenticates users or is restricted to some namespaces", http.StatusForbidden)
+		return
+	}
 	h.grafanaProxy.ServeHTTP(w, req)
 }
@@ -0,0 +1,134 @@
+package srv
+
+import (
+	"fmt"
+	"strings"
+
+	pb "github.com/linkerd/linkerd2/controller/gen/public"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+)
+
+// namespaceSet is the set of namespaces the dashboard can show. A nil set
+// allows every namespace.
+type namespaceSet map[string]struct{}
+
+func newNamespaceSet(namespaces []string) namespaceSet {
+	if len(namespaces) == 0 {
+		return nil
+	}
+
+	set := make(namespaceSet)
+	for _, ns := range namespaces {
+		set[ns] = struct{}{}
+	}
+	return set
+}
+
+func (s namespaceSet) allows(namespace string) bool {
+	if s == nil {
+		return true
+	}
+	_, ok := s[namespace]
+	return ok
+}
+
+// check returns an error if a namespace targeted by a request isn't allowed.
+// Empty namespaces, which select all namespaces, are allowed, and their
+// results are filtered instead.
+func (s namespaceSet) check(namespaces ...string) error {
+	for _, ns := range namespaces {
+		if ns != "" && !s.allows(ns) {
+			return fmt.Errorf("namespace %s is not visible in this dashboard", ns)
+		}
+	}
+	return nil
+}
+
+// checkResources returns an error if the namespace of a resource targeted by
+// a request isn't allowed.
+func (s namespaceSet) checkResources(resources ...*pb.Resource) error {
+	for _, resource := range resources {
+		if err := s.check(resourceNamespace(resource)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s namespaceSet) filterPods(rsp *pb.ListPodsResponse) {
+	if s == nil {
+		return
+	}
+
+	pods := rsp.Pods[:0]
+	for _, pod := range rsp.Pods {
+		// pod names are namespaced, e.g. "emojivoto/web-58b8bd7f7-n7cp8"
+		if s.allows(strings.SplitN(pod.GetName(), "/", 2)[0]) {
+			pods = append(pods, pod)
+		}
+	}
+	rsp.Pods = pods
+}
+
+func (s namespaceSet) filterServices(rsp *pb.ListServicesResponse) {
+	if s == nil {
+		return
+	}
+
+	services := rsp.Services[:0]
+	for _, svc := range rsp.Services {
+		if s.allows(svc.GetNamespace()) {
+			services = append(services, svc)
+		}
+	}
+	rsp.Services = services
+}
+
+func (s namespaceSet) filterStats(rsp *pb.StatSummaryResponse) {
+	if s == nil {
+		return
+	}
+
+	for _, table := range rsp.GetOk().GetStatTables() {
+		podGroup := table.GetPodGroup()
+		if podGroup == nil {
+			continue
+		}
+
+		rows := podGroup.Rows[:0]
+		for _, row := range podGroup.Rows {
+			if s.allows(resourceNamespace(row.GetResource())) {
+				rows = append(rows, row)
+			}
+		}
+		podGroup.Rows = rows
+	}
+}
+
+func (s namespaceSet) filterEdges(rsp *pb.EdgesResponse) {
+	if s == nil {
+		return
+	}
+
+	ok := rsp.GetOk()
+	if ok == nil {
+		return
+	}
+
+	edges := ok.Edges[:0]
+	for _, edge := range ok.Edges {
+		if s.allows(resourceNamespace(edge.GetSrc())) && s.allows(resourceNamespace(edge.GetDst())) {
+			edges = append(edges, edge)
+		}
+	}
+	ok.Edges = edges
+}
+
+// resourceNamespace returns the namespace of a resource, which is the
+// resource itself for namespaces.
+func resourceNamespace(resource *pb.Resource) string {
+	if resource.GetType() == k8s.Namespace {
+		return resource.GetName()
+	}
+	return resource.GetNamespace()
+}
@@ -0,0 +1,78 @@
+package srv
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/linkerd/linkerd2/controller/api/public"
+	pb "github.com/linkerd/linkerd2/controller/gen/public"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+)
+
+func TestNamespaceSet(t *testing.T) {
+	namespaces := newNamespaceSet([]string{"emojivoto", "books"})
+
+	t.Run("Rejects requests for other namespaces", func(t *testing.T) {
+		if err := namespaces.check("", "books"); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		err := namespaces.checkResources(&pb.Resource{Type: k8s.Namespace, Name: "kube-system"})
+		if err == nil {
+			t.Fatal("Expected error, got nothing")
+		}
+	})
+
+	t.Run("Filters results in other namespaces", func(t *testing.T) {
+		rsp := &pb.ListPodsResponse{
+			Pods: []*pb.Pod{
+				{Name: "emojivoto/web-58b8bd7f7-n7cp8"},
+				{Name: "kube-system/kube-dns-5b8bf6c4f4-fk2rw"},
+			},
+		}
+		namespaces.filterPods(rsp)
+		if len(rsp.Pods) != 1 || rsp.Pods[0].Name != "emojivoto/web-58b8bd7f7-n7cp8" {
+			t.Fatalf("Unexpected pods: %v", rsp.Pods)
+		}
+	})
+
+	t.Run("Allows every namespace when empty", func(t *testing.T) {
+		var all namespaceSet
+		if err := all.check("kube-system"); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	})
+}
+
+func TestHandleApiPodsNamespaces(t *testing.T) {
+	handler := &handler{
+		apiClient: &public.MockAPIClient{
+			ListPodsResponseToReturn: &pb.ListPodsResponse{
+				Pods: []*pb.Pod{
+					{Name: "emojivoto/web-58b8bd7f7-n7cp8"},
+					{Name: "kube-system/kube-dns-5b8bf6c4f4-fk2rw"},
+				},
+			},
+		},
+		namespaces: newNamespaceSet([]string{"emojivoto"}),
+	}
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/pods?namespace=kube-system", nil)
+	handler.handleAPIPods(recorder, req, httprouter.Params{})
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("Expected status %d, got %d", http.StatusForbidden, recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/api/pods", nil)
+	handler.handleAPIPods(recorder, req, httprouter.Params{})
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	if body := recorder.Body.String(); strings.Contains(body, "kube-system") {
+		t.Fatalf("Expected pods in other namespaces to be filtered, got %s", body)
+	}
+}
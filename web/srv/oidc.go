@@ -0,0 +1,344 @@
+package srv
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/linkerd/linkerd2/controller/api/public"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+const (
+	sessionCookie = "linkerd-session"
+	stateCookie   = "linkerd-oidc-state"
+
+	// stateLifetime bounds the time a user has to log in with the provider
+	stateLifetime = 10 * time.Minute
+)
+
+// OIDCConfig configures the login of dashboard users with an OpenID Connect
+// provider.
+type OIDCConfig struct {
+	// IssuerURL is the URL of the provider, used to discover its endpoints
+	IssuerURL string
+
+	ClientID     string
+	ClientSecret string
+
+	// RedirectURL is the URL of the dashboard's /auth/callback route, under
+	// its base path, as reached by the user's browser
+	RedirectURL string
+
+	// UsernameClaim and GroupsClaim are the ID token claims identifying the
+	// user, which must match the Kubernetes API server's OIDC configuration
+	// for RBAC to apply to the user
+	UsernameClaim string
+	GroupsClaim   string
+
+	// Scopes are requested in addition to the openid scope, for the provider
+	// to include the username and groups claims
+	Scopes []string
+
+	// SessionKey signs the cookies of logged in users
+	SessionKey []byte
+
+	// SessionLifetime is the time after which users must log in again
+	SessionLifetime time.Duration
+}
+
+// OIDCAuthenticator logs users in with an OpenID Connect provider, through the
+// authorization code flow, and keeps their identity in a signed cookie.
+type OIDCAuthenticator struct {
+	config  OIDCConfig
+	oauth2  oauth2.Config
+	session *sessionCodec
+
+	// basePath is the base path of the dashboard, under which the session
+	// and state cookies are set
+	basePath string
+}
+
+type providerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+}
+
+// NewOIDCAuthenticator discovers the endpoints of the provider and returns an
+// authenticator logging users in with it.
+func NewOIDCAuthenticator(config OIDCConfig) (*OIDCAuthenticator, error) {
+	if config.ClientID == "" || config.RedirectURL == "" {
+		return nil, errors.New("OIDC client ID and redirect URL must be provided")
+	}
+	if len(config.SessionKey) == 0 {
+		return nil, errors.New("a session key must be provided")
+	}
+
+	wellKnown := strings.TrimSuffix(config.IssuerURL, "/") + "/.well-known/openid-configuration"
+	rsp, err := http.Get(wellKnown)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC provider: %s", err)
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to discover OIDC provider: %s returned %s", wellKnown, rsp.Status)
+	}
+
+	var metadata providerMetadata
+	if err := json.NewDecoder(rsp.Body).Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse OIDC provider metadata: %s", err)
+	}
+	if metadata.Issuer != config.IssuerURL {
+		return nil, fmt.Errorf("OIDC provider issuer %s doesn't match %s", metadata.Issuer, config.IssuerURL)
+	}
+
+	return newOIDCAuthenticator(config, metadata), nil
+}
+
+func newOIDCAuthenticator(config OIDCConfig, metadata providerMetadata) *OIDCAuthenticator {
+	return &OIDCAuthenticator{
+		config: config,
+		oauth2: oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  metadata.AuthorizationEndpoint,
+				TokenURL: metadata.TokenEndpoint,
+			},
+			Scopes: append([]string{"openid"}, config.Scopes...),
+		},
+		session: &sessionCodec{key: config.SessionKey, lifetime: config.SessionLifetime},
+	}
+}
+
+func (a *OIDCAuthenticator) authenticate(req *http.Request) (public.User, bool) {
+	cookie, err := req.Cookie(sessionCookie)
+	if err != nil {
+		return public.User{}, false
+	}
+
+	user, err := a.session.decode(cookie.Value)
+	if err != nil {
+		log.Debugf("ignoring session cookie: %s", err)
+		return public.User{}, false
+	}
+	return user, true
+}
+
+// unauthenticated redirects pages to the provider, to log in and come back to
+// the page. API requests can't follow the redirect, and fail instead.
+func (a *OIDCAuthenticator) unauthenticated(w http.ResponseWriter, req *http.Request) {
+	if isAPIPath(a.basePath, req.URL.Path) || req.Method != http.MethodGet {
+		renderUnauthenticated(w, req, a.basePath)
+		return
+	}
+
+	nonce, err := randomString()
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// the state cookie binds the login to this browser, and remembers the
+	// page to go back to
+	state := a.session.sign([]byte(fmt.Sprintf("%s|%d|%s", nonce, time.Now().Add(stateLifetime).Unix(), req.URL.RequestURI())))
+	http.SetCookie(w, newCookie(req, stateCookie, state, a.basePath+authPathPrefix, int(stateLifetime.Seconds())))
+	http.Redirect(w, req, a.oauth2.AuthCodeURL(nonce), http.StatusFound)
+}
+
+func (a *OIDCAuthenticator) register(router *httprouter.Router, basePath string) {
+	a.basePath = basePath
+	router.GET(basePath+authPathPrefix+"callback", a.handleCallback)
+	router.GET(basePath+authPathPrefix+"logout", a.handleLogout)
+}
+
+func (a *OIDCAuthenticator) handleCallback(w http.ResponseWriter, req *http.Request, p httprouter.Params) {
+	if errMsg := req.FormValue("error"); errMsg != "" {
+		http.Error(w, fmt.Sprintf("login failed: %s %s", errMsg, req.FormValue("error_description")), http.StatusUnauthorized)
+		return
+	}
+
+	redirect, err := a.verifyState(req)
+	if err != nil {
+		log.Errorf("invalid login state: %s", err)
+		http.Error(w, "invalid login state, please try again", http.StatusBadRequest)
+		return
+	}
+
+	user, err := a.exchange(req.Context(), req.FormValue("code"))
+	if err != nil {
+		log.Errorf("login failed: %s", err)
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+
+	value, err := a.session.encode(user)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Infof("user %s logged in", user.Name)
+	http.SetCookie(w, newCookie(req, stateCookie, "", a.basePath+authPathPrefix, -1))
+	http.SetCookie(w, newCookie(req, sessionCookie, value, a.basePath+"/", int(a.config.SessionLifetime.Seconds())))
+	http.Redirect(w, req, redirect, http.StatusFound)
+}
+
+func (a *OIDCAuthenticator) handleLogout(w http.ResponseWriter, req *http.Request, p httprouter.Params) {
+	http.SetCookie(w, newCookie(req, sessionCookie, "", a.basePath+"/", -1))
+	http.Redirect(w, req, a.basePath+"/", http.StatusFound)
+}
+
+// newCookie returns a cookie set by the authenticator, which is cleared if
+// maxAge is negative. Browsers don't send SameSite=Lax cookies along with the
+// requests other sites make to the dashboard, e.g. by posting forms, except
+// when navigating to it, which is how the provider redirects back after a
+// login.
+func newCookie(req *http.Request, name, value, path string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   req.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// verifyState checks that the state returned by the provider is the one set
+// in the state cookie, and returns the page to go back to.
+func (a *OIDCAuthenticator) verifyState(req *http.Request) (string, error) {
+	cookie, err := req.Cookie(stateCookie)
+	if err != nil {
+		return "", err
+	}
+	payload, err := a.session.verify(cookie.Value)
+	if err != nil {
+		return "", err
+	}
+
+	parts := strings.SplitN(string(payload), "|", 3)
+	if len(parts) != 3 {
+		return "", errors.New("malformed state")
+	}
+	if parts[0] != req.FormValue("state") {
+		return "", errors.New("state doesn't match")
+	}
+	var expires int64
+	if _, err := fmt.Sscan(parts[1], &expires); err != nil || time.Now().Unix() > expires {
+		return "", errors.New("state expired")
+	}
+
+	// only redirect within the dashboard. Browsers treat backslashes like
+	// slashes and drop tabs and newlines, so that e.g. "/\evil.com" is a
+	// protocol-relative URL too.
+	redirect := parts[2]
+	if !strings.HasPrefix(redirect, a.basePath+"/") || strings.HasPrefix(redirect, "//") ||
+		strings.ContainsAny(redirect, "\\\t\r\n") {
+		redirect = a.basePath + "/"
+	}
+	return redirect, nil
+}
+
+// exchange exchanges the authorization code for an ID token, and returns the
+// user it identifies.
+func (a *OIDCAuthenticator) exchange(ctx context.Context, code string) (public.User, error) {
+	token, err := a.oauth2.Exchange(ctx, code)
+	if err != nil {
+		return public.User{}, err
+	}
+	idToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return public.User{}, errors.New("token response has no ID token")
+	}
+
+	// The ID token was received directly from the token endpoint, over TLS,
+	// so its issuer is authenticated without checking its signature, as
+	// allowed by section 3.1.3.7 of OpenID Connect Core.
+	claims, err := parseIDToken(idToken)
+	if err != nil {
+		return public.User{}, err
+	}
+	return a.userFromClaims(claims)
+}
+
+func (a *OIDCAuthenticator) userFromClaims(claims map[string]interface{}) (public.User, error) {
+	if claims["iss"] != a.config.IssuerURL {
+		return public.User{}, fmt.Errorf("unexpected ID token issuer %v", claims["iss"])
+	}
+	if !audienceContains(claims["aud"], a.config.ClientID) {
+		return public.User{}, fmt.Errorf("ID token isn't issued for %s", a.config.ClientID)
+	}
+	if exp, ok := claims["exp"].(float64); !ok || time.Now().Unix() > int64(exp) {
+		return public.User{}, errors.New("ID token expired")
+	}
+
+	name, ok := claims[a.config.UsernameClaim].(string)
+	if !ok || name == "" {
+		return public.User{}, fmt.Errorf("ID token has no %s claim", a.config.UsernameClaim)
+	}
+
+	user := public.User{Name: name}
+	if groups, ok := claims[a.config.GroupsClaim].([]interface{}); ok {
+		for _, group := range groups {
+			if g, ok := group.(string); ok {
+				user.Groups = append(user.Groups, g)
+			}
+		}
+	}
+	return user, nil
+}
+
+func parseIDToken(idToken string) (map[string]interface{}, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed ID token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("malformed ID token: %s", err)
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("malformed ID token: %s", err)
+	}
+	return claims, nil
+}
+
+// audienceContains returns true if the aud claim, which is either a string
+// or an array of strings, contains the client ID.
+func audienceContains(aud interface{}, clientID string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		for _, a := range aud {
+			if a == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
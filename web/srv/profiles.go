@@ -0,0 +1,293 @@
+package srv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/linkerd/linkerd2/controller/api/public"
+	sp "github.com/linkerd/linkerd2/controller/gen/apis/serviceprofile/v1alpha1"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	"github.com/linkerd/linkerd2/pkg/profiles"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// maxProfileSize bounds the size of the ServiceProfiles sent to the API
+	maxProfileSize = 1 << 20
+
+	// defaultProfileTapDuration is also the maximum duration of the taps
+	// generating profiles, whose response must be written before the server's
+	// write timeout
+	defaultProfileTapDuration = 5 * time.Second
+	defaultProfileRouteLimit  = 20
+)
+
+var (
+	// profileMediaTypes are the media types of the ServiceProfiles sent to
+	// the API
+	profileMediaTypes = []string{"application/json", "application/yaml", "application/x-yaml"}
+
+	errProfileWritesDisabled = errors.New("ServiceProfiles can only be written by authenticated users, unless the dashboard runs with -enable-profile-writes")
+)
+
+type (
+	profileGenerateRequest struct {
+		Namespace   string `json:"namespace"`
+		Service     string `json:"service"`
+		Tap         string `json:"tap"`
+		TapDuration string `json:"tapDuration"`
+		RouteLimit  int    `json:"routeLimit"`
+	}
+
+	profileListResponse struct {
+		Profiles []sp.ServiceProfile `json:"profiles"`
+	}
+
+	profileValidateResponse struct {
+		Valid bool `json:"valid"`
+	}
+)
+
+func (h *handler) handleAPIProfiles(w http.ResponseWriter, req *http.Request, p httprouter.Params) {
+	namespace := req.FormValue("namespace")
+	if err := h.namespaces.check(namespace); err != nil {
+		renderJSONError(w, err, http.StatusForbidden)
+		return
+	}
+	if err := h.authorizeProfiles(req, "list", namespace, ""); err != nil {
+		renderJSONError(w, err, http.StatusForbidden)
+		return
+	}
+
+	list, err := h.spClient.LinkerdV1alpha1().ServiceProfiles(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		renderK8sError(w, err)
+		return
+	}
+
+	rsp := profileListResponse{Profiles: []sp.ServiceProfile{}}
+	for _, profile := range list.Items {
+		if h.namespaces.allows(profile.Namespace) {
+			rsp.Profiles = append(rsp.Profiles, profile)
+		}
+	}
+	renderJSON(w, rsp)
+}
+
+func (h *handler) handleAPIProfile(w http.ResponseWriter, req *http.Request, p httprouter.Params) {
+	namespace, name := p.ByName("namespace"), p.ByName("name")
+	if err := h.namespaces.check(namespace); err != nil {
+		renderJSONError(w, err, http.StatusForbidden)
+		return
+	}
+	if err := h.authorizeProfiles(req, "get", namespace, name); err != nil {
+		renderJSONError(w, err, http.StatusForbidden)
+		return
+	}
+
+	profile, err := h.spClient.LinkerdV1alpha1().ServiceProfiles(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		renderK8sError(w, err)
+		return
+	}
+	renderJSON(w, profile)
+}
+
+func (h *handler) handleAPIProfileValidate(w http.ResponseWriter, req *http.Request, p httprouter.Params) {
+	if err := checkContentType(req, profileMediaTypes...); err != nil {
+		renderJSONError(w, err, http.StatusUnsupportedMediaType)
+		return
+	}
+	if _, err := readProfile(w, req); err != nil {
+		renderJSONError(w, err, http.StatusBadRequest)
+		return
+	}
+	renderJSON(w, profileValidateResponse{Valid: true})
+}
+
+func (h *handler) handleAPIProfileCreate(w http.ResponseWriter, req *http.Request, p httprouter.Params) {
+	if err := checkContentType(req, profileMediaTypes...); err != nil {
+		renderJSONError(w, err, http.StatusUnsupportedMediaType)
+		return
+	}
+	profile, err := readProfile(w, req)
+	if err != nil {
+		renderJSONError(w, err, http.StatusBadRequest)
+		return
+	}
+	if profile.Namespace == "" {
+		renderJSONError(w, errors.New("ServiceProfile namespace must be provided"), http.StatusBadRequest)
+		return
+	}
+	if err := h.namespaces.check(profile.Namespace); err != nil {
+		renderJSONError(w, err, http.StatusForbidden)
+		return
+	}
+	if err := h.authorizeProfiles(req, "create", profile.Namespace, ""); err != nil {
+		renderJSONError(w, err, http.StatusForbidden)
+		return
+	}
+
+	created, err := h.spClient.LinkerdV1alpha1().ServiceProfiles(profile.Namespace).Create(profile)
+	if err != nil {
+		renderK8sError(w, err)
+		return
+	}
+	renderJSON(w, created)
+}
+
+// handleAPIProfileUpdate replaces a ServiceProfile. Clients should send the
+// resourceVersion they read, for the update to fail if the profile was changed
+// in the meantime.
+func (h *handler) handleAPIProfileUpdate(w http.ResponseWriter, req *http.Request, p httprouter.Params) {
+	namespace, name := p.ByName("namespace"), p.ByName("name")
+	if err := checkContentType(req, profileMediaTypes...); err != nil {
+		renderJSONError(w, err, http.StatusUnsupportedMediaType)
+		return
+	}
+	profile, err := readProfile(w, req)
+	if err != nil {
+		renderJSONError(w, err, http.StatusBadRequest)
+		return
+	}
+	if (profile.Namespace != "" && profile.Namespace != namespace) || profile.Name != name {
+		err := fmt.Errorf("ServiceProfile must be named %s in namespace %s", name, namespace)
+		renderJSONError(w, err, http.StatusBadRequest)
+		return
+	}
+	profile.Namespace = namespace
+
+	if err := h.namespaces.check(namespace); err != nil {
+		renderJSONError(w, err, http.StatusForbidden)
+		return
+	}
+	if err := h.authorizeProfiles(req, "update", namespace, name); err != nil {
+		renderJSONError(w, err, http.StatusForbidden)
+		return
+	}
+
+	updated, err := h.spClient.LinkerdV1alpha1().ServiceProfiles(namespace).Update(profile)
+	if err != nil {
+		renderK8sError(w, err)
+		return
+	}
+	renderJSON(w, updated)
+}
+
+// handleAPIProfileGenerate returns a ServiceProfile with the routes seen by
+// tapping a resource. The tap is authorized by the Public API, which the
+// user's identity is forwarded to.
+func (h *handler) handleAPIProfileGenerate(w http.ResponseWriter, req *http.Request, p httprouter.Params) {
+	if err := checkContentType(req, "application/json"); err != nil {
+		renderJSONError(w, err, http.StatusUnsupportedMediaType)
+		return
+	}
+	var params profileGenerateRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxProfileSize)).Decode(&params); err != nil {
+		renderJSONError(w, err, http.StatusBadRequest)
+		return
+	}
+	if params.Namespace == "" || params.Service == "" || params.Tap == "" {
+		renderJSONError(w, errors.New("namespace, service and tap must be provided to generate a profile"), http.StatusBadRequest)
+		return
+	}
+	if err := h.namespaces.check(params.Namespace); err != nil {
+		renderJSONError(w, err, http.StatusForbidden)
+		return
+	}
+
+	tapDuration := defaultProfileTapDuration
+	if params.TapDuration != "" {
+		d, err := time.ParseDuration(params.TapDuration)
+		if err != nil {
+			renderJSONError(w, err, http.StatusBadRequest)
+			return
+		}
+		if d > defaultProfileTapDuration {
+			renderJSONError(w, fmt.Errorf("tap duration must be at most %s", defaultProfileTapDuration), http.StatusBadRequest)
+			return
+		}
+		tapDuration = d
+	}
+	routeLimit := defaultProfileRouteLimit
+	if params.RouteLimit > 0 {
+		routeLimit = params.RouteLimit
+	}
+
+	profile, err := profiles.TapServiceProfile(req.Context(), h.apiClient, params.Tap, params.Namespace, params.Service, tapDuration, routeLimit)
+	if err != nil {
+		renderJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	renderJSON(w, profile)
+}
+
+// authorizeProfiles checks that the user on whose behalf the request is made
+// is allowed to access ServiceProfiles, since the dashboard accesses them with
+// its own credentials. Without an authenticated user, ServiceProfiles can be
+// read, but only written if profileWrites is set.
+func (h *handler) authorizeProfiles(req *http.Request, verb, namespace, name string) error {
+	user, ok := public.UserFromContext(req.Context())
+	if !ok {
+		if (verb == "create" || verb == "update") && !h.profileWrites {
+			return errProfileWritesDisabled
+		}
+		return nil
+	}
+	return k8s.UserResourceAuthz(
+		h.k8sClient,
+		user.Name, user.Groups,
+		namespace, verb, "linkerd.io", "", "serviceprofiles", name,
+	)
+}
+
+// readProfile reads and validates the ServiceProfile in the body of a
+// request, either in YAML or JSON.
+func readProfile(w http.ResponseWriter, req *http.Request) (*sp.ServiceProfile, error) {
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, maxProfileSize))
+	if err != nil {
+		return nil, err
+	}
+	if err := profiles.Validate(data); err != nil {
+		return nil, err
+	}
+
+	var profile sp.ServiceProfile
+	if err := yaml.Unmarshal(data, &profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// checkContentType fails unless the body of a request is of one of
+// mediaTypes. Browsers can't send these across sites without a CORS preflight,
+// which the dashboard doesn't answer, so this keeps other sites from making
+// requests on behalf of the dashboard's users.
+func checkContentType(req *http.Request, mediaTypes ...string) error {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err == nil {
+		for _, t := range mediaTypes {
+			if mediaType == t {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("Content-Type must be one of %s", strings.Join(mediaTypes, ", "))
+}
+
+func renderK8sError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if statusErr, ok := err.(kerrors.APIStatus); ok && statusErr.Status().Code != 0 {
+		status = int(statusErr.Status().Code)
+	}
+	renderJSONError(w, err, status)
+}
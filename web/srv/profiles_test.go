@@ -0,0 +1,170 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/linkerd/linkerd2/controller/api/public"
+	spfake "github.com/linkerd/linkerd2/controller/gen/client/clientset/versioned/fake"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	"github.com/linkerd/linkerd2/pkg/profiles"
+	"sigs.k8s.io/yaml"
+)
+
+func TestHandleApiProfiles(t *testing.T) {
+	k8sClient, err := k8s.NewFakeAPI()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	handler := &handler{
+		k8sClient:     k8sClient,
+		spClient:      spfake.NewSimpleClientset(),
+		profileWrites: true,
+	}
+	yamlRequest := func(method, target string, body []byte) *http.Request {
+		req := httptest.NewRequest(method, target, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/yaml")
+		return req
+	}
+
+	profile := profiles.GenServiceProfile("authors", "booksns")
+	profileYaml, err := yaml.Marshal(profile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	params := httprouter.Params{
+		{Key: "namespace", Value: "booksns"},
+		{Key: "name", Value: profile.Name},
+	}
+
+	t.Run("Validates profiles", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		req := yamlRequest("POST", "/api/profiles/validate", profileYaml)
+		handler.handleAPIProfileValidate(recorder, req, httprouter.Params{})
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+		}
+
+		recorder = httptest.NewRecorder()
+		req = yamlRequest("POST", "/api/profiles/validate", []byte("metadata:\n  name: authors\nspec:\n  routes: []\n"))
+		handler.handleAPIProfileValidate(recorder, req, httprouter.Params{})
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, recorder.Code)
+		}
+	})
+
+	t.Run("Creates, lists and updates profiles", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		req := yamlRequest("POST", "/api/profiles", profileYaml)
+		handler.handleAPIProfileCreate(recorder, req, httprouter.Params{})
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+		}
+
+		recorder = httptest.NewRecorder()
+		req = httptest.NewRequest("GET", "/api/profiles?namespace=booksns", nil)
+		handler.handleAPIProfiles(recorder, req, httprouter.Params{})
+		var list profileListResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(list.Profiles) != 1 || list.Profiles[0].Name != profile.Name {
+			t.Fatalf("Unexpected profiles: %+v", list.Profiles)
+		}
+
+		updated := profile
+		updated.Spec.Routes[0].Name = "POST /authors/{id}"
+		updatedYaml, err := yaml.Marshal(updated)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		recorder = httptest.NewRecorder()
+		req = yamlRequest("PUT", "/api/profiles/booksns/"+profile.Name, updatedYaml)
+		handler.handleAPIProfileUpdate(recorder, req, params)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+		}
+
+		recorder = httptest.NewRecorder()
+		req = httptest.NewRequest("GET", "/api/profiles/booksns/"+profile.Name, nil)
+		handler.handleAPIProfile(recorder, req, params)
+		if !strings.Contains(recorder.Body.String(), "POST /authors/{id}") {
+			t.Fatalf("Expected the updated profile, got %s", recorder.Body.String())
+		}
+	})
+
+	t.Run("Rejects updates renaming profiles", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		req := yamlRequest("PUT", "/api/profiles/booksns/books", profileYaml)
+		handler.handleAPIProfileUpdate(recorder, req, httprouter.Params{
+			{Key: "namespace", Value: "booksns"},
+			{Key: "name", Value: "books"},
+		})
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, recorder.Code)
+		}
+	})
+
+	t.Run("Checks that users are authorized by Kubernetes RBAC", func(t *testing.T) {
+		// the fake client never allows subject access reviews
+		ctx := public.NewUserContext(context.Background(), public.User{Name: "alice"})
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/profiles/booksns/"+profile.Name, nil).WithContext(ctx)
+		handler.handleAPIProfile(recorder, req, params)
+		if recorder.Code != http.StatusForbidden {
+			t.Fatalf("Expected status %d, got %d", http.StatusForbidden, recorder.Code)
+		}
+	})
+
+	t.Run("Only lets unauthenticated users write profiles if enabled", func(t *testing.T) {
+		handler := &handler{
+			k8sClient: k8sClient,
+			spClient:  spfake.NewSimpleClientset(),
+		}
+
+		recorder := httptest.NewRecorder()
+		handler.handleAPIProfileCreate(recorder, yamlRequest("POST", "/api/profiles", profileYaml), httprouter.Params{})
+		if recorder.Code != http.StatusForbidden {
+			t.Fatalf("Expected status %d, got %d", http.StatusForbidden, recorder.Code)
+		}
+
+		recorder = httptest.NewRecorder()
+		handler.handleAPIProfileUpdate(recorder, yamlRequest("PUT", "/api/profiles/booksns/"+profile.Name, profileYaml), params)
+		if recorder.Code != http.StatusForbidden {
+			t.Fatalf("Expected status %d, got %d", http.StatusForbidden, recorder.Code)
+		}
+
+		// reads are still allowed
+		recorder = httptest.NewRecorder()
+		handler.handleAPIProfiles(recorder, httptest.NewRequest("GET", "/api/profiles?namespace=booksns", nil), httprouter.Params{})
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+		}
+	})
+
+	t.Run("Requires a JSON or YAML Content-Type", func(t *testing.T) {
+		for _, contentType := range []string{"", "text/plain", "application/x-www-form-urlencoded"} {
+			req := httptest.NewRequest("POST", "/api/profiles", bytes.NewReader(profileYaml))
+			req.Header.Set("Content-Type", contentType)
+			recorder := httptest.NewRecorder()
+			handler.handleAPIProfileCreate(recorder, req, httprouter.Params{})
+			if recorder.Code != http.StatusUnsupportedMediaType {
+				t.Fatalf("Expected status %d for Content-Type %q, got %d", http.StatusUnsupportedMediaType, contentType, recorder.Code)
+			}
+		}
+
+		req := httptest.NewRequest("POST", "/api/profiles/validate", bytes.NewReader(profileYaml))
+		req.Header.Set("Content-Type", "application/yaml; charset=utf-8")
+		recorder := httptest.NewRecorder()
+		handler.handleAPIProfileValidate(recorder, req, httprouter.Params{})
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+		}
+	})
+}
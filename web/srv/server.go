@@ -9,10 +9,13 @@ import (
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/linkerd/linkerd2/controller/api/public"
+	spclient "github.com/linkerd/linkerd2/controller/gen/client/clientset/versioned"
 	pb "github.com/linkerd/linkerd2/controller/gen/public"
 	"github.com/linkerd/linkerd2/pkg/filesonly"
 	"github.com/linkerd/linkerd2/pkg/prometheus"
+	"github.com/linkerd/linkerd2/pkg/util"
 	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
 )
 
 const (
@@ -26,6 +29,8 @@ type (
 		reload      bool
 		templates   map[string]*template.Template
 		router      *httprouter.Router
+		auth        Authenticator
+		basePath    string
 	}
 
 	templatePayload struct {
@@ -38,17 +43,34 @@ type (
 		Error               bool
 		ErrorMessage        string
 		PathPrefix          string
+		BasePath            string
 	}
 )
 
 // this is called by the HTTP server to actually respond to a request
 func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if s.auth != nil && !isPublicPath(s.basePath, req.URL.Path) {
+		user, ok := s.auth.authenticate(req)
+		if !ok {
+			s.auth.unauthenticated(w, req)
+			return
+		}
+		// the Public API client forwards the user's identity
+		req = req.WithContext(public.NewUserContext(req.Context(), user))
+	}
+
 	s.router.ServeHTTP(w, req)
 }
 
 // NewServer returns an initialized `http.Server`, configured to listen on an
 // address, render templates, and serve static assets, for a given Linkerd
-// control plane.
+// control plane. If auth is not nil, it authenticates the users of the
+// dashboard. If namespaces is not empty, the dashboard only shows these
+// namespaces. Grafana applies neither restriction, so it isn't proxied if
+// either is set. ServiceProfiles are managed through k8sClient and spClient,
+// and can only be written by authenticated users unless profileWrites is
+// set. All routes are served under basePath, e.g. "/linkerd", or at the root
+// if it is empty.
 func NewServer(
 	addr string,
 	grafanaAddr string,
@@ -58,10 +80,19 @@ func NewServer(
 	controllerNamespace string,
 	reload bool,
 	apiClient public.APIClient,
+	auth Authenticator,
+	namespaces []string,
+	k8sClient kubernetes.Interface,
+	spClient spclient.Interface,
+	profileWrites bool,
+	basePath string,
 ) *http.Server {
+	basePath = util.NormalizeBasePath(basePath)
 	server := &Server{
 		templateDir: templateDir,
 		reload:      reload,
+		auth:        auth,
+		basePath:    basePath,
 	}
 
 	server.router = &httprouter.Router{
@@ -70,13 +101,23 @@ func NewServer(
 		HandleMethodNotAllowed: false, // disable 405s
 	}
 
+	var grafana *grafanaProxy
+	if auth == nil && len(namespaces) == 0 {
+		grafana = newGrafanaProxy(grafanaAddr, basePath)
+	}
+
 	wrappedServer := prometheus.WithTelemetry(server)
 	handler := &handler{
 		apiClient:           apiClient,
 		render:              server.RenderTemplate,
 		uuid:                uuid,
 		controllerNamespace: controllerNamespace,
-		grafanaProxy:        newGrafanaProxy(grafanaAddr),
+		grafanaProxy:        grafana,
+		namespaces:          newNamespaceSet(namespaces),
+		k8sClient:           k8sClient,
+		spClient:            spClient,
+		profileWrites:       profileWrites,
+		basePath:            basePath,
 	}
 
 	httpServer := &http.Server{
@@ -87,53 +128,68 @@ func NewServer(
 	}
 
 	// webapp routes
-	server.router.GET("/", handler.handleIndex)
-	server.router.GET("/overview", handler.handleIndex)
-	server.router.GET("/servicemesh", handler.handleIndex)
-	server.router.GET("/namespaces", handler.handleIndex)
-	server.router.GET("/namespaces/:namespace", handler.handleIndex)
-	server.router.GET("/daemonsets", handler.handleIndex)
-	server.router.GET("/statefulsets", handler.handleIndex)
-	server.router.GET("/jobs", handler.handleIndex)
-	server.router.GET("/deployments", handler.handleIndex)
-	server.router.GET("/replicationcontrollers", handler.handleIndex)
-	server.router.GET("/pods", handler.handleIndex)
-	server.router.GET("/authorities", handler.handleIndex)
-	server.router.GET("/namespaces/:namespace/pods/:pod", handler.handleIndex)
-	server.router.GET("/namespaces/:namespace/daemonsets/:daemonset", handler.handleIndex)
-	server.router.GET("/namespaces/:namespace/statefulsets/:statefulset", handler.handleIndex)
-	server.router.GET("/namespaces/:namespace/deployments/:deployment", handler.handleIndex)
-	server.router.GET("/namespaces/:namespace/jobs/:job", handler.handleIndex)
-	server.router.GET("/namespaces/:namespace/replicationcontrollers/:replicationcontroller", handler.handleIndex)
-	server.router.GET("/tap", handler.handleIndex)
-	server.router.GET("/top", handler.handleIndex)
-	server.router.GET("/community", handler.handleIndex)
-	server.router.GET("/routes", handler.handleIndex)
-	server.router.GET("/profiles/new", handler.handleProfileDownload)
+	if basePath != "" {
+		// the dashboard is only served under its base path
+		server.router.GET("/", handler.handleBasePathRedirect)
+	}
+	server.router.GET(basePath+"/", handler.handleIndex)
+	server.router.GET(basePath+"/overview", handler.handleIndex)
+	server.router.GET(basePath+"/servicemesh", handler.handleIndex)
+	server.router.GET(basePath+"/namespaces", handler.handleIndex)
+	server.router.GET(basePath+"/namespaces/:namespace", handler.handleIndex)
+	server.router.GET(basePath+"/daemonsets", handler.handleIndex)
+	server.router.GET(basePath+"/statefulsets", handler.handleIndex)
+	server.router.GET(basePath+"/jobs", handler.handleIndex)
+	server.router.GET(basePath+"/deployments", handler.handleIndex)
+	server.router.GET(basePath+"/replicationcontrollers", handler.handleIndex)
+	server.router.GET(basePath+"/pods", handler.handleIndex)
+	server.router.GET(basePath+"/authorities", handler.handleIndex)
+	server.router.GET(basePath+"/namespaces/:namespace/pods/:pod", handler.handleIndex)
+	server.router.GET(basePath+"/namespaces/:namespace/daemonsets/:daemonset", handler.handleIndex)
+	server.router.GET(basePath+"/namespaces/:namespace/statefulsets/:statefulset", handler.handleIndex)
+	server.router.GET(basePath+"/namespaces/:namespace/deployments/:deployment", handler.handleIndex)
+	server.router.GET(basePath+"/namespaces/:namespace/jobs/:job", handler.handleIndex)
+	server.router.GET(basePath+"/namespaces/:namespace/replicationcontrollers/:replicationcontroller", handler.handleIndex)
+	server.router.GET(basePath+"/tap", handler.handleIndex)
+	server.router.GET(basePath+"/top", handler.handleIndex)
+	server.router.GET(basePath+"/community", handler.handleIndex)
+	server.router.GET(basePath+"/routes", handler.handleIndex)
+	server.router.GET(basePath+"/profiles/new", handler.handleProfileDownload)
 
 	// add catch-all parameter to match all files in dir
-	server.router.GET("/dist/*filepath", mkStaticHandler(staticDir))
+	server.router.GET(basePath+"/dist/*filepath", mkStaticHandler(staticDir))
 
 	// webapp api routes
-	server.router.GET("/api/version", handler.handleAPIVersion)
+	server.router.GET(basePath+"/api/version", handler.handleAPIVersion)
 	// Traffic Performance Summary.  This route used to be called /api/stat
 	// but was renamed to avoid triggering ad blockers.
 	// See: https://github.com/linkerd/linkerd2/issues/970
-	server.router.GET("/api/tps-reports", handler.handleAPIStat)
-	server.router.GET("/api/pods", handler.handleAPIPods)
-	server.router.GET("/api/services", handler.handleAPIServices)
-	server.router.GET("/api/tap", handler.handleAPITap)
-	server.router.GET("/api/routes", handler.handleAPITopRoutes)
-	server.router.GET("/api/edges", handler.handleAPIEdges)
+	server.router.GET(basePath+"/api/tps-reports", handler.handleAPIStat)
+	server.router.GET(basePath+"/api/pods", handler.handleAPIPods)
+	server.router.GET(basePath+"/api/services", handler.handleAPIServices)
+	server.router.GET(basePath+"/api/tap", handler.handleAPITap)
+	server.router.GET(basePath+"/api/routes", handler.handleAPITopRoutes)
+	server.router.GET(basePath+"/api/edges", handler.handleAPIEdges)
+	server.router.GET(basePath+"/api/profiles", handler.handleAPIProfiles)
+	server.router.GET(basePath+"/api/profiles/:namespace/:name", handler.handleAPIProfile)
+	server.router.POST(basePath+"/api/profiles", handler.handleAPIProfileCreate)
+	server.router.POST(basePath+"/api/profiles/validate", handler.handleAPIProfileValidate)
+	server.router.POST(basePath+"/api/profiles/generate", handler.handleAPIProfileGenerate)
+	server.router.PUT(basePath+"/api/profiles/:namespace/:name", handler.handleAPIProfileUpdate)
 
 	// grafana proxy
-	server.router.DELETE("/grafana/*grafanapath", handler.handleGrafana)
-	server.router.GET("/grafana/*grafanapath", handler.handleGrafana)
-	server.router.HEAD("/grafana/*grafanapath", handler.handleGrafana)
-	server.router.OPTIONS("/grafana/*grafanapath", handler.handleGrafana)
-	server.router.PATCH("/grafana/*grafanapath", handler.handleGrafana)
-	server.router.POST("/grafana/*grafanapath", handler.handleGrafana)
-	server.router.PUT("/grafana/*grafanapath", handler.handleGrafana)
+	server.router.DELETE(basePath+"/grafana/*grafanapath", handler.handleGrafana)
+	server.router.GET(basePath+"/grafana/*grafanapath", handler.handleGrafana)
+	server.router.HEAD(basePath+"/grafana/*grafanapath", handler.handleGrafana)
+	server.router.OPTIONS(basePath+"/grafana/*grafanapath", handler.handleGrafana)
+	server.router.PATCH(basePath+"/grafana/*grafanapath", handler.handleGrafana)
+	server.router.POST(basePath+"/grafana/*grafanapath", handler.handleGrafana)
+	server.router.PUT(basePath+"/grafana/*grafanapath", handler.handleGrafana)
+
+	// login routes
+	if auth != nil {
+		auth.register(server.router, basePath)
+	}
 
 	return httpServer
 }
@@ -0,0 +1,130 @@
+package srv
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/linkerd/linkerd2/controller/api/public"
+	pb "github.com/linkerd/linkerd2/controller/gen/public"
+)
+
+func TestServerBasePath(t *testing.T) {
+	staticDir, err := ioutil.TempDir("", "linkerd-web-static")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer os.RemoveAll(staticDir)
+	err = ioutil.WriteFile(filepath.Join(staticDir, "index_bundle.js"), []byte("bundle"), 0644)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	mockAPIClient := &public.MockAPIClient{
+		VersionInfoToReturn: &pb.VersionInfo{ReleaseVersion: "0.3.3"},
+	}
+	server := NewServer(":8084", "127.0.0.1:3000", "../templates", staticDir, "", "linkerd", true, mockAPIClient, nil, nil, nil, nil, false, "/linkerd/")
+
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		server.Handler.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		return recorder
+	}
+
+	t.Run("Generates links under the base path", func(t *testing.T) {
+		recorder := get("/linkerd/namespaces/emojivoto")
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+		}
+
+		body := recorder.Body.String()
+		expectedSubstrings := []string{
+			"src=\"/linkerd/dist/index_bundle.js\"",
+			"href=\"/linkerd/dist/img/favicon.png\"",
+			"data-base-path=\"/linkerd\"",
+		}
+		for _, expectedSubstring := range expectedSubstrings {
+			if !strings.Contains(body, expectedSubstring) {
+				t.Fatalf("Expected string [%s] to be present in [%s]", expectedSubstring, body)
+			}
+		}
+	})
+
+	t.Run("Serves static assets under the base path", func(t *testing.T) {
+		recorder := get("/linkerd/dist/index_bundle.js")
+		if recorder.Code != http.StatusOK || recorder.Body.String() != "bundle" {
+			t.Fatalf("Expected the bundle to be served, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+	})
+
+	t.Run("Redirects to paths under the base path", func(t *testing.T) {
+		redirects := []struct {
+			path     string
+			code     int
+			location string
+		}{
+			{"/", http.StatusFound, "/linkerd/"},
+			{"/linkerd", http.StatusMovedPermanently, "/linkerd/"},
+			{"/linkerd/overview/", http.StatusMovedPermanently, "/linkerd/overview"},
+		}
+		for _, redirect := range redirects {
+			recorder := get(redirect.path)
+			if recorder.Code != redirect.code {
+				t.Fatalf("Expected %s to return status %d, got %d", redirect.path, redirect.code, recorder.Code)
+			}
+			if location := recorder.Header().Get("Location"); location != redirect.location {
+				t.Fatalf("Expected %s to redirect to %s, got %s", redirect.path, redirect.location, location)
+			}
+		}
+	})
+
+	t.Run("Doesn't serve routes outside of the base path", func(t *testing.T) {
+		for _, path := range []string{"/overview", "/api/version", "/dist/index_bundle.js"} {
+			if recorder := get(path); recorder.Code != http.StatusNotFound {
+				t.Fatalf("Expected %s to return status %d, got %d", path, http.StatusNotFound, recorder.Code)
+			}
+		}
+	})
+}
+
+func TestGrafanaProxyBasePath(t *testing.T) {
+	proxy := newGrafanaProxy("linkerd-grafana:3000", "/linkerd")
+
+	req := httptest.NewRequest("GET", "/linkerd/grafana/dashboard/db/linkerd-deployment?var-namespace=emojivoto", nil)
+	proxy.Director(req)
+	if req.URL.Path != "/dashboard/db/linkerd-deployment" {
+		t.Fatalf("Expected the base path and grafana prefix to be stripped, got %s", req.URL.Path)
+	}
+	if req.URL.Host != "linkerd-grafana:3000" || req.Host != "linkerd-grafana:3000" {
+		t.Fatalf("Expected the request to be sent to grafana, got %s", req.URL.Host)
+	}
+}
+
+func TestServerGrafanaRestrictions(t *testing.T) {
+	mockAPIClient := &public.MockAPIClient{
+		VersionInfoToReturn: &pb.VersionInfo{ReleaseVersion: "0.3.3"},
+	}
+	trustedNetworks, err := ParseNetworks("192.0.2.0/24")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	auth := &ProxyAuthenticator{UserHeader: "X-Remote-User", TrustedNetworks: trustedNetworks}
+
+	servers := map[string]*http.Server{
+		"restricted to namespaces": NewServer(":8084", "127.0.0.1:3000", "../templates", "", "", "linkerd", true, mockAPIClient, nil, []string{"emojivoto"}, nil, nil, false, ""),
+		"authenticating users":     NewServer(":8084", "127.0.0.1:3000", "../templates", "", "", "linkerd", true, mockAPIClient, auth, nil, nil, nil, false, ""),
+	}
+	for desc, server := range servers {
+		req := httptest.NewRequest("GET", "/grafana/dashboard/db/linkerd-deployment?var-namespace=kube-system", nil)
+		req.Header.Set("X-Remote-User", "alice")
+		recorder := httptest.NewRecorder()
+		server.Handler.ServeHTTP(recorder, req)
+		if recorder.Code != http.StatusForbidden {
+			t.Fatalf("Expected Grafana not to be proxied when %s, got status %d", desc, recorder.Code)
+		}
+	}
+}